@@ -100,10 +100,10 @@ $> pkgs-checker entropy info app/foo-1
 					for _, dep := range detail.Dependencies {
 						if onlyDeps {
 							if dep.Condition == gentoo.PkgCondMatchVersion {
-								fmt.Printf("=%s:%s*\n", dep.String(), dep.Slot)
+								fmt.Printf("=%s:%s*\n", dep.GetPackageNameWithVersion(), dep.Slot)
 							} else {
 								fmt.Printf("%s%s:%s\n", dep.Condition.String(),
-									dep.String(), dep.Slot)
+									dep.GetPackageNameWithVersion(), dep.Slot)
 							}
 						} else {
 							fmt.Println("\tname:", dep.Name)
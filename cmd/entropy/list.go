@@ -70,7 +70,7 @@ $> pkgs-checker entropy list -d /var/lib/entropy/client/database/amd64/sabayon-w
 
 				for _, p := range pkgs {
 					if verbose {
-						plist = append(plist, fmt.Sprintf("%s:%s", p, p.Slot))
+						plist = append(plist, fmt.Sprintf("%s:%s", p.GetPackageNameWithVersion(), p.Slot))
 					} else {
 						plist = append(plist, fmt.Sprintf("%s/%s", p.Category, p.Name))
 					}
@@ -21,6 +21,7 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	logger "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -39,7 +40,11 @@ func newHashCommand() *cobra.Command {
 
 		Example: `$> pkgs-checker hash -p /usr/portage/packages/sys-app/entropy-9999.tbz2
 
-$> pkgs-checker hash -e .pyc -e .pyo -e .mo -e .bz2 --directory /usr/portage/packages/`,
+$> pkgs-checker hash -e .pyc -e .pyo -e .mo -e .bz2 --directory /usr/portage/packages/
+
+$> pkgs-checker hash --algorithm blake2b -f hashes.txt --directory /usr/portage/packages/
+
+$> pkgs-checker hash --manifest jsonl --directory /usr/portage/packages/`,
 
 		PreRun: func(cmd *cobra.Command, args []string) {
 			if settings.GetBool("stdin") == false &&
@@ -48,6 +53,18 @@ $> pkgs-checker hash -e .pyc -e .pyo -e .mo -e .bz2 --directory /usr/portage/pac
 				fmt.Println("Both package and directory not present or stdin option is not present.")
 				os.Exit(1)
 			}
+
+			_, err := hash.NewHash(settings.GetString("algorithm"))
+			if err != nil {
+				fmt.Println("ERROR: " + err.Error())
+				os.Exit(1)
+			}
+
+			manifest := settings.GetString("manifest")
+			if manifest != "" && !hash.IsManifestFormat(manifest) {
+				fmt.Println("ERROR: Invalid manifest format " + manifest)
+				os.Exit(1)
+			}
 		},
 
 		Run: func(cmd *cobra.Command, args []string) {
@@ -76,7 +93,13 @@ $> pkgs-checker hash -e .pyc -e .pyo -e .mo -e .bz2 --directory /usr/portage/pac
 
 			if settings.GetString("hashfile") != "" {
 				writeHashfile(checker)
-			} else {
+			}
+
+			if settings.GetString("manifest") != "" {
+				err = hash.WriteManifest(os.Stdout, checker.GetPackages(),
+					settings.GetString("manifest"))
+				commons.CheckErr(err)
+			} else if settings.GetString("hashfile") == "" {
 				for _, p := range checker.GetPackages() {
 					// Skip package in errors from file
 					if p.CheckSum() != "" {
@@ -92,21 +115,28 @@ $> pkgs-checker hash -e .pyc -e .pyo -e .mo -e .bz2 --directory /usr/portage/pac
 
 	flags.Bool("stdin", false, "Read package data from stdin")
 	flags.Bool("hash-empty", false,
-		fmt.Sprintf("If create a fake hash for empty packages or use %s.",
-			commons.PKGS_CHECKER_EMPTY_PKGHASH))
+		"If create a fake hash for empty packages or use a checksum of zeros with the length of the digest.")
 	flags.Bool("ignore-errors", false, "Ignore errors with broken tarball.")
 	flags.StringSliceP("package", "p", []string{}, "Path of package to check.")
 	flags.StringSliceP("ignore", "i", []string{}, "File to ignore.")
 	flags.StringSliceP("ignore-extension", "e", []string{}, "Extension to ignore.")
 
-	flags.StringP("directory", "d", "", "Artefacts directory with .tbz2 files.")
+	flags.StringP("directory", "d", "", "Artefacts directory with .tbz2 and .gpkg.tar files.")
 	flags.StringP("hashfile", "f", "", `Path of hashfile where write checksum.
 Default output on stdout with format: HASH <CHECKSUM> <PACKAGE>`)
+	flags.String("algorithm", hash.DefaultHashAlgorithm,
+		fmt.Sprintf("Hash algorithm used for files and packages (%s).",
+			strings.Join(hash.GetHashAlgorithms(), ", ")))
+	flags.String("manifest", "",
+		fmt.Sprintf("Print the files of the packages with digest, size, mode and owner (%s).",
+			strings.Join(hash.GetManifestFormats(), ", ")))
 
 	settings.BindPFlag("stdin", flags.Lookup("stdin"))
 	settings.BindPFlag("package", flags.Lookup("package"))
 	settings.BindPFlag("directory", flags.Lookup("directory"))
 	settings.BindPFlag("hashfile", flags.Lookup("hashfile"))
+	settings.BindPFlag("algorithm", flags.Lookup("algorithm"))
+	settings.BindPFlag("manifest", flags.Lookup("manifest"))
 	settings.BindPFlag("hash-empty", flags.Lookup("hash-empty"))
 	settings.BindPFlag("ignoreFiles", flags.Lookup("ignore"))
 	settings.BindPFlag("ignoreExt", flags.Lookup("ignore-extension"))
@@ -119,6 +149,14 @@ func writeHashfile(checker hash.CheckerExecutor) {
 	var err error
 	var hashfile *os.File
 
+	// Checksums of different configurations must not be mixed.
+	header := hash.NewHashfileHeader(settings.GetViper())
+	writeHeader, err := header.CheckHashfile(settings.GetString("hashfile"))
+	if err != nil {
+		fmt.Println("ERROR: " + err.Error())
+		os.Exit(1)
+	}
+
 	hashfile, err = os.OpenFile(
 		settings.GetString("hashfile"),
 		os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0660)
@@ -127,6 +165,11 @@ func writeHashfile(checker hash.CheckerExecutor) {
 	}
 	defer hashfile.Close()
 
+	if writeHeader {
+		_, err = fmt.Fprintln(hashfile, header.String())
+		commons.CheckErr(err)
+	}
+
 	for _, p := range checker.GetPackages() {
 		// Skip package in errors from file
 		if p.CheckSum() != "" {
@@ -31,6 +31,7 @@ func NewPkgCommand() *cobra.Command {
 
 	cmd.AddCommand(
 		newPkgInfoCommand(),
+		newPkgInspectCommand(),
 	)
 
 	return cmd
@@ -0,0 +1,113 @@
+/*
+
+Copyright (C) 2017-2019  Daniele Rondina <geaaru@sabayonlinux.org>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+*/
+
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Sabayon/pkgs-checker/pkg/gentoo"
+)
+
+func newPkgInspectCommand() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "inspect <file.tbz2|file.gpkg.tar> [OPTIONS]",
+		Short: "Show the metadata of a binary package.",
+		Args:  cobra.ExactArgs(1),
+		Example: `
+$> pkgs-checker pkg inspect openssl-1.1.1k.tbz2
+
+$> pkgs-checker pkg inspect --entry RDEPEND openssl-1.1.1k.tbz2
+
+$> pkgs-checker pkg inspect -j openssl-1.1.1k.gpkg.tar
+`,
+		Run: func(cmd *cobra.Command, args []string) {
+			jsonOut, _ := cmd.Flags().GetBool("json")
+			entries, _ := cmd.Flags().GetStringSlice("entry")
+			list, _ := cmd.Flags().GetBool("list")
+
+			x, err := gentoo.ReadBinPkgMetadataFiles(args[0])
+			if err != nil {
+				fmt.Println("ERROR: " + err.Error())
+				os.Exit(1)
+			}
+
+			if list {
+				for _, name := range x.GetNames() {
+					fmt.Println(fmt.Sprintf("%s (%d bytes)", name, len(x[name])))
+				}
+				return
+			}
+
+			if len(entries) > 0 {
+				for _, e := range entries {
+					data, ok := x[e]
+					if !ok {
+						fmt.Println("ERROR: Entry " + e + " not available.")
+						os.Exit(1)
+					}
+					fmt.Print(string(data))
+				}
+				return
+			}
+
+			pm, err := x.ToMetadata(nil)
+			if err != nil {
+				fmt.Println("ERROR: " + err.Error())
+				os.Exit(1)
+			}
+
+			if jsonOut {
+				out, err := json.Marshal(pm)
+				if err != nil {
+					fmt.Println(err)
+					os.Exit(1)
+				}
+				fmt.Println(string(out))
+			} else {
+				fmt.Println("package:", pm.GetPackageNameWithVersion())
+				fmt.Println("slot:", pm.GetSlotStr())
+				fmt.Println("repository:", pm.Repository)
+				fmt.Println("eapi:", pm.Eapi)
+				fmt.Println("uses:", strings.Join(pm.UseFlags, " "))
+				fmt.Println("license:", pm.License)
+				fmt.Println("keywords:", pm.KEYWORDS)
+				fmt.Println("chost:", pm.CHost)
+				fmt.Println("cflags:", pm.CFlags)
+				fmt.Println("build_time:", pm.BUILD_TIME)
+				fmt.Println("depend:", pm.DEPEND)
+				fmt.Println("rdepend:", pm.RDEPEND)
+				fmt.Println("pdepend:", pm.PDEPEND)
+				fmt.Println("bdepend:", pm.BDEPEND)
+			}
+		},
+	}
+
+	var flags = cmd.Flags()
+	flags.BoolP("json", "j", false, "Enable json output on stdout.")
+	flags.StringSliceP("entry", "e", []string{}, "Print the raw content of the metadata entries.")
+	flags.Bool("list", false, "List the metadata entries.")
+
+	return cmd
+}
@@ -0,0 +1,139 @@
+/*
+
+Copyright (C) 2017-2021  Daniele Rondina <geaaru@sabayonlinux.org>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+*/
+
+package portage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/Sabayon/pkgs-checker/pkg/gentoo"
+
+	"github.com/spf13/cobra"
+)
+
+type RequiredUseViolation struct {
+	Package     string   `json:"package"`
+	RequiredUse string   `json:"required_use"`
+	Use         []string `json:"use"`
+	Unsatisfied []string `json:"unsatisfied,omitempty"`
+	ErrorMsg    string   `json:"error,omitempty"`
+}
+
+func newCheckRequiredUseCommand() *cobra.Command {
+
+	var cmd = &cobra.Command{
+		Use:   "check-required-use [cat/pkg[:slot]...] [OPTIONS]",
+		Short: "Check REQUIRED_USE of the installed packages.",
+		Args:  cobra.ArbitraryArgs,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			dbPkgsDir, _ := cmd.Flags().GetString("db-pkgs-dir-path")
+			if dbPkgsDir == "" {
+				fmt.Println("Invalid Path of the portage metadata.")
+				os.Exit(1)
+			}
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+
+			dbPkgsDir, _ := cmd.Flags().GetString("db-pkgs-dir-path")
+			jsonOutput, _ := cmd.Flags().GetBool("json")
+
+			opts := &gentoo.PortageUseParseOpts{}
+			for _, pkg := range args {
+				gp, err := gentoo.ParsePackageStr(pkg)
+				if err != nil {
+					fmt.Println(fmt.Sprintf("Invalid pkg %s: %s",
+						pkg, err.Error()))
+					os.Exit(1)
+				}
+
+				opts.Packages = append(opts.Packages, gp.GetPackageNameWithSlot())
+				opts.AddCategory(gp.Category)
+			}
+
+			violations := []RequiredUseViolation{}
+			scanner := newVdbScanner(dbPkgsDir, opts,
+				gentoo.MetaFieldRequiredUse, gentoo.MetaFieldUse)
+			err := scanner.Walk(func(p *gentoo.PortageMetaData) error {
+				unsatisfied, err := p.CheckRequiredUse()
+				if err != nil {
+					violations = append(violations, RequiredUseViolation{
+						Package:     p.GetPackageNameWithVersion(),
+						RequiredUse: p.REQUIRED_USE,
+						Use:         p.Use,
+						ErrorMsg:    err.Error(),
+					})
+				} else if len(unsatisfied) > 0 {
+					violations = append(violations, RequiredUseViolation{
+						Package:     p.GetPackageNameWithVersion(),
+						RequiredUse: p.REQUIRED_USE,
+						Use:         p.Use,
+						Unsatisfied: unsatisfied,
+					})
+				}
+				return nil
+			})
+			if err != nil {
+				fmt.Println("ERROR: " + err.Error())
+				os.Exit(1)
+			}
+
+			sort.Slice(violations, func(i, j int) bool {
+				return violations[i].Package < violations[j].Package
+			})
+
+			if jsonOutput {
+				data, err := json.Marshal(violations)
+				if err != nil {
+					fmt.Println(fmt.Sprintf("Error on convert data to json: %s", err.Error()))
+					os.Exit(1)
+				}
+				fmt.Println(string(data))
+			} else {
+				for _, v := range violations {
+					if v.ErrorMsg != "" {
+						fmt.Println(fmt.Sprintf("%s: invalid REQUIRED_USE \"%s\": %s",
+							v.Package, v.RequiredUse, v.ErrorMsg))
+						continue
+					}
+					fmt.Println(fmt.Sprintf("%s: USE=\"%s\"",
+						v.Package, strings.Join(v.Use, " ")))
+					for _, u := range v.Unsatisfied {
+						fmt.Println("  " + u)
+					}
+				}
+			}
+
+			if len(violations) > 0 {
+				os.Exit(1)
+			}
+		},
+	}
+
+	var flags = cmd.Flags()
+
+	flags.StringP("db-pkgs-dir-path", "p", "/var/db/pkg",
+		"Path of the portage metadata.")
+	flags.BoolP("json", "j", false, "Output in JSON format")
+
+	return cmd
+}
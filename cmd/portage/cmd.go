@@ -19,9 +19,25 @@ along with this program. If not, see <http://www.gnu.org/licenses/>.
 package portage
 
 import (
+	"github.com/Sabayon/pkgs-checker/pkg/commons"
+	"github.com/Sabayon/pkgs-checker/pkg/gentoo"
+
 	"github.com/spf13/cobra"
+	settings "github.com/spf13/viper"
 )
 
+// newVdbScanner returns a scanner that uses the workers defined by the
+// concurrency settings.
+func newVdbScanner(dir string, opts *gentoo.PortageUseParseOpts, fields ...gentoo.PortageMetaField) *gentoo.VdbScanner {
+	ans := gentoo.NewVdbScanner(dir, opts)
+	ans.SetFields(fields...)
+	if settings.GetBool("concurrency") {
+		commons.InitConcurrency()
+		ans.Concurrency = settings.GetInt("maxconcurrency")
+	}
+	return ans
+}
+
 func NewPortageCommand() *cobra.Command {
 	var cmd = &cobra.Command{
 		Use:   "portage [command] [OPTIONS]",
@@ -33,6 +49,16 @@ func NewPortageCommand() *cobra.Command {
 		newGenPkgsUsesCommand(),
 		newGenMetadataCommand(),
 		newMetadataCommand(),
+		newLicenseCheckCommand(),
+		newCheckRequiredUseCommand(),
+		newKeywordsCommand(),
+		newVerifyCommand(),
+		newOwnerCommand(),
+		newCollisionsCommand(),
+		newLinkageCommand(),
+		newRdepsCommand(),
+		newOrphansCommand(),
+		newDiffCommand(),
 	)
 
 	return cmd
@@ -0,0 +1,129 @@
+/*
+
+Copyright (C) 2017-2021  Daniele Rondina <geaaru@sabayonlinux.org>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+*/
+
+package portage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Sabayon/pkgs-checker/pkg/gentoo"
+
+	"github.com/spf13/cobra"
+)
+
+func newCollisionsCommand() *cobra.Command {
+
+	var cmd = &cobra.Command{
+		Use:   "collisions [OPTIONS]",
+		Short: "Show the files owned by multiple installed packages.",
+		Args:  cobra.NoArgs,
+		Example: `
+$> pkgs-checker portage collisions --allowlist collisions.allow
+`,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			dbPkgsDir, _ := cmd.Flags().GetString("db-pkgs-dir-path")
+			if dbPkgsDir == "" {
+				fmt.Println("Invalid Path of the portage metadata.")
+				os.Exit(1)
+			}
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+
+			dbPkgsDir, _ := cmd.Flags().GetString("db-pkgs-dir-path")
+			cacheFile, _ := cmd.Flags().GetString("cache-file")
+			allowlistFile, _ := cmd.Flags().GetString("allowlist")
+			onlyMd5Differs, _ := cmd.Flags().GetBool("only-md5-differs")
+			jsonOutput, _ := cmd.Flags().GetBool("json")
+
+			var allowlist *gentoo.CollisionAllowlist
+			if allowlistFile != "" {
+				var err error
+				allowlist, err = gentoo.LoadCollisionAllowlist(allowlistFile)
+				if err != nil {
+					fmt.Println("Error on load allowlist: " + err.Error())
+					os.Exit(1)
+				}
+			}
+
+			idx, err := loadOwnerIndex(dbPkgsDir, cacheFile)
+			if err != nil {
+				fmt.Println("ERROR: " + err.Error())
+				os.Exit(1)
+			}
+
+			collisions := []gentoo.FileCollision{}
+			for _, c := range idx.GetCollisions(allowlist) {
+				if onlyMd5Differs && !c.Md5Differs && !c.TypeDiffers {
+					continue
+				}
+				collisions = append(collisions, c)
+			}
+
+			if jsonOutput {
+				data, err := json.Marshal(collisions)
+				if err != nil {
+					fmt.Println(fmt.Sprintf("Error on convert data to json: %s", err.Error()))
+					os.Exit(1)
+				}
+				fmt.Println(string(data))
+			} else {
+				for _, c := range collisions {
+					flags := []string{}
+					if c.Md5Differs {
+						flags = append(flags, "md5 differs")
+					}
+					if c.TypeDiffers {
+						flags = append(flags, "type differs")
+					}
+
+					line := c.File
+					if len(flags) > 0 {
+						line += " [" + strings.Join(flags, ", ") + "]"
+					}
+					fmt.Println(line)
+					for _, o := range c.Owners {
+						fmt.Println(fmt.Sprintf("  %s (%s)", o.Package,
+							strings.TrimSpace(o.Type+" "+o.Hash)))
+					}
+				}
+			}
+
+			if len(collisions) > 0 {
+				os.Exit(1)
+			}
+		},
+	}
+
+	var flags = cmd.Flags()
+
+	flags.StringP("db-pkgs-dir-path", "p", "/var/db/pkg",
+		"Path of the portage metadata.")
+	flags.String("cache-file", "",
+		"Path of the index cache file. The index is updated on COUNTER changes.")
+	flags.String("allowlist", "",
+		"Path of the file with the patterns of the allowed collisions.")
+	flags.Bool("only-md5-differs", false,
+		"Show only the collisions with different content.")
+	flags.BoolP("json", "j", false, "Output in JSON format")
+
+	return cmd
+}
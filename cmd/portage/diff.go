@@ -0,0 +1,102 @@
+/*
+
+Copyright (C) 2017-2021  Daniele Rondina <geaaru@sabayonlinux.org>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+*/
+
+package portage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/Sabayon/pkgs-checker/pkg/gentoo"
+
+	"github.com/spf13/cobra"
+)
+
+func newDiffCommand() *cobra.Command {
+
+	var cmd = &cobra.Command{
+		Use:   "diff --from <dir> --to <dir> [OPTIONS]",
+		Short: "Show the differences between the packages of two vdb.",
+		Args:  cobra.NoArgs,
+		Example: `
+$> pkgs-checker portage diff --from old/var/db/pkg --to new/var/db/pkg
+
+$> pkgs-checker portage diff --from old/var/db/pkg --to new/var/db/pkg -o markdown
+`,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			from, _ := cmd.Flags().GetString("from")
+			to, _ := cmd.Flags().GetString("to")
+			output, _ := cmd.Flags().GetString("output")
+			if from == "" || to == "" {
+				fmt.Println("Options --from and --to are mandatory.")
+				os.Exit(1)
+			}
+			if output != "text" && output != "json" && output != "markdown" {
+				fmt.Println("Invalid output format " + output)
+				os.Exit(1)
+			}
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+
+			from, _ := cmd.Flags().GetString("from")
+			to, _ := cmd.Flags().GetString("to")
+			output, _ := cmd.Flags().GetString("output")
+
+			fromPkgs, err := newVdbScanner(from, nil, gentoo.VdbDiffFields...).Scan()
+			if err != nil {
+				fmt.Println("ERROR: " + err.Error())
+				os.Exit(1)
+			}
+			toPkgs, err := newVdbScanner(to, nil, gentoo.VdbDiffFields...).Scan()
+			if err != nil {
+				fmt.Println("ERROR: " + err.Error())
+				os.Exit(1)
+			}
+
+			diff, err := gentoo.DiffMetadata(fromPkgs, toPkgs)
+			if err != nil {
+				fmt.Println("ERROR: " + err.Error())
+				os.Exit(1)
+			}
+
+			switch output {
+			case "json":
+				data, err := json.Marshal(diff)
+				if err != nil {
+					fmt.Println(fmt.Sprintf("Error on convert data to json: %s", err.Error()))
+					os.Exit(1)
+				}
+				fmt.Println(string(data))
+			case "markdown":
+				fmt.Print(diff.Markdown())
+			default:
+				fmt.Print(diff.Text())
+			}
+		},
+	}
+
+	var flags = cmd.Flags()
+
+	flags.String("from", "", "Path of the portage metadata of the old root.")
+	flags.String("to", "", "Path of the portage metadata of the new root.")
+	flags.StringP("output", "o", "text", "Output format (text, json, markdown).")
+
+	return cmd
+}
@@ -21,6 +21,7 @@ package portage
 import (
 	"fmt"
 	"os"
+	"sort"
 
 	"github.com/Sabayon/pkgs-checker/pkg/gentoo"
 
@@ -56,6 +57,10 @@ func newGenMetadataCommand() *cobra.Command {
 			verbose, _ := cmd.Flags().GetBool("verbose")
 			to, _ := cmd.Flags().GetString("to")
 			quiet, _ := cmd.Flags().GetBool("quiet")
+			vdbSubPath, _ := cmd.Flags().GetString("vdb-subpath")
+			skipVerify, _ := cmd.Flags().GetBool("skip-verify")
+			verbatim, _ := cmd.Flags().GetBool("verbatim")
+			sets, _ := cmd.Flags().GetStringSlice("set")
 
 			var err error
 			var opts *gentoo.PortageUseParseOpts = &gentoo.PortageUseParseOpts{
@@ -78,7 +83,33 @@ func newGenMetadataCommand() *cobra.Command {
 
 			opts.Verbose = verbose
 
-			pkgs, err := gentoo.ParseMetadataDir(dbPkgsDir, opts)
+			wopts := gentoo.NewPortageMetaWriteOpts()
+			wopts.VdbSubPath = vdbSubPath
+			wopts.Verify = !skipVerify
+
+			overrides, err := gentoo.ParseMetaFieldOverrides(sets)
+			if err != nil {
+				fmt.Println("ERROR: " + err.Error())
+				os.Exit(1)
+			}
+			if len(overrides) > 0 && !verbatim {
+				fmt.Println("ERROR: Option --set requires --verbatim.")
+				os.Exit(1)
+			}
+
+			// The verbatim copy reads the directory of the entry scanned
+			// that could be different from the PF of the metadata.
+			pkgs := []*gentoo.PortageMetaData{}
+			dirs := make(map[*gentoo.PortageMetaData]string, 0)
+			err = newVdbScanner(dbPkgsDir, opts).WalkEntries(
+				func(dir string, pm *gentoo.PortageMetaData) error {
+					pkgs = append(pkgs, pm)
+					dirs[pm] = dir
+					return nil
+				})
+			sort.Slice(pkgs, func(i, j int) bool {
+				return dirs[pkgs[i]] < dirs[pkgs[j]]
+			})
 			if err != nil {
 				fmt.Println("ERROR: " + err.Error())
 				os.Exit(1)
@@ -103,7 +134,11 @@ func newGenMetadataCommand() *cobra.Command {
 					}
 				}
 
-				err = p.WriteMetadata2Dir(to, opts)
+				if verbatim {
+					err = gentoo.CopyPackageMetadataDir(dirs[p], to, overrides, wopts)
+				} else {
+					err = p.WriteMetadata2DirWithOpts(to, opts, wopts)
+				}
 				if err != nil {
 					fmt.Println(
 						fmt.Sprintf("Error on generate metadata for %s: %s",
@@ -122,6 +157,13 @@ func newGenMetadataCommand() *cobra.Command {
 	flags.StringP("db-pkgs-dir-path", "p", "/var/db/pkg",
 		"Path of the portage metadata.")
 	flags.Bool("quiet", false, "Quiet output.")
+	flags.String("vdb-subpath", gentoo.DefaultVdbSubPath,
+		"Path of the portage metadata relative to the target path.")
+	flags.Bool("skip-verify", false, "Skip the verification of the written metadata.")
+	flags.Bool("verbatim", false,
+		"Copy the whole package directory including the files not modelled.")
+	flags.StringSlice("set", []string{},
+		"Rewrite a metadata file on verbatim copy (ex. repository=sabayon).")
 
 	return cmd
 }
@@ -111,7 +111,8 @@ func newGenPkgsUsesCommand() *cobra.Command {
 
 			opts.Verbose = verbose
 
-			pkgs, err := gentoo.ParseMetadataDir(dbPkgsDir, opts)
+			pkgs, err := newVdbScanner(dbPkgsDir, opts,
+				gentoo.UsePortageMetaFields...).Scan()
 			if err != nil {
 				fmt.Println("ERROR: " + err.Error())
 				os.Exit(1)
@@ -0,0 +1,136 @@
+/*
+
+Copyright (C) 2017-2021  Daniele Rondina <geaaru@sabayonlinux.org>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+*/
+
+package portage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
+	"sort"
+
+	"github.com/Sabayon/pkgs-checker/pkg/gentoo"
+
+	"github.com/spf13/cobra"
+)
+
+type KeywordsReportEntry struct {
+	Package  string `json:"package"`
+	Keywords string `json:"keywords"`
+	State    string `json:"state"`
+}
+
+func defaultKeywordsArch() string {
+	switch runtime.GOARCH {
+	case "386":
+		return "x86"
+	case "ppc64le":
+		return "ppc64"
+	}
+	return runtime.GOARCH
+}
+
+func newKeywordsCommand() *cobra.Command {
+
+	var cmd = &cobra.Command{
+		Use:   "keywords [OPTIONS]",
+		Short: "Show installed packages testing-only or unkeyworded for an arch.",
+		Args:  cobra.NoArgs,
+		Example: `
+$> pkgs-checker portage keywords --arch arm
+
+$> pkgs-checker portage keywords --arch amd64 --stable -j
+`,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			dbPkgsDir, _ := cmd.Flags().GetString("db-pkgs-dir-path")
+			if dbPkgsDir == "" {
+				fmt.Println("Invalid Path of the portage metadata.")
+				os.Exit(1)
+			}
+			arch, _ := cmd.Flags().GetString("arch")
+			if arch == "" {
+				fmt.Println("Invalid arch.")
+				os.Exit(1)
+			}
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+
+			dbPkgsDir, _ := cmd.Flags().GetString("db-pkgs-dir-path")
+			arch, _ := cmd.Flags().GetString("arch")
+			withStable, _ := cmd.Flags().GetBool("stable")
+			jsonOutput, _ := cmd.Flags().GetBool("json")
+
+			report := []KeywordsReportEntry{}
+			scanner := newVdbScanner(dbPkgsDir, &gentoo.PortageUseParseOpts{},
+				gentoo.MetaFieldKeywords)
+			err := scanner.Walk(func(p *gentoo.PortageMetaData) error {
+				kw, err := p.GetKeywords()
+				if err != nil {
+					fmt.Println(fmt.Sprintf("WARNING: Package %s: %s",
+						p.GetPackageNameWithVersion(), err.Error()))
+					return nil
+				}
+
+				state := kw.GetState(arch)
+				if state == gentoo.KeywordStable && !withStable {
+					return nil
+				}
+
+				report = append(report, KeywordsReportEntry{
+					Package:  p.GetPackageNameWithVersion(),
+					Keywords: kw.String(),
+					State:    state.String(),
+				})
+				return nil
+			})
+			if err != nil {
+				fmt.Println("ERROR: " + err.Error())
+				os.Exit(1)
+			}
+
+			sort.Slice(report, func(i, j int) bool {
+				return report[i].Package < report[j].Package
+			})
+
+			if jsonOutput {
+				data, err := json.Marshal(report)
+				if err != nil {
+					fmt.Println(fmt.Sprintf("Error on convert data to json: %s", err.Error()))
+					os.Exit(1)
+				}
+				fmt.Println(string(data))
+			} else {
+				for _, e := range report {
+					fmt.Println(fmt.Sprintf("%-12s %s (%s)", e.State, e.Package, e.Keywords))
+				}
+			}
+		},
+	}
+
+	var flags = cmd.Flags()
+
+	flags.StringP("db-pkgs-dir-path", "p", "/var/db/pkg",
+		"Path of the portage metadata.")
+	flags.StringP("arch", "a", defaultKeywordsArch(), "Arch to check.")
+	flags.Bool("stable", false, "Include the stable packages in the report.")
+	flags.BoolP("json", "j", false, "Output in JSON format")
+
+	return cmd
+}
@@ -0,0 +1,190 @@
+/*
+
+Copyright (C) 2017-2021  Daniele Rondina <geaaru@sabayonlinux.org>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+*/
+
+package portage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/Sabayon/pkgs-checker/pkg/entropy"
+	"github.com/Sabayon/pkgs-checker/pkg/gentoo"
+
+	"github.com/spf13/cobra"
+)
+
+// The default license_groups files of the gentoo repository with the
+// current and the legacy layout.
+var defaultLicenseGroupsFiles = []string{
+	"/var/db/repos/gentoo/profiles/license_groups",
+	"/usr/portage/profiles/license_groups",
+}
+
+type LicenseViolation struct {
+	Package  string   `json:"package"`
+	License  string   `json:"license"`
+	Missing  []string `json:"missing,omitempty"`
+	ErrorMsg string   `json:"error,omitempty"`
+}
+
+func newLicenseCheckCommand() *cobra.Command {
+
+	var cmd = &cobra.Command{
+		Use:   "license-check [OPTIONS]",
+		Short: "Check licenses of the installed packages with ACCEPT_LICENSE.",
+		Args:  cobra.NoArgs,
+		Example: `
+$> pkgs-checker portage license-check --accept-license "-* @FREE"
+
+$> pkgs-checker portage license-check -d /var/lib/entropy/client/database/amd64/sabayon-weekly/standard/amd64/5/packages.db
+`,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			dbPkgsDir, _ := cmd.Flags().GetString("db-pkgs-dir-path")
+			entropyDb, _ := cmd.Flags().GetString("entropy-db")
+			if dbPkgsDir == "" && entropyDb == "" {
+				fmt.Println("Invalid Path of the portage metadata.")
+				os.Exit(1)
+			}
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+
+			dbPkgsDir, _ := cmd.Flags().GetString("db-pkgs-dir-path")
+			entropyDb, _ := cmd.Flags().GetString("entropy-db")
+			groupsFile, _ := cmd.Flags().GetString("license-groups")
+			accept, _ := cmd.Flags().GetString("accept-license")
+			jsonOutput, _ := cmd.Flags().GetBool("json")
+
+			if groupsFile == "" {
+				// The default files are optional: without them only an
+				// ACCEPT_LICENSE with groups is refused.
+				for _, f := range defaultLicenseGroupsFiles {
+					if _, err := os.Stat(f); err == nil {
+						groupsFile = f
+						break
+					}
+				}
+			}
+
+			groups := make(map[string][]string, 0)
+			if groupsFile != "" {
+				var err error
+				groups, err = gentoo.LoadLicenseGroups(groupsFile)
+				if err != nil {
+					fmt.Println("Error on load license groups: " + err.Error())
+					os.Exit(1)
+				}
+			}
+
+			policy, err := gentoo.NewLicensePolicy(groups, accept)
+			if err != nil {
+				fmt.Println("Invalid ACCEPT_LICENSE: " + err.Error())
+				if groupsFile == "" {
+					fmt.Println("No license_groups file found, set it with --license-groups.")
+				}
+				os.Exit(1)
+			}
+
+			violations := []LicenseViolation{}
+			check := func(pkg, license string, uses []string) {
+				missing, err := policy.CheckLicense(license, uses)
+				if err != nil {
+					violations = append(violations, LicenseViolation{
+						Package:  pkg,
+						License:  license,
+						ErrorMsg: err.Error(),
+					})
+				} else if len(missing) > 0 {
+					violations = append(violations, LicenseViolation{
+						Package: pkg,
+						License: license,
+						Missing: missing,
+					})
+				}
+			}
+
+			if entropyDb != "" {
+				pkgs, err := entropy.RetrieveRepoPackages(entropyDb)
+				if err != nil {
+					fmt.Println("ERROR: " + err.Error())
+					os.Exit(1)
+				}
+
+				// Entropy stores the licenses already reduced.
+				for _, p := range pkgs {
+					check(p.GetPackageNameWithVersion(), p.License, []string{})
+				}
+			} else {
+				scanner := newVdbScanner(dbPkgsDir, &gentoo.PortageUseParseOpts{},
+					gentoo.MetaFieldLicense, gentoo.MetaFieldUse)
+				err := scanner.Walk(func(p *gentoo.PortageMetaData) error {
+					check(p.GetPackageNameWithVersion(), p.License, p.Use)
+					return nil
+				})
+				if err != nil {
+					fmt.Println("ERROR: " + err.Error())
+					os.Exit(1)
+				}
+
+				sort.Slice(violations, func(i, j int) bool {
+					return violations[i].Package < violations[j].Package
+				})
+			}
+
+			if jsonOutput {
+				data, err := json.Marshal(violations)
+				if err != nil {
+					fmt.Println(fmt.Sprintf("Error on convert data to json: %s", err.Error()))
+					os.Exit(1)
+				}
+				fmt.Println(string(data))
+			} else {
+				for _, v := range violations {
+					if v.ErrorMsg != "" {
+						fmt.Println(fmt.Sprintf("%s: invalid LICENSE \"%s\": %s",
+							v.Package, v.License, v.ErrorMsg))
+					} else {
+						fmt.Println(fmt.Sprintf("%s: %s (missing: %s)",
+							v.Package, v.License, strings.Join(v.Missing, " ")))
+					}
+				}
+			}
+
+			if len(violations) > 0 {
+				os.Exit(1)
+			}
+		},
+	}
+
+	var flags = cmd.Flags()
+
+	flags.StringP("db-pkgs-dir-path", "p", "/var/db/pkg",
+		"Path of the portage metadata.")
+	flags.StringP("entropy-db", "d", "",
+		"Path of the entropy database to check in place of the portage metadata.")
+	flags.String("license-groups", "",
+		fmt.Sprintf("Path of the license_groups file. Default the first available of: %s.",
+			strings.Join(defaultLicenseGroupsFiles, ", ")))
+	flags.String("accept-license", "* -@EULA", "ACCEPT_LICENSE value to check.")
+	flags.BoolP("json", "j", false, "Output in JSON format")
+
+	return cmd
+}
@@ -0,0 +1,145 @@
+/*
+
+Copyright (C) 2017-2021  Daniele Rondina <geaaru@sabayonlinux.org>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+*/
+
+package portage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/Sabayon/pkgs-checker/pkg/gentoo"
+
+	"github.com/spf13/cobra"
+)
+
+func buildLinkageGraph(cmd *cobra.Command) *gentoo.LinkageGraph {
+	dbPkgsDir, _ := cmd.Flags().GetString("db-pkgs-dir-path")
+	if dbPkgsDir == "" {
+		fmt.Println("Invalid Path of the portage metadata.")
+		os.Exit(1)
+	}
+
+	g, err := gentoo.BuildLinkageGraph(newVdbScanner(dbPkgsDir, nil))
+	if err != nil {
+		fmt.Println("ERROR: " + err.Error())
+		os.Exit(1)
+	}
+	return g
+}
+
+func printLinkageSonames(cmd *cobra.Command, list []gentoo.LinkageSoname) {
+	jsonOutput, _ := cmd.Flags().GetBool("json")
+
+	if jsonOutput {
+		data, err := json.Marshal(list)
+		if err != nil {
+			fmt.Println(fmt.Sprintf("Error on convert data to json: %s", err.Error()))
+			os.Exit(1)
+		}
+		fmt.Println(string(data))
+	} else {
+		for _, e := range list {
+			fmt.Println(fmt.Sprintf("%s %s (%s)", e.Package, e.Soname, e.Abi))
+		}
+	}
+}
+
+func newLinkageBrokenCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "broken [OPTIONS]",
+		Short: "Show the packages that require sonames not provided.",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			broken := buildLinkageGraph(cmd).GetBroken()
+			printLinkageSonames(cmd, broken)
+			if len(broken) > 0 {
+				os.Exit(1)
+			}
+		},
+	}
+}
+
+func newLinkageConsumersCommand() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "consumers <soname>... [OPTIONS]",
+		Short: "Show the packages that require the sonames.",
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			abi, _ := cmd.Flags().GetString("abi")
+			g := buildLinkageGraph(cmd)
+
+			ans := []gentoo.LinkageSoname{}
+			for _, soname := range args {
+				ans = append(ans, g.GetConsumers(abi, soname)...)
+			}
+			printLinkageSonames(cmd, ans)
+		},
+	}
+
+	cmd.Flags().String("abi", "", "Filter the ABI (multilib category). Example: x86_64")
+
+	return cmd
+}
+
+func newLinkageRebuildCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "rebuild <cat/pkg> [OPTIONS]",
+		Short: "Show the packages to rebuild when the library package changes.",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			ans, err := buildLinkageGraph(cmd).GetRebuildDeps(args[0])
+			if err != nil {
+				fmt.Println("ERROR: " + err.Error())
+				os.Exit(1)
+			}
+			printLinkageSonames(cmd, ans)
+		},
+	}
+}
+
+func newLinkageCommand() *cobra.Command {
+
+	var cmd = &cobra.Command{
+		Use:   "linkage [command] [OPTIONS]",
+		Short: "Analyze the shared-library linkage of the installed packages.",
+		Args:  cobra.OnlyValidArgs,
+		Example: `
+$> pkgs-checker portage linkage broken
+
+$> pkgs-checker portage linkage consumers libssl.so.1.1 --abi x86_64
+
+$> pkgs-checker portage linkage rebuild dev-libs/openssl
+`,
+	}
+
+	var flags = cmd.PersistentFlags()
+
+	flags.StringP("db-pkgs-dir-path", "p", "/var/db/pkg",
+		"Path of the portage metadata.")
+	flags.BoolP("json", "j", false, "Output in JSON format")
+
+	cmd.AddCommand(
+		newLinkageBrokenCommand(),
+		newLinkageConsumersCommand(),
+		newLinkageRebuildCommand(),
+	)
+
+	return cmd
+}
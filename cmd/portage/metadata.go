@@ -111,7 +111,7 @@ func newMetadataCommand() *cobra.Command {
 
 			opts.Verbose = verbose
 
-			pkgs, err := gentoo.ParseMetadataDir(dbPkgsDir, opts)
+			pkgs, err := newVdbScanner(dbPkgsDir, opts).Scan()
 			if err != nil {
 				fmt.Println("ERROR: " + err.Error())
 				os.Exit(1)
@@ -144,6 +144,7 @@ func newMetadataCommand() *cobra.Command {
 							"Bdepend: %s\n"+
 								"Rdepend: %s\n"+
 								"Depend: %s\n"+
+								"Pdepend: %s\n"+
 								"CxxFlags: %s\n"+
 								"Ldflags: %s\n"+
 								"Chost: %s\n"+
@@ -154,6 +155,7 @@ func newMetadataCommand() *cobra.Command {
 							p.BDEPEND,
 							p.RDEPEND,
 							p.DEPEND,
+							p.PDEPEND,
 							p.CxxFlags,
 							p.LdFlags,
 							p.CHost,
@@ -0,0 +1,204 @@
+/*
+
+Copyright (C) 2017-2021  Daniele Rondina <geaaru@sabayonlinux.org>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+*/
+
+package portage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Sabayon/pkgs-checker/pkg/commons"
+	"github.com/Sabayon/pkgs-checker/pkg/gentoo"
+	"github.com/Sabayon/pkgs-checker/pkg/sark"
+
+	"github.com/spf13/cobra"
+	settings "github.com/spf13/viper"
+)
+
+func loadSarkTargets(sarkFiles []string) ([]string, error) {
+	ans := []string{}
+
+	opts := commons.NewHttpClientDefaultOpts()
+	if settings.GetBool("insecure_skipverify") {
+		opts.InsecureSkipVerify = true
+	}
+	apiKey := settings.GetString("apikey")
+
+	for _, s := range sarkFiles {
+		conf, err := sark.NewSarkConfigFromResource(nil, s, apiKey, opts)
+		if err != nil {
+			return nil, err
+		}
+		ans = append(ans, conf.Build.TargetPkgs...)
+	}
+
+	return ans, nil
+}
+
+func newOrphansCommand() *cobra.Command {
+
+	var cmd = &cobra.Command{
+		Use:   "orphans [OPTIONS]",
+		Short: "Show the installed packages not reachable from the world set.",
+		Long: `Show the installed packages not reachable from the world file and
+the world sets through the runtime dependencies (RDEPEND and PDEPEND),
+like a dry-run of emerge --depclean.
+
+The @system set is always part of the roots, like for the @world set of
+portage: its atoms are read from the packages files of the profile.
+The other sets without a file in the sets directory are not resolved
+and the command fails: their atoms must be supplied with the --atom
+option and the set removed from the world_sets file.`,
+		Args: cobra.NoArgs,
+		Example: `
+$> pkgs-checker portage orphans
+
+$> pkgs-checker portage orphans -s build.yaml --skip-system -a sys-apps/baselayout
+`,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			dbPkgsDir, _ := cmd.Flags().GetString("db-pkgs-dir-path")
+			if dbPkgsDir == "" {
+				fmt.Println("Invalid Path of the portage metadata.")
+				os.Exit(1)
+			}
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+
+			dbPkgsDir, _ := cmd.Flags().GetString("db-pkgs-dir-path")
+			worldFile, _ := cmd.Flags().GetString("world")
+			worldSetsFile, _ := cmd.Flags().GetString("world-sets")
+			setsDir, _ := cmd.Flags().GetString("sets-dir")
+			sarkFiles, _ := cmd.Flags().GetStringSlice("sark-files")
+			atoms, _ := cmd.Flags().GetStringSlice("atom")
+			jsonOutput, _ := cmd.Flags().GetBool("json")
+			profile, _ := cmd.Flags().GetString("profile")
+			userProfile, _ := cmd.Flags().GetString("user-profile")
+			skipSystem, _ := cmd.Flags().GetBool("skip-system")
+
+			roots := []string{}
+			builtinSets := make(map[string][]string, 0)
+
+			if !skipSystem {
+				profiles := []string{profile}
+				if _, err := os.Stat(userProfile); err == nil {
+					profiles = append(profiles, userProfile)
+				}
+				system, err := gentoo.LoadSystemSet(profiles)
+				if err != nil {
+					fmt.Println("ERROR: Error on load the @system set: " + err.Error())
+					fmt.Println("Use --profile or --skip-system with the atoms of the set.")
+					os.Exit(1)
+				}
+				builtinSets[gentoo.SystemSetName] = system
+				roots = append(roots, system...)
+			}
+
+			if len(sarkFiles) > 0 {
+				targets, err := loadSarkTargets(sarkFiles)
+				if err != nil {
+					fmt.Println("ERROR: " + err.Error())
+					os.Exit(1)
+				}
+				roots = append(roots, targets...)
+			} else {
+				world, err := gentoo.LoadWorld(worldFile)
+				if err != nil {
+					fmt.Println("ERROR: " + err.Error())
+					os.Exit(1)
+				}
+				sets, err := gentoo.LoadWorldSets(worldSetsFile)
+				if err != nil {
+					fmt.Println("ERROR: " + err.Error())
+					os.Exit(1)
+				}
+				setsAtoms, unknown, err := gentoo.ResolveSets(sets, setsDir, builtinSets)
+				if err != nil {
+					fmt.Println("ERROR: " + err.Error())
+					os.Exit(1)
+				}
+				if len(unknown) > 0 {
+					// The packages of the set would be reported as orphans.
+					fmt.Println(fmt.Sprintf("ERROR: Sets %s not resolved.",
+						strings.Join(unknown, ", ")))
+					os.Exit(1)
+				}
+
+				roots = append(roots, world...)
+				roots = append(roots, setsAtoms...)
+			}
+			roots = append(roots, atoms...)
+
+			if len(roots) == 0 {
+				fmt.Println("ERROR: No root atoms available.")
+				os.Exit(1)
+			}
+
+			g, err := gentoo.BuildDependencyGraph(newVdbScanner(dbPkgsDir, nil),
+				[]string{gentoo.DepKindRdepend, gentoo.DepKindPdepend})
+			if err != nil {
+				fmt.Println("ERROR: " + err.Error())
+				os.Exit(1)
+			}
+
+			report, err := g.GetOrphans(roots)
+			if err != nil {
+				fmt.Println("ERROR: " + err.Error())
+				os.Exit(1)
+			}
+
+			if jsonOutput {
+				data, err := json.Marshal(report)
+				if err != nil {
+					fmt.Println(fmt.Sprintf("Error on convert data to json: %s", err.Error()))
+					os.Exit(1)
+				}
+				fmt.Println(string(data))
+			} else {
+				for _, a := range report.Unresolved {
+					fmt.Fprintf(os.Stderr, "WARNING: atom %s not installed.\n", a)
+				}
+				for _, p := range report.Orphans {
+					fmt.Println(p)
+				}
+			}
+		},
+	}
+
+	var flags = cmd.Flags()
+
+	flags.StringP("db-pkgs-dir-path", "p", "/var/db/pkg",
+		"Path of the portage metadata.")
+	flags.String("world", "/var/lib/portage/world", "Path of the world file.")
+	flags.String("world-sets", "/var/lib/portage/world_sets",
+		"Path of the world_sets file.")
+	flags.String("sets-dir", "/etc/portage/sets", "Directory of the user sets.")
+	flags.StringSliceP("sark-files", "s", []string{},
+		"Use the targets of the sark files as root set instead of the world.")
+	flags.StringSliceP("atom", "a", []string{}, "Additional root atoms.")
+	flags.String("profile", "/etc/portage/make.profile",
+		"Path of the profile with the @system set.")
+	flags.String("user-profile", "/etc/portage/profile",
+		"Path of the user profile applied after the profile when present.")
+	flags.Bool("skip-system", false, "Don't add the @system set to the roots.")
+	flags.BoolP("json", "j", false, "Output in JSON format")
+
+	return cmd
+}
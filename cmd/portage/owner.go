@@ -0,0 +1,178 @@
+/*
+
+Copyright (C) 2017-2021  Daniele Rondina <geaaru@sabayonlinux.org>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+*/
+
+package portage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/Sabayon/pkgs-checker/pkg/gentoo"
+
+	"github.com/spf13/cobra"
+)
+
+type OwnerReportEntry struct {
+	Query  string              `json:"query"`
+	Owners []gentoo.OwnerMatch `json:"owners"`
+}
+
+// loadOwnerIndex returns the index of the vdb updated and saved
+// to the cache file when defined.
+func loadOwnerIndex(dbPkgsDir, cacheFile string) (*gentoo.OwnerIndex, error) {
+	idx := gentoo.NewOwnerIndex()
+	if cacheFile != "" {
+		cached, err := gentoo.LoadOwnerIndex(cacheFile)
+		if err == nil {
+			idx = cached
+		} else if !os.IsNotExist(err) {
+			fmt.Println("WARNING: " + err.Error() + ". I rebuild the index.")
+		}
+	}
+
+	changed, err := idx.Sync(newVdbScanner(dbPkgsDir, nil))
+	if err != nil {
+		return nil, err
+	}
+
+	if cacheFile != "" && changed {
+		err = idx.Save(cacheFile)
+		if err != nil {
+			fmt.Println("WARNING: Error on save index: " + err.Error())
+		}
+	}
+
+	return idx, nil
+}
+
+func newOwnerCommand() *cobra.Command {
+
+	var cmd = &cobra.Command{
+		Use:   "owner <path>... [OPTIONS]",
+		Short: "Find the installed packages that own the files.",
+		Args:  cobra.MinimumNArgs(1),
+		Example: `
+$> pkgs-checker portage owner /usr/bin/python3.9
+
+$> pkgs-checker portage owner libssl.so.1.1 '/usr/lib64/libcrypto*'
+
+$> pkgs-checker portage owner --cache-file /var/cache/pkgs-checker/owner.json /usr/bin/gcc
+`,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			dbPkgsDir, _ := cmd.Flags().GetString("db-pkgs-dir-path")
+			if dbPkgsDir == "" {
+				fmt.Println("Invalid Path of the portage metadata.")
+				os.Exit(1)
+			}
+			basename, _ := cmd.Flags().GetBool("basename")
+			glob, _ := cmd.Flags().GetBool("glob")
+			if basename && glob {
+				fmt.Println("Options --basename and --glob are mutually exclusive.")
+				os.Exit(1)
+			}
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+
+			dbPkgsDir, _ := cmd.Flags().GetString("db-pkgs-dir-path")
+			cacheFile, _ := cmd.Flags().GetString("cache-file")
+			basename, _ := cmd.Flags().GetBool("basename")
+			glob, _ := cmd.Flags().GetBool("glob")
+			jsonOutput, _ := cmd.Flags().GetBool("json")
+
+			idx, err := loadOwnerIndex(dbPkgsDir, cacheFile)
+			if err != nil {
+				fmt.Println("ERROR: " + err.Error())
+				os.Exit(1)
+			}
+
+			report := []OwnerReportEntry{}
+			missing := false
+			for _, q := range args {
+				var mode gentoo.OwnerQueryMode
+				switch {
+				case basename:
+					mode = gentoo.OwnerQueryBasename
+				case glob:
+					mode = gentoo.OwnerQueryGlob
+				default:
+					mode = gentoo.GetOwnerQueryMode(q)
+				}
+
+				if mode == gentoo.OwnerQueryExact && !filepath.IsAbs(q) {
+					q, err = filepath.Abs(q)
+					if err != nil {
+						fmt.Println("ERROR: " + err.Error())
+						os.Exit(1)
+					}
+				}
+
+				owners, err := idx.Query(q, mode)
+				if err != nil {
+					fmt.Println("ERROR: " + err.Error())
+					os.Exit(1)
+				}
+				if len(owners) == 0 {
+					missing = true
+				}
+
+				report = append(report, OwnerReportEntry{
+					Query:  q,
+					Owners: owners,
+				})
+			}
+
+			if jsonOutput {
+				data, err := json.Marshal(report)
+				if err != nil {
+					fmt.Println(fmt.Sprintf("Error on convert data to json: %s", err.Error()))
+					os.Exit(1)
+				}
+				fmt.Println(string(data))
+			} else {
+				for _, e := range report {
+					if len(e.Owners) == 0 {
+						fmt.Fprintf(os.Stderr, "%s: no owner found\n", e.Query)
+						continue
+					}
+					for _, o := range e.Owners {
+						fmt.Println(fmt.Sprintf("%s (%s)", o.Package, o.File))
+					}
+				}
+			}
+
+			if missing {
+				os.Exit(1)
+			}
+		},
+	}
+
+	var flags = cmd.Flags()
+
+	flags.StringP("db-pkgs-dir-path", "p", "/var/db/pkg",
+		"Path of the portage metadata.")
+	flags.String("cache-file", "",
+		"Path of the index cache file. The index is updated on COUNTER changes.")
+	flags.Bool("basename", false, "Match only the name of the files.")
+	flags.Bool("glob", false, "Match the queries as shell patterns.")
+	flags.BoolP("json", "j", false, "Output in JSON format")
+
+	return cmd
+}
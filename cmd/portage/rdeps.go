@@ -0,0 +1,113 @@
+/*
+
+Copyright (C) 2017-2021  Daniele Rondina <geaaru@sabayonlinux.org>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+*/
+
+package portage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Sabayon/pkgs-checker/pkg/gentoo"
+
+	"github.com/spf13/cobra"
+)
+
+func newRdepsCommand() *cobra.Command {
+
+	var cmd = &cobra.Command{
+		Use:   "rdeps <atom> [OPTIONS]",
+		Short: "Show the installed packages that depend on a package.",
+		Args:  cobra.ExactArgs(1),
+		Example: `
+$> pkgs-checker portage rdeps dev-libs/openssl:0
+
+$> pkgs-checker portage rdeps -t --dot dev-lang/python:3.9 | dot -Tsvg > rdeps.svg
+`,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			dbPkgsDir, _ := cmd.Flags().GetString("db-pkgs-dir-path")
+			if dbPkgsDir == "" {
+				fmt.Println("Invalid Path of the portage metadata.")
+				os.Exit(1)
+			}
+			jsonOutput, _ := cmd.Flags().GetBool("json")
+			dotOutput, _ := cmd.Flags().GetBool("dot")
+			if jsonOutput && dotOutput {
+				fmt.Println("Options --json and --dot are mutually exclusive.")
+				os.Exit(1)
+			}
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+
+			dbPkgsDir, _ := cmd.Flags().GetString("db-pkgs-dir-path")
+			transitive, _ := cmd.Flags().GetBool("transitive")
+			kinds, _ := cmd.Flags().GetStringSlice("dep-types")
+			jsonOutput, _ := cmd.Flags().GetBool("json")
+			dotOutput, _ := cmd.Flags().GetBool("dot")
+
+			for i, k := range kinds {
+				kinds[i] = strings.ToUpper(k)
+			}
+
+			g, err := gentoo.BuildDependencyGraph(newVdbScanner(dbPkgsDir, nil), kinds)
+			if err != nil {
+				fmt.Println("ERROR: " + err.Error())
+				os.Exit(1)
+			}
+
+			rdeps, err := g.GetReverseDeps(args[0], transitive)
+			if err != nil {
+				fmt.Println("ERROR: " + err.Error())
+				os.Exit(1)
+			}
+
+			if jsonOutput {
+				data, err := json.Marshal(rdeps)
+				if err != nil {
+					fmt.Println(fmt.Sprintf("Error on convert data to json: %s", err.Error()))
+					os.Exit(1)
+				}
+				fmt.Println(string(data))
+			} else if dotOutput {
+				fmt.Print(rdeps.Dot())
+			} else {
+				for _, p := range rdeps.GetPackages() {
+					if transitive {
+						fmt.Println(fmt.Sprintf("%s (depth %d)", p, rdeps.Packages[p]))
+					} else {
+						fmt.Println(p)
+					}
+				}
+			}
+		},
+	}
+
+	var flags = cmd.Flags()
+
+	flags.StringP("db-pkgs-dir-path", "p", "/var/db/pkg",
+		"Path of the portage metadata.")
+	flags.BoolP("transitive", "t", false, "Show the transitive reverse dependencies.")
+	flags.StringSlice("dep-types", gentoo.DefaultDepKinds,
+		"Dependency types to check (RDEPEND, DEPEND, BDEPEND, PDEPEND).")
+	flags.Bool("dot", false, "Output the graph in DOT format")
+	flags.BoolP("json", "j", false, "Output in JSON format")
+
+	return cmd
+}
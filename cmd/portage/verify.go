@@ -0,0 +1,180 @@
+/*
+
+Copyright (C) 2017-2021  Daniele Rondina <geaaru@sabayonlinux.org>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+*/
+
+package portage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/Sabayon/pkgs-checker/pkg/gentoo"
+
+	"github.com/spf13/cobra"
+)
+
+type VerifyReportEntry struct {
+	Package string                       `json:"package"`
+	Files   int                          `json:"files"`
+	Issues  []gentoo.ContentsVerifyIssue `json:"issues"`
+}
+
+func newVerifyCommand() *cobra.Command {
+
+	var cmd = &cobra.Command{
+		Use:   "verify [cat/pkg[:slot]...] [OPTIONS]",
+		Short: "Verify the installed files of the packages.",
+		Args:  cobra.ArbitraryArgs,
+		Example: `
+$> pkgs-checker portage verify sys-apps/portage
+
+$> pkgs-checker portage verify --root /tmp/image -p /tmp/image/var/db/pkg --ignore-mtime
+`,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			dbPkgsDir, _ := cmd.Flags().GetString("db-pkgs-dir-path")
+			if dbPkgsDir == "" {
+				fmt.Println("Invalid Path of the portage metadata.")
+				os.Exit(1)
+			}
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+
+			dbPkgsDir, _ := cmd.Flags().GetString("db-pkgs-dir-path")
+			root, _ := cmd.Flags().GetString("root")
+			jsonOutput, _ := cmd.Flags().GetBool("json")
+			ignoreMtime, _ := cmd.Flags().GetBool("ignore-mtime")
+			ignoreMd5, _ := cmd.Flags().GetBool("ignore-md5")
+			onlyIssues, _ := cmd.Flags().GetBool("only-issues")
+
+			// The atoms without slot match all the slots of the package.
+			opts := &gentoo.PortageUseParseOpts{}
+			atoms := []*gentoo.GentooPackage{}
+			for _, pkg := range args {
+				gp, err := gentoo.ParseAtom(pkg)
+				if err != nil {
+					fmt.Println(fmt.Sprintf("Invalid pkg %s: %s",
+						pkg, err.Error()))
+					os.Exit(1)
+				}
+
+				atoms = append(atoms, gp)
+				opts.AddCategory(gp.Category)
+			}
+			matched := make([]bool, len(atoms))
+
+			verifyOpts := &gentoo.ContentsVerifyOpts{
+				IgnoreMtime: ignoreMtime,
+				IgnoreMd5:   ignoreMd5,
+			}
+
+			report := []VerifyReportEntry{}
+			nIssues := 0
+			fields := append([]gentoo.PortageMetaField{gentoo.MetaFieldContents},
+				gentoo.UsePortageMetaFields...)
+			scanner := newVdbScanner(dbPkgsDir, opts, fields...)
+			err := scanner.Walk(func(p *gentoo.PortageMetaData) error {
+				admitted := len(atoms) == 0
+				for idx, a := range atoms {
+					if a.Category != p.Category || a.Name != p.Name {
+						continue
+					}
+					ok, err := a.Admit(p.GentooPackage)
+					if err != nil {
+						return err
+					}
+					if ok {
+						matched[idx] = true
+						admitted = true
+					}
+				}
+				if !admitted {
+					return nil
+				}
+
+				issues, err := p.VerifyContents(root, verifyOpts)
+				if err != nil {
+					return err
+				}
+
+				nIssues += len(issues)
+				if len(issues) == 0 && onlyIssues {
+					return nil
+				}
+
+				report = append(report, VerifyReportEntry{
+					Package: p.GetPackageNameWithVersion(),
+					Files:   len(p.CONTENTS),
+					Issues:  issues,
+				})
+				return nil
+			})
+			if err != nil {
+				fmt.Println("ERROR: " + err.Error())
+				os.Exit(1)
+			}
+
+			sort.Slice(report, func(i, j int) bool {
+				return report[i].Package < report[j].Package
+			})
+
+			if jsonOutput {
+				data, err := json.Marshal(report)
+				if err != nil {
+					fmt.Println(fmt.Sprintf("Error on convert data to json: %s", err.Error()))
+					os.Exit(1)
+				}
+				fmt.Println(string(data))
+			} else {
+				for _, e := range report {
+					fmt.Println(fmt.Sprintf("Checking %s: %d files, %d issues",
+						e.Package, e.Files, len(e.Issues)))
+					for _, i := range e.Issues {
+						fmt.Println("  " + i.String())
+					}
+				}
+			}
+
+			// A missing package must not be reported as verified.
+			for idx, m := range matched {
+				if !m {
+					fmt.Fprintln(os.Stderr, fmt.Sprintf(
+						"ERROR: No installed packages match %s.", args[idx]))
+					nIssues++
+				}
+			}
+
+			if nIssues > 0 {
+				os.Exit(1)
+			}
+		},
+	}
+
+	var flags = cmd.Flags()
+
+	flags.StringP("db-pkgs-dir-path", "p", "/var/db/pkg",
+		"Path of the portage metadata.")
+	flags.StringP("root", "r", "/", "Root directory of the installed files.")
+	flags.Bool("ignore-mtime", false, "Ignore the mtime changes.")
+	flags.Bool("ignore-md5", false, "Ignore the MD5 changes.")
+	flags.Bool("only-issues", false, "Show only the packages with issues.")
+	flags.BoolP("json", "j", false, "Output in JSON format")
+
+	return cmd
+}
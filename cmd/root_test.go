@@ -0,0 +1,52 @@
+/*
+
+Copyright (C) 2017-2018  Daniele Rondina <geaaru@sabayonlinux.org>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Commands", func() {
+
+	Context("Flags", func() {
+		It("Don't redefine the persistent flags", func() {
+			var walk func(c *cobra.Command)
+			walk = func(c *cobra.Command) {
+				// Merging the persistent flags panics on shorthand redefinition.
+				Expect(func() { c.InheritedFlags() }).ShouldNot(Panic(), c.CommandPath())
+				Expect(func() { c.LocalFlags() }).ShouldNot(Panic(), c.CommandPath())
+				for _, sub := range c.Commands() {
+					walk(sub)
+				}
+			}
+			walk(rootCmd)
+		})
+
+		It("Build the pkg inspect command", func() {
+			c, _, err := rootCmd.Find([]string{"pkg", "inspect"})
+			Expect(err).Should(BeNil())
+			Expect(c.Name()).Should(Equal("inspect"))
+			Expect(c.Flags().Lookup("list")).ShouldNot(BeNil())
+			Expect(c.Flags().Lookup("logfile").Shorthand).Should(Equal("l"))
+		})
+	})
+})
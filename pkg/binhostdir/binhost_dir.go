@@ -28,12 +28,17 @@ import (
 	"regexp"
 
 	logger "github.com/sirupsen/logrus"
+
+	"github.com/Sabayon/pkgs-checker/pkg/gentoo"
 )
 
 const (
 	RegexCatString = `(^[a-z]+[0-9]*[a-z]*[-][a-z]+[0-9]*[a-z]*$|virtual)`
 )
 
+// ProcessCategoryDir stores the binary packages of the category
+// directory in the tree. The binary packages of the binpkg-multi-instance
+// layout (<category>/<pn>/<pf>-<buildid>.<ext>) are stored too.
 func ProcessCategoryDir(dir string, log *logger.Logger, tree *map[string][]string) error {
 	var files []os.FileInfo
 	var pkgFiles []string = make([]string, 0)
@@ -46,7 +51,6 @@ func ProcessCategoryDir(dir string, log *logger.Logger, tree *map[string][]strin
 			dir, err.Error()))
 	}
 
-	var regexCat = regexp.MustCompile(`.tbz2$`)
 	for _, file := range files {
 		log.WithFields(logger.Fields{
 			"file":     file.Name(),
@@ -54,11 +58,16 @@ func ProcessCategoryDir(dir string, log *logger.Logger, tree *map[string][]strin
 		}).Debugf("Processing file...")
 
 		if file.IsDir() {
+			pnFiles, err := getBinPkgFiles(path.Join(dir, file.Name()))
+			if err != nil {
+				return err
+			}
+			pkgFiles = append(pkgFiles, pnFiles...)
 			continue
 		}
 
-		// Check only directory of categories.
-		if !regexCat.MatchString(file.Name()) {
+		// Check only the binary packages (.tbz2 and .gpkg.tar).
+		if !gentoo.IsBinPkg(file.Name()) {
 			log.WithFields(logger.Fields{
 				"file":     file.Name(),
 				"category": cat,
@@ -82,6 +91,26 @@ func ProcessCategoryDir(dir string, log *logger.Logger, tree *map[string][]strin
 	return nil
 }
 
+// getBinPkgFiles returns the binary packages of the directory of
+// a package.
+func getBinPkgFiles(dir string) ([]string, error) {
+	ans := make([]string, 0)
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return ans, errors.New(fmt.Sprintf("Error on read directory %s: %s",
+			dir, err.Error()))
+	}
+
+	for _, file := range files {
+		if !file.IsDir() && gentoo.IsBinPkg(file.Name()) {
+			ans = append(ans, path.Join(dir, file.Name()))
+		}
+	}
+
+	return ans, nil
+}
+
 // Parse binhost Directory
 func AnalyzeBinHostDirectory(binhostDir string, log *logger.Logger, tree *map[string][]string) error {
 	var err error
@@ -0,0 +1,82 @@
+/*
+
+Copyright (C) 2017-2019  Daniele Rondina <geaaru@sabayonlinux.org>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package binhostdir_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/Sabayon/pkgs-checker/pkg/binhostdir"
+
+	logger "github.com/sirupsen/logrus"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Binhost Dir", func() {
+
+	Context("Check category directory", func() {
+
+		It("Mixed tbz2 and gpkg packages", func() {
+			tmpdir, err := ioutil.TempDir("", "binhost")
+			Expect(err).Should(BeNil())
+			defer os.RemoveAll(tmpdir)
+
+			catDir := filepath.Join(tmpdir, "app-misc")
+			Expect(os.MkdirAll(catDir, 0755)).Should(BeNil())
+			for _, f := range []string{"foo-1.0.tbz2", "bar-2.0.gpkg.tar", "Packages", "baz-1.0.tar"} {
+				Expect(ioutil.WriteFile(filepath.Join(catDir, f), []byte{}, 0644)).Should(BeNil())
+			}
+
+			tree := make(map[string][]string, 0)
+			Expect(ProcessCategoryDir(catDir, logger.StandardLogger(), &tree)).Should(BeNil())
+			Expect(tree).Should(Equal(map[string][]string{
+				"app-misc": []string{
+					filepath.Join(catDir, "bar-2.0.gpkg.tar"),
+					filepath.Join(catDir, "foo-1.0.tbz2"),
+				},
+			}))
+		})
+
+		It("Multi-instance packages", func() {
+			tmpdir, err := ioutil.TempDir("", "binhost")
+			Expect(err).Should(BeNil())
+			defer os.RemoveAll(tmpdir)
+
+			catDir := filepath.Join(tmpdir, "app-misc")
+			Expect(os.MkdirAll(filepath.Join(catDir, "foo"), 0755)).Should(BeNil())
+			for _, f := range []string{"foo/foo-1.0-1.gpkg.tar", "foo/foo-1.0-2.xpak", "foo/Manifest", "bar-2.0.tbz2"} {
+				Expect(ioutil.WriteFile(filepath.Join(catDir, f), []byte{}, 0644)).Should(BeNil())
+			}
+
+			tree := make(map[string][]string, 0)
+			Expect(ProcessCategoryDir(catDir, logger.StandardLogger(), &tree)).Should(BeNil())
+			Expect(tree).Should(Equal(map[string][]string{
+				"app-misc": []string{
+					filepath.Join(catDir, "bar-2.0.tbz2"),
+					filepath.Join(catDir, "foo", "foo-1.0-1.gpkg.tar"),
+					filepath.Join(catDir, "foo", "foo-1.0-2.xpak"),
+				},
+			}))
+		})
+	})
+
+})
@@ -0,0 +1,272 @@
+/*
+
+Copyright (C) 2017-2021  Daniele Rondina <geaaru@sabayonlinux.org>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+*/
+
+package compress
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+const (
+	// Bytes read to detect the codec of a stream.
+	MagicLen = 8
+)
+
+// Decompressor is a codec used to read the compressed binary packages
+// (BINPKG_COMPRESS). The codecs are detected by the magic bytes of the
+// stream.
+type Decompressor interface {
+	GetName() string
+	Match(magic []byte) bool
+	NewReader(r io.Reader) (io.ReadCloser, error)
+}
+
+var (
+	registry      []Decompressor
+	registryMutex sync.RWMutex
+)
+
+func init() {
+	Register(NewBzip2Decompressor())
+	Register(NewGzipDecompressor())
+	Register(NewCommandDecompressor("xz", []byte{0xfd, '7', 'z', 'X', 'Z', 0x00},
+		[]string{"xz", "-dc", "-T0"}))
+	Register(NewCommandDecompressor("zstd", []byte{0x28, 0xb5, 0x2f, 0xfd},
+		[]string{"zstd", "-dc"}))
+	Register(NewCommandDecompressor("lz4", []byte{0x04, 0x22, 0x4d, 0x18},
+		[]string{"lz4", "-dc"}))
+}
+
+// Register adds a codec to the registry. The codec replaces a codec
+// with the same name.
+func Register(d Decompressor) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	for i, r := range registry {
+		if r.GetName() == d.GetName() {
+			registry[i] = d
+			return
+		}
+	}
+	registry = append(registry, d)
+}
+
+// GetDecompressors returns the registered codecs.
+func GetDecompressors() []Decompressor {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	return append([]Decompressor{}, registry...)
+}
+
+// Detect returns the codec of the magic bytes or nil.
+func Detect(magic []byte) Decompressor {
+	for _, d := range GetDecompressors() {
+		if d.Match(magic) {
+			return d
+		}
+	}
+	return nil
+}
+
+// NewReader returns a reader of the decompressed content and the name
+// of the codec detected. The streams without a known magic are returned
+// as is with an empty codec name.
+func NewReader(r io.Reader) (io.ReadCloser, string, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(MagicLen)
+	if err != nil && err != io.EOF {
+		return nil, "", err
+	}
+
+	d := Detect(magic)
+	if d == nil {
+		return ioutil.NopCloser(br), "", nil
+	}
+
+	ans, err := d.NewReader(br)
+	if err != nil {
+		return nil, "", errors.New(
+			fmt.Sprintf("Error on create %s reader: %s", d.GetName(), err.Error()))
+	}
+	return ans, d.GetName(), nil
+}
+
+// Bzip2Decompressor uses the parallel decoders lbzip2 or pbzip2 when
+// available and compress/bzip2 otherwise.
+type Bzip2Decompressor struct {
+	Commands [][]string
+}
+
+func NewBzip2Decompressor() *Bzip2Decompressor {
+	return &Bzip2Decompressor{
+		Commands: [][]string{
+			{"lbzip2", "-dc"},
+			{"pbzip2", "-dc"},
+		},
+	}
+}
+
+func (d *Bzip2Decompressor) GetName() string { return "bzip2" }
+
+func (d *Bzip2Decompressor) Match(magic []byte) bool {
+	return bytes.HasPrefix(magic, []byte("BZh"))
+}
+
+func (d *Bzip2Decompressor) NewReader(r io.Reader) (io.ReadCloser, error) {
+	for _, c := range d.Commands {
+		if isCommandAvailable(c[0]) {
+			return NewCommandReader(r, c[0], c[1:]...)
+		}
+	}
+	return ioutil.NopCloser(bzip2.NewReader(r)), nil
+}
+
+type GzipDecompressor struct{}
+
+func NewGzipDecompressor() *GzipDecompressor { return &GzipDecompressor{} }
+
+func (d *GzipDecompressor) GetName() string { return "gzip" }
+
+func (d *GzipDecompressor) Match(magic []byte) bool {
+	return bytes.HasPrefix(magic, []byte{0x1f, 0x8b})
+}
+
+func (d *GzipDecompressor) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+// CommandDecompressor handles the codecs without a decoder in the
+// standard library with the tools used by Portage.
+type CommandDecompressor struct {
+	Name    string
+	Magic   []byte
+	Command []string
+}
+
+func NewCommandDecompressor(name string, magic []byte, command []string) *CommandDecompressor {
+	return &CommandDecompressor{
+		Name:    name,
+		Magic:   magic,
+		Command: command,
+	}
+}
+
+func (d *CommandDecompressor) GetName() string { return d.Name }
+
+func (d *CommandDecompressor) Match(magic []byte) bool {
+	return bytes.HasPrefix(magic, d.Magic)
+}
+
+func (d *CommandDecompressor) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return NewCommandReader(r, d.Command[0], d.Command[1:]...)
+}
+
+var (
+	commandsAvailable = make(map[string]bool, 0)
+	commandsMutex     sync.Mutex
+)
+
+func isCommandAvailable(name string) bool {
+	commandsMutex.Lock()
+	defer commandsMutex.Unlock()
+
+	ans, ok := commandsAvailable[name]
+	if !ok {
+		_, err := exec.LookPath(name)
+		ans = err == nil
+		commandsAvailable[name] = ans
+	}
+	return ans
+}
+
+type commandReader struct {
+	cmd    *exec.Cmd
+	out    io.ReadCloser
+	stderr bytes.Buffer
+	done   bool
+	err    error
+}
+
+// NewCommandReader returns the output of the command executed with
+// the reader as standard input.
+func NewCommandReader(r io.Reader, name string, args ...string) (io.ReadCloser, error) {
+	ans := &commandReader{
+		cmd: exec.Command(name, args...),
+	}
+	ans.cmd.Stdin = r
+	ans.cmd.Stderr = &ans.stderr
+
+	out, err := ans.cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	ans.out = out
+
+	err = ans.cmd.Start()
+	if err != nil {
+		return nil, errors.New(
+			fmt.Sprintf("Error on execute %s: %s", name, err.Error()))
+	}
+
+	return ans, nil
+}
+
+func (c *commandReader) wait() error {
+	if !c.done {
+		c.done = true
+		err := c.cmd.Wait()
+		if err != nil {
+			c.err = errors.New(
+				fmt.Sprintf("Error on execute %s: %s %s", c.cmd.Path,
+					err.Error(), strings.TrimSpace(c.stderr.String())))
+		}
+	}
+	return c.err
+}
+
+func (c *commandReader) Read(p []byte) (int, error) {
+	n, err := c.out.Read(p)
+	if err == io.EOF {
+		if werr := c.wait(); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+// Close stops the command if the output is not completely read.
+func (c *commandReader) Close() error {
+	if !c.done {
+		c.cmd.Process.Kill()
+		c.wait()
+	}
+	return nil
+}
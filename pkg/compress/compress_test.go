@@ -0,0 +1,158 @@
+/*
+
+Copyright (C) 2017-2021  Daniele Rondina <geaaru@sabayonlinux.org>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+*/
+
+package compress_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os/exec"
+
+	. "github.com/Sabayon/pkgs-checker/pkg/compress"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+type upperDecompressor struct{}
+
+func (d *upperDecompressor) GetName() string { return "upper" }
+
+func (d *upperDecompressor) Match(magic []byte) bool {
+	return bytes.HasPrefix(magic, []byte("UPPER"))
+}
+
+func (d *upperDecompressor) NewReader(r io.Reader) (io.ReadCloser, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(bytes.NewReader(bytes.ToLower(data[5:]))), nil
+}
+
+func readAll(r io.Reader) (string, string) {
+	rc, codec, err := NewReader(r)
+	Expect(err).Should(BeNil())
+	defer rc.Close()
+	data, err := ioutil.ReadAll(rc)
+	Expect(err).Should(BeNil())
+	return string(data), codec
+}
+
+var _ = Describe("Compress", func() {
+
+	content := []byte("binary package content\n")
+
+	DescribeTable("Check codec detection",
+		func(magic []byte, codec string) {
+			d := Detect(magic)
+			if codec == "" {
+				Expect(d).Should(BeNil())
+			} else {
+				Expect(d).ShouldNot(BeNil())
+				Expect(d.GetName()).Should(Equal(codec))
+			}
+		},
+		Entry("bzip2", []byte("BZh91AY&"), "bzip2"),
+		Entry("gzip", []byte{0x1f, 0x8b, 0x08, 0x00}, "gzip"),
+		Entry("xz", []byte{0xfd, '7', 'z', 'X', 'Z', 0x00, 0x00, 0x04}, "xz"),
+		Entry("zstd", []byte{0x28, 0xb5, 0x2f, 0xfd, 0x04}, "zstd"),
+		Entry("lz4", []byte{0x04, 0x22, 0x4d, 0x18, 0x64}, "lz4"),
+		Entry("tar", []byte("usr/bin/"), ""),
+		Entry("short", []byte{0x1f}, ""),
+	)
+
+	It("Check uncompressed data", func() {
+		data, codec := readAll(bytes.NewReader(content))
+		Expect(data).Should(Equal(string(content)))
+		Expect(codec).Should(Equal(""))
+	})
+
+	It("Check gzip data", func() {
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		w.Write(content)
+		w.Close()
+
+		data, codec := readAll(&buf)
+		Expect(data).Should(Equal(string(content)))
+		Expect(codec).Should(Equal("gzip"))
+	})
+
+	DescribeTable("Check data compressed with the external tools",
+		func(tool, codec string) {
+			if _, err := exec.LookPath(tool); err != nil {
+				Skip(tool + " not available")
+			}
+
+			cmd := exec.Command(tool, "-c")
+			cmd.Stdin = bytes.NewReader(content)
+			compressed, err := cmd.Output()
+			Expect(err).Should(BeNil())
+
+			data, c := readAll(bytes.NewReader(compressed))
+			Expect(data).Should(Equal(string(content)))
+			Expect(c).Should(Equal(codec))
+		},
+		Entry("bzip2", "bzip2", "bzip2"),
+		Entry("xz", "xz", "xz"),
+		Entry("zstd", "zstd", "zstd"),
+		Entry("lz4", "lz4", "lz4"),
+	)
+
+	It("Check bzip2 without parallel decoders", func() {
+		if _, err := exec.LookPath("bzip2"); err != nil {
+			Skip("bzip2 not available")
+		}
+		cmd := exec.Command("bzip2", "-c")
+		cmd.Stdin = bytes.NewReader(content)
+		compressed, err := cmd.Output()
+		Expect(err).Should(BeNil())
+
+		d := &Bzip2Decompressor{}
+		r, err := d.NewReader(bytes.NewReader(compressed))
+		Expect(err).Should(BeNil())
+		data, err := ioutil.ReadAll(r)
+		Expect(err).Should(BeNil())
+		Expect(data).Should(Equal(content))
+	})
+
+	It("Check corrupted data", func() {
+		if _, err := exec.LookPath("xz"); err != nil {
+			Skip("xz not available")
+		}
+		magic := []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+		rc, _, err := NewReader(bytes.NewReader(append(magic, content...)))
+		Expect(err).Should(BeNil())
+		_, err = ioutil.ReadAll(rc)
+		Expect(err).ShouldNot(BeNil())
+		Expect(rc.Close()).Should(BeNil())
+	})
+
+	It("Check custom codec", func() {
+		Register(&upperDecompressor{})
+		data, codec := readAll(bytes.NewReader([]byte("UPPERHELLO")))
+		Expect(data).Should(Equal("hello"))
+		Expect(codec).Should(Equal("upper"))
+	})
+
+})
@@ -377,7 +377,7 @@ func RetrieveRepoPackages(dbpath string) ([]*EntropyPackage, error) {
 	db.SetMaxOpenConns(1)
 
 	listDbPkgQuery := `
-SELECT atom,slot
+SELECT atom,slot,license
 FROM baseinfo`
 
 	rows, err := db.Query(listDbPkgQuery)
@@ -386,9 +386,9 @@ FROM baseinfo`
 	}
 	defer rows.Close()
 
-	var atom, slot string
+	var atom, slot, license string
 	for rows.Next() {
-		err = rows.Scan(&atom, &slot)
+		err = rows.Scan(&atom, &slot, &license)
 		if err != nil {
 			return ans, errors.New("Error on parse row for retrieve data: " + err.Error())
 		}
@@ -398,6 +398,7 @@ FROM baseinfo`
 			return ans, errors.New("Error on parse atom " + atom + ": " + err.Error())
 		}
 		pkg.Slot = slot
+		pkg.License = license
 
 		ans = append(ans, pkg)
 	}
@@ -62,6 +62,8 @@ type FilterMatrixBranch struct {
 	Matches map[string]*FilterMatrixLeaf
 	// The key of the map contains file path
 	NotMatches map[string]*FilterMatrixLeaf
+	// The key of the map contains file path
+	Blocked map[string]*FilterMatrixLeaf
 }
 
 type FilterMatrixLeaf struct {
@@ -124,6 +126,7 @@ func NewFilterMatrixBranch(category string) (*FilterMatrixBranch, error) {
 		Packages:         make([]*gentoo.GentooPackage, 0),
 		Matches:          make(map[string]*FilterMatrixLeaf, 0),
 		NotMatches:       make(map[string]*FilterMatrixLeaf, 0),
+		Blocked:          make(map[string]*FilterMatrixLeaf, 0),
 	}, nil
 }
 
@@ -146,14 +149,15 @@ func (b *FilterMatrixBranch) ContainsResource(resource *FilterResource) (bool, e
 
 func (b *FilterMatrixBranch) CheckPackages(files []string) error {
 	var admitted bool
+	var blocked bool
 	var hasPkgRule bool
 
 	for _, f := range files {
 		admitted = false
+		blocked = false
 		hasPkgRule = false
 
-		pkgname := filepath.Base(f)
-		pkgname = pkgname[:strings.Index(pkgname, filepath.Ext(pkgname))]
+		pkgname := gentoo.GetBinPkgName(f)
 
 		gentooPkg, err := gentoo.ParsePackageStr(
 			fmt.Sprintf("%s/%s", b.Category, pkgname))
@@ -161,9 +165,31 @@ func (b *FilterMatrixBranch) CheckPackages(files []string) error {
 			return err
 		}
 
+		// The blockers exclude the packages in both whitelist and
+		// blacklist and they are not rules that admit packages.
+		for _, pkg := range b.Packages {
+			if pkg.Name == gentooPkg.Name && pkg.IsBlocker() {
+				blocked, err = pkg.Blocks(gentooPkg)
+				if err != nil {
+					return err
+				}
+				if blocked {
+					break
+				}
+			}
+		}
+
+		if blocked {
+			_, err = b.AddBlockedPackage(f)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
 		// TODO: replace packages with a map
 		for _, pkg := range b.Packages {
-			if pkg.Name == gentooPkg.Name {
+			if pkg.Name == gentooPkg.Name && !pkg.IsBlocker() {
 				hasPkgRule = true
 				admitted, err = pkg.Admit(gentooPkg)
 				if err != nil {
@@ -216,9 +242,8 @@ func (b *FilterMatrixBranch) AddResource(resource *FilterResource) error {
 	return nil
 }
 
-func (b *FilterMatrixBranch) AddPackage(file string, match bool) (*FilterMatrixLeaf, error) {
-	pkgname := filepath.Base(file)
-	pkgname = pkgname[:strings.Index(pkgname, filepath.Ext(pkgname))]
+func (b *FilterMatrixBranch) newLeaf(file string) (*FilterMatrixLeaf, error) {
+	pkgname := gentoo.GetBinPkgName(file)
 
 	gentooPkg, err := gentoo.ParsePackageStr(
 		fmt.Sprintf("%s/%s", b.Category, pkgname))
@@ -226,12 +251,33 @@ func (b *FilterMatrixBranch) AddPackage(file string, match bool) (*FilterMatrixL
 		return nil, err
 	}
 
-	leaf := &FilterMatrixLeaf{
+	return &FilterMatrixLeaf{
 		Name:    gentooPkg.Name,
 		Path:    file,
 		Package: gentooPkg,
 		Father:  b,
+	}, nil
+}
+
+func (b *FilterMatrixBranch) AddBlockedPackage(file string) (*FilterMatrixLeaf, error) {
+	leaf, err := b.newLeaf(file)
+	if err != nil {
+		return nil, err
+	}
+
+	b.Blocked[file] = leaf
+	b.Matrix.Log(logger.DebugLevel, "Branch %s: Add blocked package %s (%s)",
+		b.Category, leaf.Package, leaf.Path)
+
+	return leaf, nil
+}
+
+func (b *FilterMatrixBranch) AddPackage(file string, match bool) (*FilterMatrixLeaf, error) {
+	leaf, err := b.newLeaf(file)
+	if err != nil {
+		return nil, err
 	}
+	gentooPkg := leaf.Package
 
 	if match {
 		b.Matches[file] = leaf
@@ -546,6 +592,30 @@ func (m *FilterMatrix) GetNotMatchesFiles() []string {
 	return ans
 }
 
+func (m *FilterMatrix) GetBlocked() []*FilterMatrixLeaf {
+	ans := make([]*FilterMatrixLeaf, 0)
+
+	for _, branch := range m.Branches {
+		for _, blocked := range branch.Blocked {
+			ans = append(ans, blocked)
+		}
+	}
+
+	return ans
+}
+
+func (m *FilterMatrix) GetBlockedFiles() []string {
+	ans := make([]string, 0)
+
+	for _, branch := range m.Branches {
+		for _, blocked := range branch.Blocked {
+			ans = append(ans, (*blocked).Path)
+		}
+	}
+
+	return ans
+}
+
 func (m *FilterMatrix) CheckMatches(binhost map[string][]string) error {
 	for category, pkgs := range binhost {
 
@@ -652,6 +722,9 @@ func (f *Filter) Run(binhostDir string) error {
 	notMatches := f.RulesTree.GetNotMatches()
 	f.logger.Infof("Not matches packages found %d.", len(notMatches))
 
+	blocked := f.RulesTree.GetBlocked()
+	f.logger.Infof("Blocked packages found %d.", len(blocked))
+
 	// Write report
 	if f.settings.GetString("report-prefix-path") != "" {
 		report, err := NewFilterReport(f.RulesTree.FilterType)
@@ -660,6 +733,7 @@ func (f *Filter) Run(binhostDir string) error {
 		}
 		report.Matches = f.RulesTree.GetMatchesFiles()
 		report.NotMatches = f.RulesTree.GetNotMatchesFiles()
+		report.Blocked = f.RulesTree.GetBlockedFiles()
 		err = report.WriteReport(f.settings.GetString("report-prefix-path"))
 		if err != nil {
 			return err
@@ -679,6 +753,14 @@ func (f *Filter) Run(binhostDir string) error {
 		if err != nil {
 			return err
 		}
+
+		// POST: Blocked packages are always removed
+		if len(blocked) > 0 {
+			err = f.unlinkFiles(blocked)
+			if err != nil {
+				return err
+			}
+		}
 	}
 
 	return nil
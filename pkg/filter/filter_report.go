@@ -36,6 +36,7 @@ type FilterReport struct {
 	FilterType string   `json:"filter_type,omitempty"`
 	Matches    []string `json:"matches,omitempty"`
 	NotMatches []string `json:"not_matches,omitempty"`
+	Blocked    []string `json:"blocked,omitempty"`
 }
 
 func NewFilterReport(filterType string) (*FilterReport, error) {
@@ -47,6 +48,7 @@ func NewFilterReport(filterType string) (*FilterReport, error) {
 		FilterType: filterType,
 		Matches:    make([]string, 0),
 		NotMatches: make([]string, 0),
+		Blocked:    make([]string, 0),
 	}
 
 	return ans, nil
@@ -315,4 +315,76 @@ var _ = Describe("FilterRule", func() {
 		})
 	})
 
+	// Check filter with blockers
+	Describe("NewFilterMatrix with blockers", func() {
+
+		matrix, _ := NewFilterMatrix("whitelist")
+
+		pkgs := []string{"net-libs/gnutls", "!<net-libs/gnutls-3.0", "!net-libs/nodejs"}
+		resource, _ := NewFilterResource("test", "buildfile", pkgs, []string{"net-libs"})
+		matrix.AddResource(resource)
+
+		binHostTree := make(map[string][]string, 1)
+		binHostTree["net-libs"] = []string{
+			"/tmp/net-libs/gnutls-1.1.1.tbz2",
+			"/tmp/net-libs/gnutls-3.6.15.tbz2",
+			"/tmp/net-libs/nodejs-9.11.1.tbz2",
+			"/tmp/net-libs/libssh-0.9.5.tbz2",
+		}
+
+		err := matrix.CreateBranches()
+		It("Check error", func() {
+			Expect(err).Should(BeNil())
+		})
+
+		err = matrix.CheckMatches(binHostTree)
+		It("Check matches", func() {
+			Expect(err).Should(BeNil())
+			Expect(len(matrix.GetMatches())).Should(Equal(2))
+			Expect(len(matrix.GetNotMatches())).Should(Equal(0))
+		})
+
+		It("Check blocked", func() {
+			Expect(len(matrix.GetBlocked())).Should(Equal(2))
+			b := matrix.Branches["net-libs"]
+			Expect(b.Blocked).Should(HaveKey("/tmp/net-libs/gnutls-1.1.1.tbz2"))
+			Expect(b.Blocked).Should(HaveKey("/tmp/net-libs/nodejs-9.11.1.tbz2"))
+			Expect(b.Matches).Should(HaveKey("/tmp/net-libs/gnutls-3.6.15.tbz2"))
+			Expect(b.Matches).Should(HaveKey("/tmp/net-libs/libssh-0.9.5.tbz2"))
+		})
+	})
+
+	// Check filter with the binpkg-multi-instance packages
+	Describe("NewFilterMatrix with multi-instance packages", func() {
+
+		matrix, _ := NewFilterMatrix("whitelist")
+
+		pkgs := []string{">=net-libs/gnutls-3.0", "net-libs/nodejs"}
+		resource, _ := NewFilterResource("test", "buildfile", pkgs, []string{})
+		matrix.AddResource(resource)
+
+		binHostTree := make(map[string][]string, 1)
+		binHostTree["net-libs"] = []string{
+			"/tmp/net-libs/gnutls-1.1.1-1.gpkg.tar",
+			"/tmp/net-libs/gnutls/gnutls-3.6.15-2.gpkg.tar",
+			"/tmp/net-libs/nodejs/nodejs-9.11.1-1.xpak",
+			"/tmp/net-libs/libssh-0.9.5.tbz2",
+		}
+
+		err := matrix.CreateBranches()
+		It("Check error", func() {
+			Expect(err).Should(BeNil())
+		})
+
+		err = matrix.CheckMatches(binHostTree)
+		It("Check matches", func() {
+			Expect(err).Should(BeNil())
+			b := matrix.Branches["net-libs"]
+			Expect(b.Matches).Should(HaveKey("/tmp/net-libs/gnutls/gnutls-3.6.15-2.gpkg.tar"))
+			Expect(b.Matches).Should(HaveKey("/tmp/net-libs/nodejs/nodejs-9.11.1-1.xpak"))
+			Expect(b.NotMatches).Should(HaveKey("/tmp/net-libs/gnutls-1.1.1-1.gpkg.tar"))
+			Expect(b.Matches["/tmp/net-libs/nodejs/nodejs-9.11.1-1.xpak"].Package.Version).Should(Equal("9.11.1"))
+		})
+	})
+
 })
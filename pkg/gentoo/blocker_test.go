@@ -0,0 +1,100 @@
+/*
+
+Copyright (C) 2017-2021  Daniele Rondina <geaaru@sabayonlinux.org>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+*/
+
+
+package gentoo_test
+
+import (
+	. "github.com/Sabayon/pkgs-checker/pkg/gentoo"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Gentoo Blockers", func() {
+
+	DescribeTable("Blocks",
+		func(atom, pkg string, expected bool) {
+			gpA, err := ParsePackageStr(atom)
+			Expect(err).Should(BeNil())
+			gpB, err := ParsePackageStr(pkg)
+			Expect(err).Should(BeNil())
+
+			Expect(gpA.IsBlocker()).Should(BeTrue())
+			blocked, err := gpA.Blocks(gpB)
+			Expect(err).Should(BeNil())
+			Expect(blocked).Should(Equal(expected))
+
+			admitted, err := gpA.Admit(gpB)
+			Expect(err).Should(BeNil())
+			Expect(admitted).Should(Equal(!expected))
+		},
+		Entry("not same version", "!app-misc/foo-1.0", "app-misc/foo-1.0", true),
+		Entry("not other version", "!app-misc/foo-1.0", "app-misc/foo-1.0-r1", false),
+		Entry("not without version", "!app-misc/foo", "app-misc/foo-1.0", true),
+		Entry("not without version and package version", "!app-misc/foo", "app-misc/foo", true),
+		Entry("not less with less", "!<app-misc/foo-1.0", "app-misc/foo-0.9", true),
+		Entry("not less with same", "!<app-misc/foo-1.0", "app-misc/foo-1.0", false),
+		Entry("not less with greater", "!<app-misc/foo-1.0", "app-misc/foo-1.1", false),
+		Entry("not greater with greater", "!>app-misc/foo-1.0", "app-misc/foo-1.0-r1", true),
+		Entry("not greater with same", "!>app-misc/foo-1.0", "app-misc/foo-1.0", false),
+		Entry("not greater with less", "!>app-misc/foo-1.0", "app-misc/foo-1.0_rc1", false),
+		Entry("package without version", "!<app-misc/foo-1.0", "app-misc/foo", false),
+		Entry("different slot", "!app-misc/foo:2", "app-misc/foo-1.0:1", false),
+		Entry("strong same version", "!!app-misc/foo-1.0", "app-misc/foo-1.0", true),
+		Entry("strong less with less", "!!<app-misc/foo-1.0", "app-misc/foo-0.9", true),
+		Entry("strong greater with same", "!!>app-misc/foo-1.0", "app-misc/foo-1.0", false),
+	)
+
+	It("Check strong blocker", func() {
+		gp, err := ParsePackageStr("!!<app-misc/foo-1.0")
+		Expect(err).Should(BeNil())
+		Expect(gp.StrongBlocker).Should(BeTrue())
+		Expect(gp.Name).Should(Equal("foo"))
+		Expect(gp.Version).Should(Equal("1.0"))
+		Expect(gp.Condition.String()).Should(Equal("!<"))
+		Expect(gp.Format()).Should(Equal("!!<app-misc/foo-1.0"))
+
+		gp, err = ParsePackageStr("!app-misc/foo")
+		Expect(err).Should(BeNil())
+		Expect(gp.StrongBlocker).Should(BeFalse())
+		Expect(gp.Format()).Should(Equal("!app-misc/foo"))
+	})
+
+	It("Check not blocker", func() {
+		gpA, _ := ParsePackageStr(">=app-misc/foo-1.0")
+		gpB, _ := ParsePackageStr("app-misc/foo-1.0")
+		Expect(gpA.IsBlocker()).Should(BeFalse())
+		Expect(gpA.Blocks(gpB)).Should(BeFalse())
+	})
+
+	It("Check blocker of another package", func() {
+		gpA, _ := ParsePackageStr("!app-misc/foo")
+		gpB, _ := ParsePackageStr("app-misc/bar-1.0")
+		Expect(gpA.Blocks(gpB)).Should(BeFalse())
+	})
+
+	It("Check constraint of blocker without version", func() {
+		c, err := ParseGentooConstraint("!app-misc/foo")
+		Expect(err).Should(BeNil())
+		Expect(c.IsEmpty()).Should(BeTrue())
+	})
+
+})
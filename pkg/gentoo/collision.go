@@ -0,0 +1,160 @@
+/*
+
+Copyright (C) 2017-2021  Daniele Rondina <geaaru@sabayonlinux.org>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+*/
+
+package gentoo
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// FileCollision is a path owned by more than one installed package.
+type FileCollision struct {
+	File        string       `json:"file"`
+	Owners      []OwnerMatch `json:"owners"`
+	Md5Differs  bool         `json:"md5_differs,omitempty"`
+	TypeDiffers bool         `json:"type_differs,omitempty"`
+}
+
+// CollisionAllowlist contains the patterns of the paths with known
+// overlaps. A pattern ending with /** matches all the files under
+// the directory.
+type CollisionAllowlist struct {
+	Patterns []string `json:"patterns"`
+}
+
+func NewCollisionAllowlist(patterns []string) (*CollisionAllowlist, error) {
+	for _, p := range patterns {
+		if _, err := filepath.Match(strings.TrimSuffix(p, "/**"), ""); err != nil {
+			return nil, errors.New(
+				fmt.Sprintf("Invalid pattern %s: %s", p, err.Error()))
+		}
+	}
+	return &CollisionAllowlist{Patterns: patterns}, nil
+}
+
+// ParseCollisionAllowlist parses a file with a pattern for line.
+// Empty lines and comments starting with # are ignored.
+func ParseCollisionAllowlist(data []byte) (*CollisionAllowlist, error) {
+	patterns := []string{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return NewCollisionAllowlist(patterns)
+}
+
+func LoadCollisionAllowlist(file string) (*CollisionAllowlist, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return ParseCollisionAllowlist(data)
+}
+
+func (a *CollisionAllowlist) IsAllowed(file string) bool {
+	for _, p := range a.Patterns {
+		if strings.HasSuffix(p, "/**") {
+			dir := strings.TrimSuffix(p, "/**")
+			if matched, _ := filepath.Match(dir, file); matched {
+				return true
+			}
+			// Check the parent directories of the file.
+			for d := filepath.Dir(file); d != "/" && d != "."; d = filepath.Dir(d) {
+				if matched, _ := filepath.Match(dir, d); matched {
+					return true
+				}
+			}
+		} else if matched, _ := filepath.Match(p, file); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// GetCollisions returns the paths owned by more than one package
+// sorted by path. The directories are shared between packages and
+// they are ignored. The allowlist is optional.
+func (idx *OwnerIndex) GetCollisions(allowlist *CollisionAllowlist) []FileCollision {
+	ans := []FileCollision{}
+
+	for file, owners := range idx.files {
+		if len(owners) < 2 {
+			continue
+		}
+
+		c := FileCollision{
+			File: file,
+		}
+		hash := ""
+		dirs := 0
+		for _, o := range owners {
+			if o.Type != owners[0].Type {
+				c.TypeDiffers = true
+			}
+			switch o.Type {
+			case ContentTypeDir:
+				dirs++
+			case ContentTypeObj:
+				if hash != "" && hash != o.Hash {
+					c.Md5Differs = true
+				}
+				hash = o.Hash
+			}
+		}
+
+		// The directories are shared between packages but a path that
+		// is a directory for a package and a file for another is a
+		// collision too.
+		if dirs == len(owners) {
+			continue
+		}
+
+		if allowlist != nil && allowlist.IsAllowed(file) {
+			continue
+		}
+
+		c.Owners = append([]OwnerMatch{}, owners...)
+		sort.Slice(c.Owners, func(i, j int) bool {
+			return c.Owners[i].Package < c.Owners[j].Package
+		})
+		ans = append(ans, c)
+	}
+
+	sort.Slice(ans, func(i, j int) bool {
+		return ans[i].File < ans[j].File
+	})
+
+	return ans
+}
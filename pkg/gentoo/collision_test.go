@@ -0,0 +1,126 @@
+/*
+
+Copyright (C) 2017-2021  Daniele Rondina <geaaru@sabayonlinux.org>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+*/
+
+
+package gentoo_test
+
+import (
+	"io/ioutil"
+	"os"
+
+	. "github.com/Sabayon/pkgs-checker/pkg/gentoo"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Gentoo file collisions", func() {
+
+	var tmpdir string
+	var idx *OwnerIndex
+
+	BeforeEach(func() {
+		var err error
+		tmpdir, err = ioutil.TempDir("", "collisions")
+		Expect(err).Should(BeNil())
+
+		writeVdbPackage(tmpdir, "app-misc/foo-1.0", map[string]string{
+			"COUNTER": "1",
+			"CONTENTS": "dir /usr/bin\n" +
+				"obj /usr/bin/same d3b07384d113edec49eaa6238ad5ff00 1600000000\n" +
+				"obj /usr/bin/differs d3b07384d113edec49eaa6238ad5ff00 1600000000\n" +
+				"obj /usr/share/doc/README d3b07384d113edec49eaa6238ad5ff00 1600000000\n" +
+				"sym /usr/bin/link -> same 1600000000\n" +
+				"obj /usr/lib/mixed d3b07384d113edec49eaa6238ad5ff00 1600000000",
+		})
+		writeVdbPackage(tmpdir, "app-misc/bar-2.0", map[string]string{
+			"COUNTER": "2",
+			"CONTENTS": "dir /usr/bin\n" +
+				"obj /usr/bin/same d3b07384d113edec49eaa6238ad5ff00 1600000000\n" +
+				"obj /usr/bin/differs c157a79031e1c40f85931829bc5fc552 1600000000\n" +
+				"obj /usr/share/doc/README c157a79031e1c40f85931829bc5fc552 1600000000\n" +
+				"obj /usr/bin/link d3b07384d113edec49eaa6238ad5ff00 1600000000\n" +
+				"dir /usr/lib/mixed",
+		})
+
+		idx = NewOwnerIndex()
+		_, err = idx.Sync(NewVdbScanner(tmpdir, nil))
+		Expect(err).Should(BeNil())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tmpdir)
+	})
+
+	It("Check collisions", func() {
+		collisions := idx.GetCollisions(nil)
+		Expect(len(collisions)).Should(Equal(5))
+
+		Expect(collisions[0].File).Should(Equal("/usr/bin/differs"))
+		Expect(collisions[0].Md5Differs).Should(BeTrue())
+		Expect(collisions[0].TypeDiffers).Should(BeFalse())
+		Expect(collisions[0].Owners[0].Package).Should(Equal("app-misc/bar-2.0"))
+		Expect(collisions[0].Owners[1].Package).Should(Equal("app-misc/foo-1.0"))
+
+		Expect(collisions[1].File).Should(Equal("/usr/bin/link"))
+		Expect(collisions[1].TypeDiffers).Should(BeTrue())
+
+		Expect(collisions[2].File).Should(Equal("/usr/bin/same"))
+		Expect(collisions[2].Md5Differs).Should(BeFalse())
+
+		Expect(collisions[3].File).Should(Equal("/usr/lib/mixed"))
+		Expect(collisions[3].TypeDiffers).Should(BeTrue())
+
+		Expect(collisions[4].File).Should(Equal("/usr/share/doc/README"))
+	})
+
+	It("Check collisions with allowlist", func() {
+		allowlist, err := ParseCollisionAllowlist([]byte(
+			"# Docs are harmless\n/usr/share/doc/**\n\n/usr/bin/s*\n"))
+		Expect(err).Should(BeNil())
+
+		collisions := idx.GetCollisions(allowlist)
+		Expect(len(collisions)).Should(Equal(3))
+		Expect(collisions[0].File).Should(Equal("/usr/bin/differs"))
+		Expect(collisions[1].File).Should(Equal("/usr/bin/link"))
+		Expect(collisions[2].File).Should(Equal("/usr/lib/mixed"))
+	})
+
+	DescribeTable("Check allowlist",
+		func(pattern, file string, allowed bool) {
+			allowlist, err := NewCollisionAllowlist([]string{pattern})
+			Expect(err).Should(BeNil())
+			Expect(allowlist.IsAllowed(file)).Should(Equal(allowed))
+		},
+		Entry("exact", "/usr/bin/foo", "/usr/bin/foo", true),
+		Entry("glob", "/usr/bin/*", "/usr/bin/foo", true),
+		Entry("glob without subdirs", "/usr/*", "/usr/bin/foo", false),
+		Entry("recursive", "/usr/share/**", "/usr/share/doc/foo/README", true),
+		Entry("recursive dir", "/usr/share/**", "/usr/share", true),
+		Entry("recursive with glob", "/usr/lib*/python*/**", "/usr/lib64/python3.9/site.py", true),
+		Entry("recursive not matched", "/usr/share/**", "/usr/bin/foo", false),
+	)
+
+	It("Check invalid allowlist", func() {
+		_, err := NewCollisionAllowlist([]string{"/usr/["})
+		Expect(err).ShouldNot(BeNil())
+	})
+
+})
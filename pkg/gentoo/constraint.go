@@ -0,0 +1,485 @@
+/*
+
+Copyright (C) 2017-2021  Daniele Rondina <geaaru@sabayonlinux.org>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package gentoo
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Algebra of the version constraints of the atoms. Every version is
+// mapped to a list of tokens ordered as the PMS version comparison, so
+// the ~ and =* conditions become the range of all the versions with
+// the same prefix and every constraint is a list of disjoint ranges.
+// It's assumed that between two different versions there are always
+// other versions (ex. with a build or a suffix).
+
+type versionToken struct {
+	w   int
+	n   string
+	s   string
+	inf bool
+}
+
+func (t versionToken) compare(o versionToken) int {
+	if t.w != o.w {
+		if t.w < o.w {
+			return -1
+		}
+		return 1
+	}
+	if t.inf != o.inf {
+		if t.inf {
+			return 1
+		}
+		return -1
+	}
+	if ans := compareIntStrings(t.n, o.n); ans != 0 {
+		return ans
+	}
+	return strings.Compare(t.s, o.s)
+}
+
+func versionKey(v *GentooVersion) []versionToken {
+	ans := []versionToken{{w: 10, n: v.Numbers[0]}}
+	for _, n := range v.Numbers[1:] {
+		// Components with a leading zero are compared as strings
+		// and they are always less than the others.
+		if strings.HasPrefix(n, "0") {
+			ans = append(ans, versionToken{w: 9, s: strings.TrimRight(n, "0")})
+		} else {
+			ans = append(ans, versionToken{w: 10, n: n})
+		}
+	}
+	// End of the numeric components
+	ans = append(ans, versionToken{w: 0})
+	ans = append(ans, versionToken{w: 10, s: v.Letter})
+	for _, s := range v.Suffixes {
+		ans = append(ans, versionToken{w: versionSuffixWeight(s.Type), n: s.Number})
+	}
+	// End of the suffixes
+	ans = append(ans, versionToken{w: versionSuffixWeight("")})
+	ans = append(ans, versionToken{w: 10, n: v.Revision})
+	if v.Build != "" {
+		for _, b := range strings.Split(v.Build, ".") {
+			if isDigits(b) {
+				ans = append(ans, versionToken{w: 10, n: b})
+			} else {
+				ans = append(ans, versionToken{w: 11, s: b})
+			}
+		}
+	}
+	return ans
+}
+
+type versionBound struct {
+	key []versionToken
+	// -1 before and +1 after all the versions with key as prefix.
+	ext       int
+	incl      bool
+	unbounded bool
+}
+
+type versionInterval struct {
+	lo versionBound
+	hi versionBound
+}
+
+func compareBoundPoints(a, b versionBound) int {
+	for i := 0; i < len(a.key) && i < len(b.key); i++ {
+		if ans := a.key[i].compare(b.key[i]); ans != 0 {
+			return ans
+		}
+	}
+	if len(a.key) == len(b.key) {
+		if a.ext == b.ext {
+			return 0
+		} else if a.ext < b.ext {
+			return -1
+		}
+		return 1
+	}
+	if len(a.key) < len(b.key) {
+		if a.ext > 0 {
+			return 1
+		}
+		return -1
+	}
+	if b.ext > 0 {
+		return -1
+	}
+	return 1
+}
+
+func compareLowerBounds(a, b versionBound) int {
+	if a.unbounded || b.unbounded {
+		if a.unbounded == b.unbounded {
+			return 0
+		} else if a.unbounded {
+			return -1
+		}
+		return 1
+	}
+	ans := compareBoundPoints(a, b)
+	if ans == 0 && a.incl != b.incl {
+		if a.incl {
+			return -1
+		}
+		return 1
+	}
+	return ans
+}
+
+func compareUpperBounds(a, b versionBound) int {
+	if a.unbounded || b.unbounded {
+		if a.unbounded == b.unbounded {
+			return 0
+		} else if a.unbounded {
+			return 1
+		}
+		return -1
+	}
+	ans := compareBoundPoints(a, b)
+	if ans == 0 && a.incl != b.incl {
+		if a.incl {
+			return 1
+		}
+		return -1
+	}
+	return ans
+}
+
+// touch returns true if the upper bound hi and the lower bound lo
+// overlap or are adjacent.
+func touch(hi, lo versionBound) bool {
+	if hi.unbounded || lo.unbounded {
+		return true
+	}
+	ans := compareBoundPoints(lo, hi)
+	return ans < 0 || (ans == 0 && (lo.incl || hi.incl))
+}
+
+func (i versionInterval) isEmpty() bool {
+	if i.lo.unbounded || i.hi.unbounded {
+		return false
+	}
+	ans := compareBoundPoints(i.lo, i.hi)
+	return ans > 0 || (ans == 0 && !(i.lo.incl && i.hi.incl))
+}
+
+func (i versionInterval) contains(key []versionToken) bool {
+	point := versionBound{key: key, incl: true}
+	return compareLowerBounds(i.lo, point) <= 0 && compareUpperBounds(i.hi, point) >= 0
+}
+
+// normalizeIntervals sorts and merges the intervals.
+func normalizeIntervals(list []versionInterval) []versionInterval {
+	ans := []versionInterval{}
+	sorted := []versionInterval{}
+	for _, i := range list {
+		if !i.isEmpty() {
+			sorted = append(sorted, i)
+		}
+	}
+	sort.SliceStable(sorted, func(a, b int) bool {
+		return compareLowerBounds(sorted[a].lo, sorted[b].lo) < 0
+	})
+
+	for _, i := range sorted {
+		if len(ans) > 0 && touch(ans[len(ans)-1].hi, i.lo) {
+			if compareUpperBounds(i.hi, ans[len(ans)-1].hi) > 0 {
+				ans[len(ans)-1].hi = i.hi
+			}
+			continue
+		}
+		ans = append(ans, i)
+	}
+	return ans
+}
+
+type GentooConstraint struct {
+	Category string `json:"category"`
+	Name     string `json:"name"`
+
+	intervals []versionInterval
+}
+
+// NewGentooConstraint returns the versions constraint defined by the
+// condition and the version of the atom. The slot and the USE flags
+// are not modelled.
+func NewGentooConstraint(p *GentooPackage) (*GentooConstraint, error) {
+	ans := &GentooConstraint{
+		Category: p.Category,
+		Name:     p.Name,
+	}
+	any := versionInterval{
+		lo: versionBound{unbounded: true},
+		hi: versionBound{unbounded: true},
+	}
+
+	// An atom without version admits all versions and a blocker
+	// without version blocks all versions.
+	if p.Version == "" {
+		if p.IsBlocker() {
+			ans.intervals = []versionInterval{}
+		} else {
+			ans.intervals = []versionInterval{any}
+		}
+		return ans, nil
+	}
+
+	v := p.Version + p.VersionSuffix
+	if p.VersionBuild != "" && p.Condition != PkgCondMatchVersion {
+		v += "+" + p.VersionBuild
+	}
+	gv, err := ParseVersion(v)
+	if err != nil {
+		return nil, err
+	}
+	key := versionKey(gv)
+	point := versionBound{key: key, incl: true}
+
+	switch p.Condition {
+	case PkgCondInvalid, PkgCondEqual:
+		ans.intervals = []versionInterval{{lo: point, hi: point}}
+	case PkgCondNot:
+		ans.intervals = complementIntervals([]versionInterval{{lo: point, hi: point}})
+	case PkgCondGreaterEqual, PkgCondNotLess:
+		ans.intervals = []versionInterval{{lo: point, hi: any.hi}}
+	case PkgCondLessEqual, PkgCondNotGreater:
+		ans.intervals = []versionInterval{{lo: any.lo, hi: point}}
+	case PkgCondGreater:
+		point.incl = false
+		ans.intervals = []versionInterval{{lo: point, hi: any.hi}}
+	case PkgCondLess:
+		point.incl = false
+		ans.intervals = []versionInterval{{lo: any.lo, hi: point}}
+	case PkgCondAnyRevision:
+		// All the revisions and the builds of the version
+		prefix := key[0 : len(key)-1-len(buildTokens(gv))]
+		ans.intervals = []versionInterval{prefixInterval(prefix, prefix)}
+	case PkgCondMatchVersion:
+		ans.intervals = []versionInterval{globInterval(gv, key)}
+	default:
+		return nil, errors.New(
+			fmt.Sprintf("Unsupported condition %s", p.Condition.String()))
+	}
+
+	return ans, nil
+}
+
+// ParseGentooConstraint returns the constraint of the atom string.
+func ParseGentooConstraint(atom string) (*GentooConstraint, error) {
+	gp, err := ParsePackageStr(atom)
+	if err != nil {
+		return nil, err
+	}
+	return NewGentooConstraint(gp)
+}
+
+func buildTokens(v *GentooVersion) []string {
+	if v.Build == "" {
+		return []string{}
+	}
+	return strings.Split(v.Build, ".")
+}
+
+func prefixInterval(lo, hi []versionToken) versionInterval {
+	return versionInterval{
+		lo: versionBound{key: lo, ext: -1, incl: true},
+		hi: versionBound{key: hi, ext: 1, incl: true},
+	}
+}
+
+// globInterval returns the range of the =<version>* condition. The
+// prefix ends with the last part of the version present in the glob.
+func globInterval(v *GentooVersion, key []versionToken) versionInterval {
+	nums := len(v.Numbers)
+	// Index of the end of the suffixes
+	sufEnd := nums + 2 + len(v.Suffixes)
+
+	switch {
+	case v.Revision != "":
+		prefix := key[0 : sufEnd+2]
+		return prefixInterval(prefix, prefix)
+
+	case len(v.Suffixes) > 0:
+		prefix := key[0:sufEnd]
+		if v.Suffixes[len(v.Suffixes)-1].Number == "" {
+			// Without the number the glob matches all the numbers
+			// of the suffix (ex. 1.0_rc* matches 1.0_rc2).
+			last := prefix[len(prefix)-1]
+			hi := append(append([]versionToken{}, prefix[0:len(prefix)-1]...),
+				versionToken{w: last.w, inf: true})
+			return prefixInterval(prefix, hi)
+		}
+		return prefixInterval(prefix, prefix)
+
+	case v.Letter != "":
+		prefix := key[0 : nums+2]
+		return prefixInterval(prefix, prefix)
+	}
+
+	prefix := key[0:nums]
+	return prefixInterval(prefix, prefix)
+}
+
+func complementIntervals(list []versionInterval) []versionInterval {
+	ans := []versionInterval{}
+	lo := versionBound{unbounded: true}
+
+	for _, i := range list {
+		if !i.lo.unbounded {
+			hi := i.lo
+			hi.incl = !hi.incl
+			ans = append(ans, versionInterval{lo: lo, hi: hi})
+		}
+		if i.hi.unbounded {
+			return normalizeIntervals(ans)
+		}
+		lo = i.hi
+		lo.incl = !lo.incl
+	}
+
+	ans = append(ans, versionInterval{lo: lo, hi: versionBound{unbounded: true}})
+	return normalizeIntervals(ans)
+}
+
+func (c *GentooConstraint) checkPackage(o *GentooConstraint) error {
+	if c.Category != o.Category || c.Name != o.Name {
+		return errors.New(
+			fmt.Sprintf("Constraints of different packages %s/%s and %s/%s",
+				c.Category, c.Name, o.Category, o.Name))
+	}
+	return nil
+}
+
+func (c *GentooConstraint) newConstraint(intervals []versionInterval) *GentooConstraint {
+	return &GentooConstraint{
+		Category:  c.Category,
+		Name:      c.Name,
+		intervals: normalizeIntervals(intervals),
+	}
+}
+
+// Intersect returns the constraint of the versions admitted by both
+// the constraints.
+func (c *GentooConstraint) Intersect(o *GentooConstraint) (*GentooConstraint, error) {
+	if err := c.checkPackage(o); err != nil {
+		return nil, err
+	}
+
+	ans := []versionInterval{}
+	for _, a := range c.intervals {
+		for _, b := range o.intervals {
+			i := versionInterval{lo: a.lo, hi: a.hi}
+			if compareLowerBounds(b.lo, i.lo) > 0 {
+				i.lo = b.lo
+			}
+			if compareUpperBounds(b.hi, i.hi) < 0 {
+				i.hi = b.hi
+			}
+			ans = append(ans, i)
+		}
+	}
+
+	return c.newConstraint(ans), nil
+}
+
+// Union returns the constraint of the versions admitted by one of
+// the constraints.
+func (c *GentooConstraint) Union(o *GentooConstraint) (*GentooConstraint, error) {
+	if err := c.checkPackage(o); err != nil {
+		return nil, err
+	}
+
+	ans := append([]versionInterval{}, c.intervals...)
+	return c.newConstraint(append(ans, o.intervals...)), nil
+}
+
+// Complement returns the constraint of the versions not admitted.
+func (c *GentooConstraint) Complement() *GentooConstraint {
+	return c.newConstraint(complementIntervals(c.intervals))
+}
+
+// IsEmpty returns true if the constraint doesn't admit any version.
+func (c *GentooConstraint) IsEmpty() bool {
+	return len(c.intervals) == 0
+}
+
+// IsAny returns true if the constraint admits all the versions.
+func (c *GentooConstraint) IsAny() bool {
+	return len(c.intervals) == 1 &&
+		c.intervals[0].lo.unbounded && c.intervals[0].hi.unbounded
+}
+
+// IsSubsetOf returns true if all the versions admitted by the
+// constraint are admitted also by o.
+func (c *GentooConstraint) IsSubsetOf(o *GentooConstraint) (bool, error) {
+	ans, err := c.Intersect(o.Complement())
+	if err != nil {
+		return false, err
+	}
+	return ans.IsEmpty(), nil
+}
+
+// IsDisjoint returns true if no version is admitted by both the
+// constraints.
+func (c *GentooConstraint) IsDisjoint(o *GentooConstraint) (bool, error) {
+	ans, err := c.Intersect(o)
+	if err != nil {
+		return false, err
+	}
+	return ans.IsEmpty(), nil
+}
+
+// Equal returns true if the constraints admit the same versions.
+func (c *GentooConstraint) Equal(o *GentooConstraint) (bool, error) {
+	ans, err := c.IsSubsetOf(o)
+	if err != nil || !ans {
+		return false, err
+	}
+	return o.IsSubsetOf(c)
+}
+
+// Contains returns true if the version of the package is admitted by
+// the constraint. A package without version is contained only by the
+// constraint of all the versions.
+func (c *GentooConstraint) Contains(p *GentooPackage) (bool, error) {
+	if c.Category != p.Category || c.Name != p.Name {
+		return false, nil
+	}
+	if p.Version == "" {
+		return c.IsAny(), nil
+	}
+
+	v, err := p.GetVersion()
+	if err != nil {
+		return false, err
+	}
+	key := versionKey(v)
+	for _, i := range c.intervals {
+		if i.contains(key) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,176 @@
+/*
+
+Copyright (C) 2017-2021  Daniele Rondina <geaaru@sabayonlinux.org>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+*/
+
+
+package gentoo_test
+
+import (
+	. "github.com/Sabayon/pkgs-checker/pkg/gentoo"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+func newConstraint(atom string) *GentooConstraint {
+	c, err := ParseGentooConstraint(atom)
+	Expect(err).Should(BeNil())
+	return c
+}
+
+var _ = Describe("Gentoo Constraints", func() {
+
+	// Contains must be coherent with Admit
+	DescribeTable("Contains",
+		func(atom string) {
+			c := newConstraint(atom)
+			versions := []string{
+				"0.9", "1.0_alpha", "1.0_rc1", "1.0", "1.0-r1", "1.0-r1+2", "1.0_p1",
+				"1.0a", "1.0.1", "1.01", "1.1", "1.2_alpha1", "1.2_rc", "1.2_rc2",
+				"1.2_rc2_p1", "1.2", "1.2-r3", "1.2a", "1.2.5", "1.2.10", "1.20", "2.0",
+			}
+			for _, v := range versions {
+				gpA, err := ParsePackageStr(atom)
+				Expect(err).Should(BeNil())
+				gpB, err := ParsePackageStr("app-misc/foo-" + v)
+				Expect(err).Should(BeNil())
+
+				admitted, err := gpA.Admit(gpB)
+				Expect(err).Should(BeNil())
+				contained, err := c.Contains(gpB)
+				Expect(err).Should(BeNil())
+				Expect(contained).Should(Equal(admitted), "version %s", v)
+			}
+		},
+		Entry("equal", "=app-misc/foo-1.0"),
+		Entry("equal with revision", "=app-misc/foo-1.0-r1"),
+		Entry("greater", ">app-misc/foo-1.0"),
+		Entry("greater or equal", ">=app-misc/foo-1.0_rc1"),
+		Entry("less", "<app-misc/foo-1.2"),
+		Entry("less or equal", "<=app-misc/foo-1.2_rc2"),
+		Entry("any revision", "~app-misc/foo-1.0"),
+		Entry("glob", "=app-misc/foo-1.2*"),
+		Entry("glob with suffix", "=app-misc/foo-1.2_rc*"),
+		Entry("glob with suffix number", "=app-misc/foo-1.2_rc2*"),
+		Entry("glob with letter", "=app-misc/foo-1.0a*"),
+		Entry("glob with revision", "=app-misc/foo-1.2-r3*"),
+		Entry("glob major", "=app-misc/foo-1*"),
+		Entry("any version", "app-misc/foo"),
+	)
+
+	DescribeTable("IsDisjoint",
+		func(a, b string, expected bool) {
+			ans, err := newConstraint(a).IsDisjoint(newConstraint(b))
+			Expect(err).Should(BeNil())
+			Expect(ans).Should(Equal(expected))
+
+			ans, err = newConstraint(b).IsDisjoint(newConstraint(a))
+			Expect(err).Should(BeNil())
+			Expect(ans).Should(Equal(expected))
+		},
+		Entry("overlapping ranges", ">=dev-lang/go-1.15", "<dev-lang/go-1.17", false),
+		Entry("contradictory ranges", ">=dev-lang/go-1.17", "<dev-lang/go-1.17", true),
+		Entry("adjacent ranges", ">dev-lang/go-1.17", "<=dev-lang/go-1.17", true),
+		Entry("point in range", "=dev-lang/go-1.16.5", "<dev-lang/go-1.17", false),
+		Entry("different globs", "=dev-lang/go-1.16*", "=dev-lang/go-1.17*", true),
+		Entry("glob component", "=dev-lang/go-1.1*", ">=dev-lang/go-1.10", true),
+		Entry("glob with rc", "=dev-lang/go-1.17*", "<dev-lang/go-1.17", false),
+		Entry("any revision and revision", "~dev-lang/go-1.17", "=dev-lang/go-1.17-r2", false),
+		Entry("any revision and _p", "~dev-lang/go-1.17", "=dev-lang/go-1.17_p1", true),
+		Entry("not less and less", "!<dev-lang/go-1.17", "<dev-lang/go-1.17", true),
+		Entry("not greater and greater", "!>dev-lang/go-1.17", ">dev-lang/go-1.17", true),
+		Entry("not and equal", "!dev-lang/go-1.17", "=dev-lang/go-1.17", true),
+		Entry("not and any revision", "!dev-lang/go-1.17", "~dev-lang/go-1.17", false),
+	)
+
+	DescribeTable("IsSubsetOf",
+		func(a, b string, expected bool) {
+			ans, err := newConstraint(a).IsSubsetOf(newConstraint(b))
+			Expect(err).Should(BeNil())
+			Expect(ans).Should(Equal(expected))
+		},
+		Entry("glob in range", "=app-misc/foo-1.2*", ">=app-misc/foo-1.0", true),
+		Entry("glob with rc in range", "=app-misc/foo-1.2*", ">=app-misc/foo-1.2", false),
+		Entry("range in glob", ">=app-misc/foo-1.0", "=app-misc/foo-1.2*", false),
+		Entry("any revision in glob", "~app-misc/foo-1.2", "=app-misc/foo-1.2*", true),
+		Entry("glob in glob", "=app-misc/foo-1.2.3*", "=app-misc/foo-1.2*", true),
+		Entry("revision in any revision", "=app-misc/foo-1.2-r3", "~app-misc/foo-1.2", true),
+		Entry("greater in greater or equal", ">app-misc/foo-1.2", ">=app-misc/foo-1.2", true),
+		Entry("greater or equal in greater", ">=app-misc/foo-1.2", ">app-misc/foo-1.2", false),
+		Entry("not less in greater or equal", "!<app-misc/foo-1.2", ">=app-misc/foo-1.2", true),
+		Entry("range in any", ">=app-misc/foo-1.2", "app-misc/foo", true),
+		Entry("any in range", "app-misc/foo", ">=app-misc/foo-1.2", false),
+	)
+
+	Context("Union", func() {
+		It("Check complementary ranges", func() {
+			c, err := newConstraint(">=app-misc/foo-1.0").Union(newConstraint("<app-misc/foo-1.0"))
+			Expect(err).Should(BeNil())
+			Expect(c.IsAny()).Should(BeTrue())
+		})
+
+		It("Check not with equal", func() {
+			c, err := newConstraint("!app-misc/foo-1.0").Union(newConstraint("=app-misc/foo-1.0"))
+			Expect(err).Should(BeNil())
+			Expect(c.IsAny()).Should(BeTrue())
+		})
+
+		It("Check disjoint ranges", func() {
+			c, err := newConstraint("<app-misc/foo-1.0").Union(newConstraint(">app-misc/foo-2.0"))
+			Expect(err).Should(BeNil())
+			Expect(c.IsAny()).Should(BeFalse())
+
+			ans, err := newConstraint("=app-misc/foo-1.5").IsDisjoint(c)
+			Expect(err).Should(BeNil())
+			Expect(ans).Should(BeTrue())
+
+			ans, err = c.Equal(newConstraint("!app-misc/foo-1.5").Complement().Complement())
+			Expect(err).Should(BeNil())
+			Expect(ans).Should(BeFalse())
+		})
+	})
+
+	Context("Intersect", func() {
+		It("Check empty", func() {
+			c, err := newConstraint(">=app-misc/foo-1.2").Intersect(newConstraint("<app-misc/foo-1.2"))
+			Expect(err).Should(BeNil())
+			Expect(c.IsEmpty()).Should(BeTrue())
+		})
+
+		It("Check equal", func() {
+			c, err := newConstraint(">=app-misc/foo-1.2").Intersect(newConstraint("<=app-misc/foo-1.2"))
+			Expect(err).Should(BeNil())
+			ans, err := c.Equal(newConstraint("=app-misc/foo-1.2"))
+			Expect(err).Should(BeNil())
+			Expect(ans).Should(BeTrue())
+		})
+
+		It("Check different packages", func() {
+			_, err := newConstraint(">=app-misc/foo-1.2").Intersect(newConstraint("<app-misc/bar-1.2"))
+			Expect(err).ShouldNot(BeNil())
+		})
+	})
+
+	It("Check Complement", func() {
+		ans, err := newConstraint("<app-misc/foo-1.2").Complement().Equal(newConstraint(">=app-misc/foo-1.2"))
+		Expect(err).Should(BeNil())
+		Expect(ans).Should(BeTrue())
+	})
+
+})
@@ -0,0 +1,284 @@
+/*
+
+Copyright (C) 2017-2021  Daniele Rondina <geaaru@sabayonlinux.org>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package gentoo
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Parser of the dependency specifications (RDEPEND, DEPEND, BDEPEND,
+// PDEPEND) as described by PMS section 8.2:
+// https://projects.gentoo.org/pms/latest/pms.html#x1-670008.2
+
+type GentooDependencyType int
+
+const (
+	// cat/foo
+	DepTypeAtom GentooDependencyType = iota
+	// ( ... )
+	DepTypeAllOf
+	// || ( ... )
+	DepTypeAnyOf
+	// ^^ ( ... )
+	DepTypeExactlyOne
+	// ?? ( ... )
+	DepTypeAtMostOne
+	// foo? ( ... ) and !foo? ( ... )
+	DepTypeUseConditional
+)
+
+const (
+	// !cat/foo
+	DepBlockerWeak = "!"
+	// !!cat/foo
+	DepBlockerStrong = "!!"
+)
+
+type GentooDependency struct {
+	Type GentooDependencyType `json:"type"`
+
+	// Valorized only for DepTypeAtom
+	Atom    string         `json:"atom,omitempty"`
+	Package *GentooPackage `json:"package,omitempty"`
+	Blocker string         `json:"blocker,omitempty"`
+
+	// Valorized only for DepTypeUseConditional
+	Use        string `json:"use,omitempty"`
+	UseNegated bool   `json:"use_negated,omitempty"`
+
+	Children []*GentooDependency `json:"children,omitempty"`
+
+	// The root group is the only group without parenthesis.
+	root bool
+}
+
+func (t GentooDependencyType) String() (ans string) {
+	switch t {
+	case DepTypeAtom:
+		ans = "atom"
+	case DepTypeAllOf:
+		ans = "all-of"
+	case DepTypeAnyOf:
+		ans = "any-of"
+	case DepTypeExactlyOne:
+		ans = "exactly-one-of"
+	case DepTypeAtMostOne:
+		ans = "at-most-one-of"
+	case DepTypeUseConditional:
+		ans = "use-conditional"
+	}
+	return
+}
+
+var dependencyParser = &depSpecParser{
+	name:      "dependencies",
+	operators: depSpecAllOperators,
+	newGroup: func(t GentooDependencyType, use string, useNegated bool) depSpecNode {
+		return &GentooDependency{
+			Type:       t,
+			Use:        use,
+			UseNegated: useNegated,
+			Children:   []*GentooDependency{},
+		}
+	},
+	newLeaf: func(token string, pos int) (depSpecNode, error) {
+		return parseDependencyAtom(token)
+	},
+}
+
+// ParseDependencies parses a dependency specification and returns
+// the root of the tree as a DepTypeAllOf group.
+func ParseDependencies(deps string) (*GentooDependency, error) {
+	node, err := dependencyParser.parse(deps)
+	if err != nil {
+		return nil, err
+	}
+	ans := node.(*GentooDependency)
+	ans.root = true
+	return ans, nil
+}
+
+func (d *GentooDependency) addChild(child depSpecNode) {
+	d.Children = append(d.Children, child.(*GentooDependency))
+}
+
+func parseDependencyAtom(token string) (*GentooDependency, error) {
+	ans := &GentooDependency{
+		Type: DepTypeAtom,
+	}
+
+	if strings.HasPrefix(token, DepBlockerStrong) {
+		ans.Blocker = DepBlockerStrong
+		token = token[2:]
+	} else if strings.HasPrefix(token, DepBlockerWeak) {
+		ans.Blocker = DepBlockerWeak
+		token = token[1:]
+	}
+
+	gp, err := ParseAtom(token)
+	if err != nil {
+		return nil, errors.New(
+			fmt.Sprintf("Invalid dependency %s: %s", token, err.Error()))
+	}
+	ans.Package = gp
+	ans.Atom = token
+
+	return ans, nil
+}
+
+// ParseAtom parses an atom of a dependency. Unlike ParsePackageStr an
+// atom without slot matches all the slots.
+func ParseAtom(atom string) (*GentooPackage, error) {
+	return ParsePackageStrWithOpts(atom, &GentooPackageParseOpts{AnySlot: true})
+}
+
+func newUseSet(uses []string) map[string]bool {
+	ans := make(map[string]bool, len(uses))
+	for _, u := range uses {
+		if strings.HasPrefix(u, "-") {
+			ans[u[1:]] = false
+		} else {
+			ans[strings.TrimPrefix(u, "+")] = true
+		}
+	}
+	return ans
+}
+
+// IsBlocker returns true if the dependency is a weak or strong blocker.
+func (d *GentooDependency) IsBlocker() bool {
+	return d.Type == DepTypeAtom && d.Blocker != ""
+}
+
+// ReduceUse returns a new tree where the USE-conditional groups are
+// evaluated against the enabled USE flags. The inactive groups are
+// dropped and the active groups are replaced by an all-of group.
+// The USE flags disabled could be supplied with the - prefix.
+func (d *GentooDependency) ReduceUse(uses []string) *GentooDependency {
+	return d.reduceUse(newUseSet(uses))
+}
+
+func (d *GentooDependency) reduceUse(uses map[string]bool) *GentooDependency {
+	if d.Type == DepTypeAtom {
+		return d
+	}
+
+	ans := &GentooDependency{
+		Type:     d.Type,
+		Children: []*GentooDependency{},
+		root:     d.root,
+	}
+	if d.Type == DepTypeUseConditional {
+		if uses[d.Use] == d.UseNegated {
+			return nil
+		}
+		ans.Type = DepTypeAllOf
+	}
+
+	for _, c := range d.Children {
+		child := c.reduceUse(uses)
+		if child != nil {
+			ans.Children = append(ans.Children, child)
+		}
+	}
+
+	return ans
+}
+
+// GetAtoms returns the list of all atoms of the tree. The alternatives
+// of the any-of groups are all returned.
+func (d *GentooDependency) GetAtoms(withBlockers bool) []*GentooPackage {
+	ans := []*GentooPackage{}
+
+	if d.Type == DepTypeAtom {
+		if withBlockers || d.Blocker == "" {
+			ans = append(ans, d.Package)
+		}
+		return ans
+	}
+
+	for _, c := range d.Children {
+		ans = append(ans, c.GetAtoms(withBlockers)...)
+	}
+
+	return ans
+}
+
+// Reduce evaluates the tree against the USE flags supplied and
+// returns the flat list of the atoms without blockers.
+func (d *GentooDependency) Reduce(uses []string) []*GentooPackage {
+	return d.ReduceUse(uses).GetAtoms(false)
+}
+
+// GetBlockers returns the list of the blockers active with the USE
+// flags supplied.
+func (d *GentooDependency) GetBlockers(uses []string) []*GentooDependency {
+	ans := []*GentooDependency{}
+	d.ReduceUse(uses).walk(func(n *GentooDependency) {
+		if n.IsBlocker() {
+			ans = append(ans, n)
+		}
+	})
+	return ans
+}
+
+func (d *GentooDependency) walk(f func(*GentooDependency)) {
+	f(d)
+	for _, c := range d.Children {
+		c.walk(f)
+	}
+}
+
+func (d *GentooDependency) String() string {
+	if d.Type == DepTypeAtom {
+		return d.Blocker + d.Atom
+	}
+
+	children := []string{}
+	for _, c := range d.Children {
+		children = append(children, c.String())
+	}
+	body := strings.Join(children, " ")
+	if d.root && d.Type == DepTypeAllOf {
+		return body
+	}
+
+	group := "( )"
+	if body != "" {
+		group = "( " + body + " )"
+	}
+
+	switch d.Type {
+	case DepTypeAnyOf:
+		return "|| " + group
+	case DepTypeExactlyOne:
+		return "^^ " + group
+	case DepTypeAtMostOne:
+		return "?? " + group
+	case DepTypeUseConditional:
+		neg := ""
+		if d.UseNegated {
+			neg = "!"
+		}
+		return neg + d.Use + "? " + group
+	}
+
+	return group
+}
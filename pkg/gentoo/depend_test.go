@@ -0,0 +1,209 @@
+/*
+
+Copyright (C) 2017-2021  Daniele Rondina <geaaru@sabayonlinux.org>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+*/
+
+package gentoo_test
+
+import (
+	. "github.com/Sabayon/pkgs-checker/pkg/gentoo"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func atomsNames(atoms []*GentooPackage) []string {
+	ans := []string{}
+	for _, a := range atoms {
+		ans = append(ans, a.GetPackageName())
+	}
+	return ans
+}
+
+var _ = Describe("Gentoo Dependencies", func() {
+
+	Context("Parse RDEPEND of dev-libs/glib", func() {
+		rdepend := `!<dev-util/gdbus-codegen-2.66.4 >=virtual/libiconv-0-r1[abi_x86_32(-)?,abi_x86_64(-)?] >=dev-libs/libpcre-8.31:3[abi_x86_32(-)?,abi_x86_64(-)?,static-libs?] dbus? ( elibc_glibc? ( sys-apps/dbus ) ) !!sys-apps/old-glib || ( dev-lang/python:3.9 dev-lang/python:3.8 ) ?? ( app-misc/a app-misc/b ) !systemtap? ( dev-util/systemtap )`
+
+		deps, err := ParseDependencies(rdepend)
+
+		It("Check error", func() {
+			Expect(err).Should(BeNil())
+		})
+
+		It("Check tree", func() {
+			Expect(deps.Type).Should(Equal(DepTypeAllOf))
+			Expect(len(deps.Children)).Should(Equal(8))
+
+			Expect(deps.Children[0].Type).Should(Equal(DepTypeAtom))
+			Expect(deps.Children[0].Blocker).Should(Equal(DepBlockerWeak))
+			Expect(deps.Children[0].Package.Condition).Should(Equal(PackageCond(PkgCondLess)))
+			Expect(deps.Children[0].Package.Version).Should(Equal("2.66.4"))
+
+			Expect(deps.Children[2].Package.GetPackageName()).Should(Equal("dev-libs/libpcre"))
+			Expect(deps.Children[2].Package.Slot).Should(Equal("3"))
+			Expect(deps.Children[2].Package.UseFlags).Should(Equal([]string{
+				"abi_x86_32(-)?", "abi_x86_64(-)?", "static-libs?",
+			}))
+
+			Expect(deps.Children[3].Type).Should(Equal(DepTypeUseConditional))
+			Expect(deps.Children[3].Use).Should(Equal("dbus"))
+			Expect(deps.Children[3].UseNegated).Should(BeFalse())
+			Expect(deps.Children[3].Children[0].Type).Should(Equal(DepTypeUseConditional))
+			Expect(deps.Children[3].Children[0].Use).Should(Equal("elibc_glibc"))
+
+			Expect(deps.Children[4].Blocker).Should(Equal(DepBlockerStrong))
+			Expect(deps.Children[4].IsBlocker()).Should(BeTrue())
+
+			Expect(deps.Children[5].Type).Should(Equal(DepTypeAnyOf))
+			Expect(len(deps.Children[5].Children)).Should(Equal(2))
+			Expect(deps.Children[6].Type).Should(Equal(DepTypeAtMostOne))
+
+			Expect(deps.Children[7].Type).Should(Equal(DepTypeUseConditional))
+			Expect(deps.Children[7].UseNegated).Should(BeTrue())
+			Expect(deps.Children[7].Use).Should(Equal("systemtap"))
+		})
+
+		It("Check String", func() {
+			Expect(deps.String()).Should(Equal(rdepend))
+		})
+
+		It("Check Reduce with dbus", func() {
+			Expect(atomsNames(deps.Reduce([]string{"dbus", "elibc_glibc", "-systemtap"}))).Should(Equal([]string{
+				"virtual/libiconv",
+				"dev-libs/libpcre",
+				"sys-apps/dbus",
+				"dev-lang/python",
+				"dev-lang/python",
+				"app-misc/a",
+				"app-misc/b",
+				"dev-util/systemtap",
+			}))
+		})
+
+		It("Check Reduce without dbus", func() {
+			Expect(atomsNames(deps.Reduce([]string{"elibc_glibc", "systemtap"}))).Should(Equal([]string{
+				"virtual/libiconv",
+				"dev-libs/libpcre",
+				"dev-lang/python",
+				"dev-lang/python",
+				"app-misc/a",
+				"app-misc/b",
+			}))
+		})
+
+		It("Check ReduceUse", func() {
+			Expect(deps.ReduceUse([]string{"dbus"}).String()).Should(Equal(
+				`!<dev-util/gdbus-codegen-2.66.4 >=virtual/libiconv-0-r1[abi_x86_32(-)?,abi_x86_64(-)?] >=dev-libs/libpcre-8.31:3[abi_x86_32(-)?,abi_x86_64(-)?,static-libs?] ( ) !!sys-apps/old-glib || ( dev-lang/python:3.9 dev-lang/python:3.8 ) ?? ( app-misc/a app-misc/b ) ( dev-util/systemtap )`,
+			))
+		})
+
+		It("Check blockers", func() {
+			blockers := deps.GetBlockers([]string{})
+			Expect(len(blockers)).Should(Equal(2))
+			Expect(blockers[0].Package.GetPackageName()).Should(Equal("dev-util/gdbus-codegen"))
+			Expect(blockers[1].Package.GetPackageName()).Should(Equal("sys-apps/old-glib"))
+		})
+
+		It("Check atoms with blockers", func() {
+			Expect(len(deps.GetAtoms(true))).Should(Equal(10))
+			Expect(len(deps.GetAtoms(false))).Should(Equal(8))
+		})
+	})
+
+	Context("Parse empty dependencies", func() {
+		deps, err := ParseDependencies("")
+
+		It("Check error", func() {
+			Expect(err).Should(BeNil())
+		})
+
+		It("Check tree", func() {
+			Expect(len(deps.Children)).Should(Equal(0))
+			Expect(len(deps.Reduce(nil))).Should(Equal(0))
+		})
+	})
+
+	Context("Parse dependencies with newlines", func() {
+		deps, err := ParseDependencies("sys-libs/zlib\n\tssl? (\n\t\tdev-libs/openssl:0=\n\t)\n")
+
+		It("Check error", func() {
+			Expect(err).Should(BeNil())
+		})
+
+		It("Check Reduce", func() {
+			Expect(atomsNames(deps.Reduce([]string{"ssl"}))).Should(Equal([]string{
+				"sys-libs/zlib", "dev-libs/openssl",
+			}))
+		})
+	})
+
+	Context("Parse dependencies without slot", func() {
+		deps, err := ParseDependencies("dev-lang/python >=sys-libs/zlib-1.2:0")
+
+		It("Check error", func() {
+			Expect(err).Should(BeNil())
+		})
+
+		It("Check match of all the slots", func() {
+			atoms := deps.Reduce([]string{})
+			Expect(len(atoms)).Should(Equal(2))
+			Expect(atoms[0].Slot).Should(Equal(""))
+			Expect(atoms[1].Slot).Should(Equal("0"))
+
+			installed, err := ParsePackageStr("dev-lang/python-3.9:3.9")
+			Expect(err).Should(BeNil())
+			admit, err := atoms[0].Admit(installed)
+			Expect(err).Should(BeNil())
+			Expect(admit).Should(BeTrue())
+
+			installed, err = ParsePackageStr("sys-libs/zlib-1.2.11:1")
+			Expect(err).Should(BeNil())
+			admit, err = atoms[1].Admit(installed)
+			Expect(err).Should(BeNil())
+			Expect(admit).Should(BeFalse())
+		})
+	})
+
+	Context("Invalid dependencies", func() {
+		It("Missing close", func() {
+			_, err := ParseDependencies("ssl? ( dev-libs/openssl")
+			Expect(err).ShouldNot(BeNil())
+		})
+
+		It("Unexpected close", func() {
+			_, err := ParseDependencies("dev-libs/openssl )")
+			Expect(err).ShouldNot(BeNil())
+		})
+
+		It("Missing open", func() {
+			_, err := ParseDependencies("ssl? dev-libs/openssl")
+			Expect(err).ShouldNot(BeNil())
+		})
+
+		It("Invalid any-of", func() {
+			_, err := ParseDependencies("|| dev-libs/openssl")
+			Expect(err).ShouldNot(BeNil())
+		})
+
+		It("Invalid atom", func() {
+			_, err := ParseDependencies("openssl")
+			Expect(err).ShouldNot(BeNil())
+		})
+	})
+
+})
@@ -0,0 +1,142 @@
+/*
+
+Copyright (C) 2017-2021  Daniele Rondina <geaaru@sabayonlinux.org>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package gentoo
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Common parser of the specifications with the syntax described by
+// PMS section 8.2 (DEPEND, LICENSE, REQUIRED_USE, ...). The grammars
+// differ only for the group operators supported and for the leaves.
+
+// depSpecNode is a node of the tree built by the depSpecParser.
+type depSpecNode interface {
+	addChild(child depSpecNode)
+}
+
+type depSpecParser struct {
+	// Name of the specification used on the errors.
+	name string
+	// Group operators supported besides the all-of and the
+	// USE-conditional groups.
+	operators map[string]GentooDependencyType
+	// newGroup creates a group. The use is valorized only for
+	// DepTypeUseConditional.
+	newGroup func(t GentooDependencyType, use string, useNegated bool) depSpecNode
+	// newLeaf creates a leaf from the token at the position pos.
+	newLeaf func(token string, pos int) (depSpecNode, error)
+}
+
+var (
+	depSpecAnyOfOperators = map[string]GentooDependencyType{
+		"||": DepTypeAnyOf,
+	}
+	depSpecAllOperators = map[string]GentooDependencyType{
+		"||": DepTypeAnyOf,
+		"^^": DepTypeExactlyOne,
+		"??": DepTypeAtMostOne,
+	}
+)
+
+// parse returns the root of the tree as a DepTypeAllOf group.
+func (p *depSpecParser) parse(spec string) (depSpecNode, error) {
+	tokens := strings.Fields(spec)
+
+	ans, pos, err := p.parseGroup(tokens, 0, p.newGroup(DepTypeAllOf, "", false), false)
+	if err != nil {
+		return nil, err
+	}
+	if pos != len(tokens) {
+		return nil, errors.New(
+			fmt.Sprintf("Unexpected token %s at position %d", tokens[pos], pos))
+	}
+	return ans, nil
+}
+
+func (p *depSpecParser) parseGroup(tokens []string, pos int, ans depSpecNode, nested bool) (depSpecNode, int, error) {
+	for pos < len(tokens) {
+		token := tokens[pos]
+		t, isOperator := p.operators[token]
+
+		switch {
+		case token == ")":
+			if !nested {
+				return nil, pos, errors.New(
+					fmt.Sprintf("Unexpected ) at position %d", pos))
+			}
+			return ans, pos + 1, nil
+
+		case token == "(":
+			child, next, err := p.parseGroup(tokens, pos+1,
+				p.newGroup(DepTypeAllOf, "", false), true)
+			if err != nil {
+				return nil, next, err
+			}
+			ans.addChild(child)
+			pos = next
+
+		case isOperator || strings.HasSuffix(token, "?"):
+			if pos+1 >= len(tokens) || tokens[pos+1] != "(" {
+				return nil, pos, errors.New(
+					fmt.Sprintf("Missing ( after %s at position %d", token, pos))
+			}
+
+			var group depSpecNode
+			if isOperator {
+				group = p.newGroup(t, "", false)
+			} else {
+				use := token[0 : len(token)-1]
+				negated := strings.HasPrefix(use, "!")
+				if negated {
+					use = use[1:]
+				}
+				if use == "" {
+					return nil, pos, errors.New(
+						fmt.Sprintf("Invalid USE conditional %s at position %d", token, pos))
+				}
+				group = p.newGroup(DepTypeUseConditional, use, negated)
+			}
+
+			child, next, err := p.parseGroup(tokens, pos+2, group, true)
+			if err != nil {
+				return nil, next, err
+			}
+			ans.addChild(child)
+			pos = next
+
+		default:
+			child, err := p.newLeaf(token, pos)
+			if err != nil {
+				return nil, pos, err
+			}
+			ans.addChild(child)
+			pos++
+		}
+	}
+
+	if nested {
+		return nil, pos, errors.New(
+			fmt.Sprintf("Missing ) at the end of the %s", p.name))
+	}
+
+	return ans, pos, nil
+}
@@ -0,0 +1,334 @@
+/*
+
+Copyright (C) 2017-2021  Daniele Rondina <geaaru@sabayonlinux.org>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+*/
+
+package gentoo
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	DiffFieldUse        = "USE"
+	DiffFieldCFlags     = "CFLAGS"
+	DiffFieldCHost      = "CHOST"
+	DiffFieldKeywords   = "KEYWORDS"
+	DiffFieldRepository = "repository"
+	DiffFieldSlot       = "SLOT"
+	DiffFieldBuildTime  = "BUILD_TIME"
+)
+
+// VdbDiffFields are the metadata files needed to compute the differences
+// between two vdb.
+var VdbDiffFields = []PortageMetaField{
+	MetaFieldSlot,
+	MetaFieldBuildTime,
+	MetaFieldUse,
+	MetaFieldCFlags,
+	MetaFieldCHost,
+	MetaFieldKeywords,
+	MetaFieldRepository,
+}
+
+// FieldChange describes the change of a metadata field of a package.
+// The list fields (USE, KEYWORDS) report the added and removed values.
+type FieldChange struct {
+	Field   string   `json:"field"`
+	From    string   `json:"from"`
+	To      string   `json:"to"`
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+}
+
+// PackageChange describes a package available on both the vdb with a
+// different version or build.
+type PackageChange struct {
+	Package string        `json:"package"`
+	From    string        `json:"from"`
+	To      string        `json:"to"`
+	Fields  []FieldChange `json:"fields,omitempty"`
+}
+
+// VdbDiff contains the differences between two sets of installed
+// packages. The packages are matched by name and slot.
+type VdbDiff struct {
+	Added      []string        `json:"added"`
+	Removed    []string        `json:"removed"`
+	Upgraded   []PackageChange `json:"upgraded"`
+	Downgraded []PackageChange `json:"downgraded"`
+	Rebuilt    []PackageChange `json:"rebuilt"`
+}
+
+func (c *FieldChange) String() string {
+	if c.Field == DiffFieldUse || c.Field == DiffFieldKeywords {
+		values := []string{}
+		for _, v := range c.Added {
+			values = append(values, "+"+v)
+		}
+		for _, v := range c.Removed {
+			values = append(values, "-"+v)
+		}
+		return fmt.Sprintf("%s: %s", c.Field, strings.Join(values, " "))
+	}
+	return fmt.Sprintf("%s: \"%s\" -> \"%s\"", c.Field, c.From, c.To)
+}
+
+func diffListField(field, from, to string) *FieldChange {
+	fromValues := strings.Fields(from)
+	toValues := strings.Fields(to)
+	fromSet := make(map[string]bool, 0)
+	toSet := make(map[string]bool, 0)
+	for _, v := range fromValues {
+		fromSet[v] = true
+	}
+	for _, v := range toValues {
+		toSet[v] = true
+	}
+
+	ans := &FieldChange{
+		Field:   field,
+		From:    strings.Join(fromValues, " "),
+		To:      strings.Join(toValues, " "),
+		Added:   []string{},
+		Removed: []string{},
+	}
+	for _, v := range toValues {
+		if !fromSet[v] {
+			ans.Added = append(ans.Added, v)
+		}
+	}
+	for _, v := range fromValues {
+		if !toSet[v] {
+			ans.Removed = append(ans.Removed, v)
+		}
+	}
+	if len(ans.Added) == 0 && len(ans.Removed) == 0 {
+		return nil
+	}
+	sort.Strings(ans.Added)
+	sort.Strings(ans.Removed)
+
+	return ans
+}
+
+func diffStringField(field, from, to string) *FieldChange {
+	from = strings.TrimSpace(from)
+	to = strings.TrimSpace(to)
+	if from == to {
+		return nil
+	}
+	return &FieldChange{Field: field, From: from, To: to}
+}
+
+// DiffFields returns the differences of USE, CFLAGS, CHOST, KEYWORDS,
+// repository and sub-slot between two builds of a package.
+func DiffFields(from, to *PortageMetaData) []FieldChange {
+	ans := []FieldChange{}
+	changes := []*FieldChange{
+		diffListField(DiffFieldUse,
+			strings.Join(from.Use, " "), strings.Join(to.Use, " ")),
+		diffStringField(DiffFieldCFlags, from.CFlags, to.CFlags),
+		diffStringField(DiffFieldCHost, from.CHost, to.CHost),
+		diffListField(DiffFieldKeywords, from.KEYWORDS, to.KEYWORDS),
+		diffStringField(DiffFieldRepository,
+			from.GentooPackage.Repository, to.GentooPackage.Repository),
+		diffStringField(DiffFieldSlot,
+			from.GentooPackage.GetSlotStr(), to.GentooPackage.GetSlotStr()),
+	}
+	for _, c := range changes {
+		if c != nil {
+			ans = append(ans, *c)
+		}
+	}
+	return ans
+}
+
+func getDiffKey(pm *PortageMetaData) string {
+	slot := pm.GentooPackage.Slot
+	if slot == "" {
+		slot = "0"
+	}
+	return fmt.Sprintf("%s:%s", pm.GetPackageName(), slot)
+}
+
+func indexDiffPackages(pkgs []*PortageMetaData) (map[string]*PortageMetaData, error) {
+	ans := make(map[string]*PortageMetaData, 0)
+	for _, pm := range pkgs {
+		key := getDiffKey(pm)
+		if _, ok := ans[key]; ok {
+			return nil, errors.New(
+				fmt.Sprintf("Package %s installed multiple times", key))
+		}
+		ans[key] = pm
+	}
+	return ans, nil
+}
+
+// DiffMetadata compares two sets of installed packages, for example the
+// result of ParseMetadataDir over two roots. The packages with the same
+// version are reported as rebuilt when the BUILD_TIME or the compared
+// fields are different.
+func DiffMetadata(from, to []*PortageMetaData) (*VdbDiff, error) {
+	fromIdx, err := indexDiffPackages(from)
+	if err != nil {
+		return nil, err
+	}
+	toIdx, err := indexDiffPackages(to)
+	if err != nil {
+		return nil, err
+	}
+
+	ans := &VdbDiff{
+		Added:      []string{},
+		Removed:    []string{},
+		Upgraded:   []PackageChange{},
+		Downgraded: []PackageChange{},
+		Rebuilt:    []PackageChange{},
+	}
+
+	for key, f := range fromIdx {
+		if _, ok := toIdx[key]; !ok {
+			ans.Removed = append(ans.Removed, f.GetPackageNameWithVersion())
+		}
+	}
+
+	for key, t := range toIdx {
+		f, ok := fromIdx[key]
+		if !ok {
+			ans.Added = append(ans.Added, t.GetPackageNameWithVersion())
+			continue
+		}
+
+		change := PackageChange{
+			Package: key,
+			From:    f.GetPackageNameWithVersion(),
+			To:      t.GetPackageNameWithVersion(),
+			Fields:  DiffFields(f, t),
+		}
+
+		cmp, err := t.GentooPackage.CompareVersion(f.GentooPackage)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case cmp > 0:
+			ans.Upgraded = append(ans.Upgraded, change)
+		case cmp < 0:
+			ans.Downgraded = append(ans.Downgraded, change)
+		// The COUNTER is the merge sequence of the root and it differs
+		// also between roots installed from the same binary packages.
+		case f.BUILD_TIME != t.BUILD_TIME:
+			change.Fields = append(change.Fields, FieldChange{
+				Field: DiffFieldBuildTime,
+				From:  f.BUILD_TIME,
+				To:    t.BUILD_TIME,
+			})
+			ans.Rebuilt = append(ans.Rebuilt, change)
+		case len(change.Fields) > 0:
+			ans.Rebuilt = append(ans.Rebuilt, change)
+		}
+	}
+
+	sort.Strings(ans.Added)
+	sort.Strings(ans.Removed)
+	for _, changes := range [][]PackageChange{ans.Upgraded, ans.Downgraded, ans.Rebuilt} {
+		sort.Slice(changes, func(i, j int) bool {
+			return changes[i].Package < changes[j].Package
+		})
+	}
+
+	return ans, nil
+}
+
+// IsEmpty returns true if there aren't differences.
+func (d *VdbDiff) IsEmpty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Upgraded) == 0 &&
+		len(d.Downgraded) == 0 && len(d.Rebuilt) == 0
+}
+
+func (d *VdbDiff) sections() []struct {
+	title   string
+	pkgs    []string
+	changes []PackageChange
+} {
+	return []struct {
+		title   string
+		pkgs    []string
+		changes []PackageChange
+	}{
+		{title: "Added", pkgs: d.Added},
+		{title: "Removed", pkgs: d.Removed},
+		{title: "Upgraded", changes: d.Upgraded},
+		{title: "Downgraded", changes: d.Downgraded},
+		{title: "Rebuilt", changes: d.Rebuilt},
+	}
+}
+
+// Text returns the differences in a human readable format.
+func (d *VdbDiff) Text() string {
+	var b strings.Builder
+
+	for _, s := range d.sections() {
+		if len(s.pkgs) == 0 && len(s.changes) == 0 {
+			continue
+		}
+		b.WriteString(fmt.Sprintf("%s (%d):\n", s.title, len(s.pkgs)+len(s.changes)))
+		for _, p := range s.pkgs {
+			b.WriteString(fmt.Sprintf("  %s\n", p))
+		}
+		for _, c := range s.changes {
+			b.WriteString(fmt.Sprintf("  %s -> %s\n", c.From, c.To))
+			for _, f := range c.Fields {
+				b.WriteString(fmt.Sprintf("      %s\n", f.String()))
+			}
+		}
+	}
+
+	return b.String()
+}
+
+// Markdown returns the differences in Markdown format for the
+// release notes.
+func (d *VdbDiff) Markdown() string {
+	var b strings.Builder
+
+	for _, s := range d.sections() {
+		if len(s.pkgs) == 0 && len(s.changes) == 0 {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(fmt.Sprintf("### %s\n\n", s.title))
+		for _, p := range s.pkgs {
+			b.WriteString(fmt.Sprintf("- `%s`\n", p))
+		}
+		for _, c := range s.changes {
+			b.WriteString(fmt.Sprintf("- `%s` → `%s`\n", c.From, c.To))
+			for _, f := range c.Fields {
+				b.WriteString(fmt.Sprintf("  - %s\n", f.String()))
+			}
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,188 @@
+/*
+
+Copyright (C) 2017-2021  Daniele Rondina <geaaru@sabayonlinux.org>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+*/
+
+package gentoo_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/Sabayon/pkgs-checker/pkg/gentoo"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Gentoo vdb diff", func() {
+
+	var tmpdir string
+	var diff *VdbDiff
+
+	BeforeEach(func() {
+		var err error
+		tmpdir, err = ioutil.TempDir("", "diff")
+		Expect(err).Should(BeNil())
+
+		from := filepath.Join(tmpdir, "from")
+		to := filepath.Join(tmpdir, "to")
+
+		writeVdbPackage(from, "dev-libs/openssl-1.1.1k", map[string]string{
+			"SLOT":    "0/1.1",
+			"COUNTER": "10",
+			"USE":     "asm zlib",
+		})
+		writeVdbPackage(to, "dev-libs/openssl-1.1.1l", map[string]string{
+			"SLOT":    "0/1.1",
+			"COUNTER": "20",
+			"USE":     "asm sslv3",
+		})
+		writeVdbPackage(from, "sys-libs/zlib-1.2.11-r2", map[string]string{
+			"COUNTER": "11",
+			"CFLAGS":  "-O2 -pipe",
+		})
+		writeVdbPackage(to, "sys-libs/zlib-1.2.11-r2", map[string]string{
+			"COUNTER": "21",
+			"CFLAGS":  "-O3 -pipe",
+		})
+		writeVdbPackage(from, "app-misc/stable-1.0", map[string]string{
+			"COUNTER":    "12",
+			"BUILD_TIME": "1620000000",
+		})
+		writeVdbPackage(to, "app-misc/stable-1.0", map[string]string{
+			"COUNTER":    "32",
+			"BUILD_TIME": "1620000000",
+		})
+		writeVdbPackage(from, "app-misc/rebuilt-1.0", map[string]string{
+			"COUNTER":    "13",
+			"BUILD_TIME": "1620000000",
+		})
+		writeVdbPackage(to, "app-misc/rebuilt-1.0", map[string]string{
+			"COUNTER":    "33",
+			"BUILD_TIME": "1630000000",
+		})
+		writeVdbPackage(from, "dev-lang/python-3.9.5", map[string]string{
+			"SLOT":       "3.9/3.9",
+			"KEYWORDS":   "amd64 ~arm",
+			"repository": "gentoo",
+		})
+		writeVdbPackage(to, "dev-lang/python-3.9.4", map[string]string{
+			"SLOT":       "3.9/3.9",
+			"KEYWORDS":   "amd64 arm",
+			"repository": "sabayon",
+		})
+		writeVdbPackage(to, "dev-lang/python-3.10.0", map[string]string{
+			"SLOT": "3.10/3.10",
+		})
+		writeVdbPackage(from, "app-misc/old-1.0", map[string]string{})
+
+		fromPkgs, err := NewVdbScanner(from, nil).Scan()
+		Expect(err).Should(BeNil())
+		toPkgs, err := NewVdbScanner(to, nil).Scan()
+		Expect(err).Should(BeNil())
+
+		diff, err = DiffMetadata(fromPkgs, toPkgs)
+		Expect(err).Should(BeNil())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tmpdir)
+	})
+
+	It("Check added and removed packages", func() {
+		Expect(diff.Added).Should(Equal([]string{"dev-lang/python-3.10.0"}))
+		Expect(diff.Removed).Should(Equal([]string{"app-misc/old-1.0"}))
+	})
+
+	It("Check upgraded packages", func() {
+		Expect(diff.Upgraded).Should(Equal([]PackageChange{
+			{
+				Package: "dev-libs/openssl:0",
+				From:    "dev-libs/openssl-1.1.1k",
+				To:      "dev-libs/openssl-1.1.1l",
+				Fields: []FieldChange{
+					{
+						Field:   DiffFieldUse,
+						From:    "asm zlib",
+						To:      "asm sslv3",
+						Added:   []string{"sslv3"},
+						Removed: []string{"zlib"},
+					},
+				},
+			},
+		}))
+	})
+
+	It("Check downgraded packages", func() {
+		Expect(len(diff.Downgraded)).Should(Equal(1))
+		Expect(diff.Downgraded[0].Package).Should(Equal("dev-lang/python:3.9"))
+		Expect(diff.Downgraded[0].Fields).Should(Equal([]FieldChange{
+			{
+				Field:   DiffFieldKeywords,
+				From:    "amd64 ~arm",
+				To:      "amd64 arm",
+				Added:   []string{"arm"},
+				Removed: []string{"~arm"},
+			},
+			{Field: DiffFieldRepository, From: "gentoo", To: "sabayon"},
+		}))
+	})
+
+	It("Check rebuilt packages", func() {
+		Expect(diff.Rebuilt).Should(Equal([]PackageChange{
+			{
+				Package: "app-misc/rebuilt:0",
+				From:    "app-misc/rebuilt-1.0",
+				To:      "app-misc/rebuilt-1.0",
+				Fields: []FieldChange{
+					{Field: DiffFieldBuildTime, From: "1620000000", To: "1630000000"},
+				},
+			},
+			{
+				Package: "sys-libs/zlib:0",
+				From:    "sys-libs/zlib-1.2.11-r2",
+				To:      "sys-libs/zlib-1.2.11-r2",
+				Fields: []FieldChange{
+					{Field: DiffFieldCFlags, From: "-O2 -pipe", To: "-O3 -pipe"},
+				},
+			},
+		}))
+	})
+
+	It("Check markdown output", func() {
+		Expect(diff.Markdown()).Should(Equal("### Added\n\n" +
+			"- `dev-lang/python-3.10.0`\n\n" +
+			"### Removed\n\n" +
+			"- `app-misc/old-1.0`\n\n" +
+			"### Upgraded\n\n" +
+			"- `dev-libs/openssl-1.1.1k` → `dev-libs/openssl-1.1.1l`\n" +
+			"  - USE: +sslv3 -zlib\n\n" +
+			"### Downgraded\n\n" +
+			"- `dev-lang/python-3.9.5` → `dev-lang/python-3.9.4`\n" +
+			"  - KEYWORDS: +arm -~arm\n" +
+			"  - repository: \"gentoo\" -> \"sabayon\"\n\n" +
+			"### Rebuilt\n\n" +
+			"- `app-misc/rebuilt-1.0` → `app-misc/rebuilt-1.0`\n" +
+			"  - BUILD_TIME: \"1620000000\" -> \"1630000000\"\n" +
+			"- `sys-libs/zlib-1.2.11-r2` → `sys-libs/zlib-1.2.11-r2`\n" +
+			"  - CFLAGS: \"-O2 -pipe\" -> \"-O3 -pipe\"\n"))
+		Expect(diff.IsEmpty()).Should(BeFalse())
+	})
+
+})
@@ -0,0 +1,61 @@
+//go:build go1.18
+// +build go1.18
+
+/*
+
+Copyright (C) 2017-2021  Daniele Rondina <geaaru@sabayonlinux.org>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+*/
+
+package gentoo_test
+
+import (
+	"os"
+	"reflect"
+	"regexp"
+	"testing"
+
+	. "github.com/Sabayon/pkgs-checker/pkg/gentoo"
+)
+
+// FuzzFormat checks that ParsePackageStr(x.Format()) == x. The corpus is
+// composed by the atoms used in pkg_test.go.
+func FuzzFormat(f *testing.F) {
+	data, err := os.ReadFile("pkg_test.go")
+	if err != nil {
+		f.Fatal(err)
+	}
+	r := regexp.MustCompile(`ParsePackageStr\("([^"]*)"\)`)
+	for _, m := range r.FindAllStringSubmatch(string(data), -1) {
+		f.Add(m[1])
+	}
+
+	f.Fuzz(func(t *testing.T, atom string) {
+		gp, err := ParsePackageStr(atom)
+		if err != nil {
+			return
+		}
+
+		formatted := gp.Format()
+		gp2, err := ParsePackageStr(formatted)
+		if err != nil {
+			t.Fatalf("%s formatted as %s: %s", atom, formatted, err.Error())
+		}
+		if !reflect.DeepEqual(gp, gp2) {
+			t.Fatalf("%s formatted as %s: %+v != %+v", atom, formatted, *gp, *gp2)
+		}
+	})
+}
@@ -0,0 +1,109 @@
+/*
+
+Copyright (C) 2017-2021  Daniele Rondina <geaaru@sabayonlinux.org>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+*/
+
+
+package gentoo_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	. "github.com/Sabayon/pkgs-checker/pkg/gentoo"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Gentoo Format", func() {
+
+	DescribeTable("Format",
+		func(atom, expected string) {
+			gp, err := ParsePackageStr(atom)
+			Expect(err).Should(BeNil())
+			Expect(gp.Format()).Should(Equal(expected))
+
+			gp2, err := ParsePackageStr(gp.Format())
+			Expect(err).Should(BeNil())
+			Expect(*gp2).Should(Equal(*gp))
+		},
+		Entry("name", "x11-libs/gtk+", "x11-libs/gtk+"),
+		Entry("version", "app-misc/foo-1.0-r1", "=app-misc/foo-1.0-r1"),
+		Entry("condition", ">=sys-libs/ncurses-5.2-r5:0=", ">=sys-libs/ncurses-5.2-r5:0="),
+		Entry("glob", "=dev-lang/python-3.9*:3.9/3.9", "=dev-lang/python-3.9*:3.9/3.9"),
+		Entry("any revision", "~sys-devel/gdb-7.3", "~sys-devel/gdb-7.3"),
+		Entry("blocker", "!<app-misc/foo-1.0", "!<app-misc/foo-1.0"),
+		Entry("slot zero", "dev-db/sqlite:0", "dev-db/sqlite"),
+		Entry("any slot", "dev-libs/openssl:*", "dev-libs/openssl:*"),
+		Entry("repository", "=media-libs/mesa-9999::x11", "=media-libs/mesa-9999::x11"),
+		Entry("use flags", "media-libs/mesa:0/21[vulkan,-llvm,abi_x86_32(-)?]",
+			"media-libs/mesa:0/21[vulkan,-llvm,abi_x86_32(-)?]"),
+		Entry("build", "app/A-1.0+1", "=app/A-1.0+1"),
+		Entry("full", ">=app/A-1.0_p1-r2+2.1:1/2=::foo[bar]", ">=app/A-1.0_p1-r2+2.1:1/2=::foo[bar]"),
+	)
+
+	It("Check invalid empty slot", func() {
+		_, err := ParsePackageStr("app-misc/foo:")
+		Expect(err).ShouldNot(BeNil())
+	})
+
+	// Same corpus of FuzzFormat: the atoms used in pkg_test.go and the
+	// inputs found by the fuzzer under testdata/fuzz/FuzzFormat.
+	It("Check round trip of the fuzz corpus", func() {
+		atoms := []string{}
+
+		data, err := ioutil.ReadFile("pkg_test.go")
+		Expect(err).Should(BeNil())
+		r := regexp.MustCompile(`ParsePackageStr\("([^"]*)"\)`)
+		for _, m := range r.FindAllStringSubmatch(string(data), -1) {
+			atoms = append(atoms, m[1])
+		}
+
+		files, err := filepath.Glob(filepath.Join("testdata", "fuzz", "FuzzFormat", "*"))
+		Expect(err).Should(BeNil())
+		Expect(len(files)).ShouldNot(Equal(0))
+		for _, f := range files {
+			data, err := ioutil.ReadFile(f)
+			Expect(err).Should(BeNil())
+			for _, line := range strings.Split(string(data), "\n") {
+				if strings.HasPrefix(line, "string(") {
+					atom, err := strconv.Unquote(
+						strings.TrimSuffix(strings.TrimPrefix(line, "string("), ")"))
+					Expect(err).Should(BeNil())
+					atoms = append(atoms, atom)
+				}
+			}
+		}
+
+		for _, atom := range atoms {
+			gp, err := ParsePackageStr(atom)
+			if err != nil {
+				continue
+			}
+
+			gp2, err := ParsePackageStr(gp.Format())
+			Expect(err).Should(BeNil(), atom)
+			Expect(*gp2).Should(Equal(*gp), atom)
+		}
+	})
+
+})
@@ -0,0 +1,224 @@
+/*
+
+Copyright (C) 2017-2021  Daniele Rondina <geaaru@sabayonlinux.org>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+*/
+
+package gentoo
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/Sabayon/pkgs-checker/pkg/compress"
+)
+
+// GPKG format of the binary packages as described by GLEP 78:
+// https://www.gentoo.org/glep/glep-0078.html
+//
+// The .gpkg.tar file is an uncompressed tarball with the members
+//   <basename>/gpkg-1
+//   <basename>/metadata.tar[.<compr>]
+//   <basename>/image.tar[.<compr>]
+// The metadata tarball contains the files under metadata/ and the
+// image tarball the files of the package under image/.
+
+const (
+	Tbz2Extension = ".tbz2"
+	XpakExtension = ".xpak"
+	GpkgExtension = ".gpkg.tar"
+
+	GpkgMetadataDir = "metadata"
+	GpkgImageDir    = "image"
+)
+
+// BinPkgExtensions are the extensions of the supported binary packages.
+var BinPkgExtensions = []string{
+	GpkgExtension,
+	Tbz2Extension,
+	XpakExtension,
+}
+
+// With FEATURES=binpkg-multi-instance the binary packages are stored
+// as <category>/<pn>/<pf>-<buildid>.<ext>. The build id is dropped from
+// the name only after a valid version: a package name can't end with
+// a version.
+var regexBinPkgBuildId = regexp.MustCompile(
+	`^(.+-` + RegexVersionString + `(?:\+[0-9A-Za-z_.]+)?)-[0-9]+$`)
+
+// IsBinPkg returns true if the file has the extension of a binary
+// package.
+func IsBinPkg(file string) bool {
+	return GetBinPkgExtension(file) != ""
+}
+
+// IsGpkg returns true if the file is a GPKG binary package.
+func IsGpkg(file string) bool {
+	return strings.HasSuffix(file, GpkgExtension)
+}
+
+// GetBinPkgExtension returns the extension of the binary package or an
+// empty string.
+func GetBinPkgExtension(file string) string {
+	for _, ext := range BinPkgExtensions {
+		if strings.HasSuffix(file, ext) {
+			return ext
+		}
+	}
+	return ""
+}
+
+// GetBinPkgName returns the name of the package (<pf>) of a binary
+// package file without the build id of the multi-instance packages.
+// An unknown extension is dropped too.
+func GetBinPkgName(file string) string {
+	base := filepath.Base(file)
+	if ext := GetBinPkgExtension(base); ext != "" {
+		base = strings.TrimSuffix(base, ext)
+	} else {
+		base = strings.TrimSuffix(base, filepath.Ext(base))
+	}
+
+	if m := regexBinPkgBuildId.FindStringSubmatch(base); m != nil {
+		return m[1]
+	}
+	return base
+}
+
+// walkGpkgMember calls the callback with the entries of the tarball
+// of the member of the GPKG (metadata or image) decompressed.
+func walkGpkgMember(file, member string, f func(h *tar.Header, r io.Reader) error) error {
+	fd, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+
+	outer := tar.NewReader(fd)
+	for {
+		h, err := outer.Next()
+		if err == io.EOF {
+			return errors.New(
+				fmt.Sprintf("Member %s not found in %s", member, file))
+		}
+		if err != nil {
+			return err
+		}
+
+		name := path.Base(h.Name)
+		if h.Typeflag != tar.TypeReg ||
+			(name != member+".tar" && !strings.HasPrefix(name, member+".tar.")) {
+			continue
+		}
+
+		r, _, err := compress.NewReader(outer)
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+
+		inner := tar.NewReader(r)
+		for {
+			ih, err := inner.Next()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return errors.New(
+					fmt.Sprintf("Error on read %s of %s: %s", name, file, err.Error()))
+			}
+
+			err = f(ih, inner)
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// WalkGpkgImage calls the callback with the entries of the image of
+// a GPKG. The names of the entries are rewritten as the entries of a
+// tbz2 (./usr/bin/foo) and the image directory is returned as ./ so
+// that the same image has the same checksum in both formats.
+func WalkGpkgImage(file string, f func(h *tar.Header, r io.Reader) error) error {
+	return walkGpkgMember(file, GpkgImageDir, func(h *tar.Header, r io.Reader) error {
+		name := strings.TrimPrefix(h.Name, "./")
+		name = strings.TrimPrefix(name, GpkgImageDir)
+		name = strings.TrimPrefix(name, "/")
+		h.Name = "./" + name
+		return f(h, r)
+	})
+}
+
+// ReadGpkgMetadataFiles returns the files of the metadata of a GPKG.
+func ReadGpkgMetadataFiles(file string) (map[string][]byte, error) {
+	ans := make(map[string][]byte, 0)
+
+	err := walkGpkgMember(file, GpkgMetadataDir, func(h *tar.Header, r io.Reader) error {
+		if h.Typeflag != tar.TypeReg {
+			return nil
+		}
+		name := strings.TrimPrefix(h.Name, "./")
+		name = strings.TrimPrefix(name, GpkgMetadataDir+"/")
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		ans[name] = data
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ans, nil
+}
+
+// ParseGpkgMetadata returns the metadata of a GPKG binary package.
+func ParseGpkgMetadata(file string, opts *PortageUseParseOpts) (*PortageMetaData, error) {
+	files, err := ReadGpkgMetadataFiles(file)
+	if err != nil {
+		return nil, err
+	}
+	return XpakData(files).ToMetadata(opts)
+}
+
+// ParseBinPkgMetadata returns the metadata of a .tbz2 or a .gpkg.tar
+// binary package.
+func ParseBinPkgMetadata(file string, opts *PortageUseParseOpts) (*PortageMetaData, error) {
+	if IsGpkg(file) {
+		return ParseGpkgMetadata(file, opts)
+	}
+	return ParseTbz2Metadata(file, opts)
+}
+
+// ReadBinPkgMetadataFiles returns the metadata files of a .tbz2 or a
+// .gpkg.tar binary package.
+func ReadBinPkgMetadataFiles(file string) (XpakData, error) {
+	if IsGpkg(file) {
+		files, err := ReadGpkgMetadataFiles(file)
+		return XpakData(files), err
+	}
+	return ReadXpakFile(file)
+}
@@ -0,0 +1,148 @@
+/*
+
+Copyright (C) 2017-2021  Daniele Rondina <geaaru@sabayonlinux.org>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+*/
+
+package gentoo_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/Sabayon/pkgs-checker/pkg/gentoo"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type tarEntry struct {
+	name    string
+	content string
+	dir     bool
+}
+
+func createTar(entries []tarEntry) []byte {
+	var buf bytes.Buffer
+	w := tar.NewWriter(&buf)
+	for _, e := range entries {
+		h := &tar.Header{Name: e.name, Mode: 0644, Size: int64(len(e.content))}
+		if e.dir {
+			h.Typeflag = tar.TypeDir
+			h.Mode = 0755
+		}
+		Expect(w.WriteHeader(h)).Should(BeNil())
+		w.Write([]byte(e.content))
+	}
+	Expect(w.Close()).Should(BeNil())
+	return buf.Bytes()
+}
+
+func gzipData(data []byte) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write(data)
+	w.Close()
+	return buf.Bytes()
+}
+
+var _ = Describe("Gentoo GPKG", func() {
+
+	var tmpdir, file string
+
+	BeforeEach(func() {
+		var err error
+		tmpdir, err = ioutil.TempDir("", "gpkg")
+		Expect(err).Should(BeNil())
+
+		metadata := createTar([]tarEntry{
+			{name: "metadata", dir: true},
+			{name: "metadata/CATEGORY", content: "app-misc\n"},
+			{name: "metadata/PF", content: "foo-1.0-r1\n"},
+			{name: "metadata/SLOT", content: "2\n"},
+			{name: "metadata/IUSE", content: "bar\n"},
+			{name: "metadata/IUSE_EFFECTIVE", content: "bar\n"},
+			{name: "metadata/USE", content: "bar\n"},
+			{name: "metadata/repository", content: "gentoo\n"},
+		})
+		image := createTar([]tarEntry{
+			{name: "image/", dir: true},
+			{name: "image/usr/", dir: true},
+			{name: "image/usr/bin/foo", content: "#!/bin/sh\n"},
+		})
+
+		file = filepath.Join(tmpdir, "foo-1.0-r1.gpkg.tar")
+		Expect(ioutil.WriteFile(file, createTar([]tarEntry{
+			{name: "foo-1.0-r1/gpkg-1"},
+			{name: "foo-1.0-r1/metadata.tar.gz", content: string(gzipData(metadata))},
+			{name: "foo-1.0-r1/image.tar", content: string(image)},
+		}), 0644)).Should(BeNil())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tmpdir)
+	})
+
+	It("Check binary package names", func() {
+		Expect(IsBinPkg("foo-1.0.tbz2")).Should(BeTrue())
+		Expect(IsBinPkg("foo-1.0.gpkg.tar")).Should(BeTrue())
+		Expect(IsBinPkg("foo-1.0.tar")).Should(BeFalse())
+		Expect(GetBinPkgName("/bin/app-misc/foo-1.0-r1.gpkg.tar")).Should(Equal("foo-1.0-r1"))
+		Expect(GetBinPkgName("/bin/app-misc/foo-1.0-r1.tbz2")).Should(Equal("foo-1.0-r1"))
+		Expect(GetBinPkgName("/bin/app-misc/foo/foo-1.0-r1-2.gpkg.tar")).Should(Equal("foo-1.0-r1"))
+		Expect(GetBinPkgName("/bin/app-misc/foo-1.0-1.gpkg.tar")).Should(Equal("foo-1.0"))
+		Expect(GetBinPkgName("/bin/app-misc/foo/foo-1.0_rc1+2-3.xpak")).Should(Equal("foo-1.0_rc1+2"))
+		Expect(GetBinPkgName("/bin/media-fonts/font-100dpi-1.gpkg.tar")).Should(Equal("font-100dpi-1"))
+	})
+
+	It("Check metadata", func() {
+		pm, err := ParseBinPkgMetadata(file, nil)
+		Expect(err).Should(BeNil())
+		Expect(pm.GetPackageNameWithVersion()).Should(Equal("app-misc/foo-1.0-r1"))
+		Expect(pm.Slot).Should(Equal("2"))
+		Expect(pm.Repository).Should(Equal("gentoo"))
+		Expect(pm.UseFlags).Should(Equal([]string{"bar"}))
+	})
+
+	It("Check image", func() {
+		names := []string{}
+		err := WalkGpkgImage(file, func(h *tar.Header, r io.Reader) error {
+			names = append(names, h.Name)
+			if h.Typeflag == tar.TypeReg {
+				data, err := ioutil.ReadAll(r)
+				Expect(err).Should(BeNil())
+				Expect(string(data)).Should(Equal("#!/bin/sh\n"))
+			}
+			return nil
+		})
+		Expect(err).Should(BeNil())
+		Expect(names).Should(Equal([]string{"./", "./usr/", "./usr/bin/foo"}))
+	})
+
+	It("Check missing member", func() {
+		Expect(ioutil.WriteFile(file, createTar([]tarEntry{
+			{name: "foo-1.0-r1/gpkg-1"},
+		}), 0644)).Should(BeNil())
+		_, err := ParseGpkgMetadata(file, nil)
+		Expect(err).ShouldNot(BeNil())
+	})
+
+})
@@ -0,0 +1,158 @@
+/*
+
+Copyright (C) 2017-2021  Daniele Rondina <geaaru@sabayonlinux.org>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+*/
+
+package gentoo
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+type KeywordState int
+
+const (
+	// arch
+	KeywordStable KeywordState = iota
+	// ~arch
+	KeywordTesting
+	// -arch or -*
+	KeywordUnavailable
+	// arch not present
+	KeywordUnkeyworded
+)
+
+const (
+	KeywordAll = "*"
+)
+
+type GentooKeyword struct {
+	Arch  string       `json:"arch"`
+	State KeywordState `json:"state"`
+}
+
+type GentooKeywords struct {
+	Keywords []GentooKeyword `json:"keywords"`
+}
+
+var regexKeywordValid = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_-]*$`)
+
+func (s KeywordState) String() (ans string) {
+	switch s {
+	case KeywordStable:
+		ans = "stable"
+	case KeywordTesting:
+		ans = "testing"
+	case KeywordUnavailable:
+		ans = "unavailable"
+	case KeywordUnkeyworded:
+		ans = "unkeyworded"
+	}
+	return
+}
+
+// ParseKeywords parses a KEYWORDS string like "amd64 ~arm -sparc -*".
+func ParseKeywords(keywords string) (*GentooKeywords, error) {
+	ans := &GentooKeywords{
+		Keywords: []GentooKeyword{},
+	}
+
+	for _, k := range strings.Fields(keywords) {
+		kw := GentooKeyword{State: KeywordStable, Arch: k}
+
+		if strings.HasPrefix(k, "~") {
+			kw.State = KeywordTesting
+			kw.Arch = k[1:]
+		} else if strings.HasPrefix(k, "-") {
+			kw.State = KeywordUnavailable
+			kw.Arch = k[1:]
+		}
+
+		if kw.Arch == KeywordAll {
+			if kw.State != KeywordUnavailable {
+				return nil, errors.New(
+					fmt.Sprintf("Invalid keyword %s", k))
+			}
+		} else if !regexKeywordValid.MatchString(kw.Arch) {
+			return nil, errors.New(
+				fmt.Sprintf("Invalid keyword %s", k))
+		}
+
+		ans.Keywords = append(ans.Keywords, kw)
+	}
+
+	return ans, nil
+}
+
+// GetState returns the state of the arch. An arch not listed is
+// unavailable when -* is present and unkeyworded otherwise.
+func (k *GentooKeywords) GetState(arch string) KeywordState {
+	ans := KeywordUnkeyworded
+	for _, kw := range k.Keywords {
+		if kw.Arch == arch {
+			return kw.State
+		}
+		if kw.Arch == KeywordAll {
+			ans = KeywordUnavailable
+		}
+	}
+	return ans
+}
+
+func (k *GentooKeywords) IsStable(arch string) bool {
+	return k.GetState(arch) == KeywordStable
+}
+
+func (k *GentooKeywords) IsTesting(arch string) bool {
+	return k.GetState(arch) == KeywordTesting
+}
+
+func (k *GentooKeywords) GetArchs(state KeywordState) []string {
+	ans := []string{}
+	for _, kw := range k.Keywords {
+		if kw.State == state && kw.Arch != KeywordAll {
+			ans = append(ans, kw.Arch)
+		}
+	}
+	return ans
+}
+
+func (kw GentooKeyword) String() string {
+	switch kw.State {
+	case KeywordTesting:
+		return "~" + kw.Arch
+	case KeywordUnavailable:
+		return "-" + kw.Arch
+	}
+	return kw.Arch
+}
+
+func (k *GentooKeywords) String() string {
+	ans := []string{}
+	for _, kw := range k.Keywords {
+		ans = append(ans, kw.String())
+	}
+	return strings.Join(ans, " ")
+}
+
+// GetKeywords parses the KEYWORDS of the package.
+func (m *PortageMetaData) GetKeywords() (*GentooKeywords, error) {
+	return ParseKeywords(m.KEYWORDS)
+}
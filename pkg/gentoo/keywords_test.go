@@ -0,0 +1,90 @@
+/*
+
+Copyright (C) 2017-2021  Daniele Rondina <geaaru@sabayonlinux.org>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+*/
+
+
+package gentoo_test
+
+import (
+	. "github.com/Sabayon/pkgs-checker/pkg/gentoo"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Gentoo Keywords", func() {
+
+	Context("Parse keywords", func() {
+		keywords := "amd64 ~arm ~arm64 -sparc x86 -*"
+		kw, err := ParseKeywords(keywords)
+
+		It("Check error", func() {
+			Expect(err).Should(BeNil())
+		})
+
+		It("Check entries", func() {
+			Expect(len(kw.Keywords)).Should(Equal(6))
+			Expect(kw.Keywords[1]).Should(Equal(GentooKeyword{Arch: "arm", State: KeywordTesting}))
+			Expect(kw.Keywords[5]).Should(Equal(GentooKeyword{Arch: "*", State: KeywordUnavailable}))
+			Expect(kw.GetArchs(KeywordStable)).Should(Equal([]string{"amd64", "x86"}))
+			Expect(kw.GetArchs(KeywordTesting)).Should(Equal([]string{"arm", "arm64"}))
+			Expect(kw.GetArchs(KeywordUnavailable)).Should(Equal([]string{"sparc"}))
+		})
+
+		It("Check String", func() {
+			Expect(kw.String()).Should(Equal(keywords))
+		})
+	})
+
+	DescribeTable("Check state",
+		func(keywords, arch string, state KeywordState) {
+			kw, err := ParseKeywords(keywords)
+			Expect(err).Should(BeNil())
+			Expect(kw.GetState(arch)).Should(Equal(state))
+			Expect(kw.IsStable(arch)).Should(Equal(state == KeywordStable))
+			Expect(kw.IsTesting(arch)).Should(Equal(state == KeywordTesting))
+		},
+		Entry("stable", "amd64 ~arm", "amd64", KeywordStable),
+		Entry("testing", "amd64 ~arm", "arm", KeywordTesting),
+		Entry("unavailable", "amd64 -arm", "arm", KeywordUnavailable),
+		Entry("unavailable with -*", "amd64 -*", "arm", KeywordUnavailable),
+		Entry("explicit with -*", "-* ~arm", "arm", KeywordTesting),
+		Entry("unkeyworded", "amd64 ~x86", "arm", KeywordUnkeyworded),
+		Entry("empty", "", "arm", KeywordUnkeyworded),
+	)
+
+	DescribeTable("Invalid keywords",
+		func(keywords string) {
+			_, err := ParseKeywords(keywords)
+			Expect(err).ShouldNot(BeNil())
+		},
+		Entry("testing all", "~*"),
+		Entry("all", "*"),
+		Entry("empty testing", "~"),
+		Entry("invalid arch", "amd64 ~arm/linux"),
+	)
+
+	It("Check state names", func() {
+		Expect(KeywordStable.String()).Should(Equal("stable"))
+		Expect(KeywordTesting.String()).Should(Equal("testing"))
+		Expect(KeywordUnavailable.String()).Should(Equal("unavailable"))
+		Expect(KeywordUnkeyworded.String()).Should(Equal("unkeyworded"))
+	})
+
+})
@@ -0,0 +1,402 @@
+/*
+
+Copyright (C) 2017-2021  Daniele Rondina <geaaru@sabayonlinux.org>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+*/
+
+package gentoo
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// Parser of the LICENSE variable as described by PMS section 8.2.
+// The LICENSE syntax supports only the all-of, any-of and
+// USE-conditional groups.
+
+type GentooLicense struct {
+	Type GentooDependencyType `json:"type"`
+
+	// Valorized only for DepTypeAtom
+	Name string `json:"name,omitempty"`
+
+	// Valorized only for DepTypeUseConditional
+	Use        string `json:"use,omitempty"`
+	UseNegated bool   `json:"use_negated,omitempty"`
+
+	Children []*GentooLicense `json:"children,omitempty"`
+
+	root bool
+}
+
+// LicensePolicy evaluates the licenses against an ACCEPT_LICENSE
+// value and the license groups defined by the profiles/license_groups
+// file of the repository.
+type LicensePolicy struct {
+	Groups map[string][]string `json:"groups,omitempty"`
+	Accept string              `json:"accept_license"`
+
+	tokens []licensePolicyToken
+}
+
+type licensePolicyToken struct {
+	negated  bool
+	any      bool
+	licenses map[string]bool
+}
+
+const (
+	LicenseGroupPrefix = "@"
+)
+
+var licenseParser = &depSpecParser{
+	name:      "license",
+	operators: depSpecAnyOfOperators,
+	newGroup: func(t GentooDependencyType, use string, useNegated bool) depSpecNode {
+		return &GentooLicense{
+			Type:       t,
+			Use:        use,
+			UseNegated: useNegated,
+			Children:   []*GentooLicense{},
+		}
+	},
+	newLeaf: func(token string, pos int) (depSpecNode, error) {
+		if !regexNameValid.MatchString(token) {
+			return nil, errors.New(
+				fmt.Sprintf("Invalid license %s at position %d", token, pos))
+		}
+		return &GentooLicense{
+			Type: DepTypeAtom,
+			Name: token,
+		}, nil
+	},
+}
+
+// ParseLicense parses a LICENSE string and returns the root of the tree
+// as a DepTypeAllOf group.
+func ParseLicense(license string) (*GentooLicense, error) {
+	node, err := licenseParser.parse(license)
+	if err != nil {
+		return nil, err
+	}
+	ans := node.(*GentooLicense)
+	ans.root = true
+	return ans, nil
+}
+
+func (l *GentooLicense) addChild(child depSpecNode) {
+	l.Children = append(l.Children, child.(*GentooLicense))
+}
+
+// ReduceUse returns a new tree where the USE-conditional groups are
+// evaluated against the enabled USE flags.
+func (l *GentooLicense) ReduceUse(uses []string) *GentooLicense {
+	return l.reduceUse(newUseSet(uses))
+}
+
+func (l *GentooLicense) reduceUse(uses map[string]bool) *GentooLicense {
+	if l.Type == DepTypeAtom {
+		return l
+	}
+
+	ans := &GentooLicense{
+		Type:     l.Type,
+		Children: []*GentooLicense{},
+		root:     l.root,
+	}
+	if l.Type == DepTypeUseConditional {
+		if uses[l.Use] == l.UseNegated {
+			return nil
+		}
+		ans.Type = DepTypeAllOf
+	}
+
+	for _, c := range l.Children {
+		child := c.reduceUse(uses)
+		if child != nil {
+			ans.Children = append(ans.Children, child)
+		}
+	}
+
+	return ans
+}
+
+// GetLicenses returns the sorted list of the licenses of the tree
+// without duplicates.
+func (l *GentooLicense) GetLicenses() []string {
+	m := make(map[string]bool, 0)
+	l.walk(func(n *GentooLicense) {
+		if n.Type == DepTypeAtom {
+			m[n.Name] = true
+		}
+	})
+
+	ans := []string{}
+	for k, _ := range m {
+		ans = append(ans, k)
+	}
+	sort.Strings(ans)
+	return ans
+}
+
+func (l *GentooLicense) walk(f func(*GentooLicense)) {
+	f(l)
+	for _, c := range l.Children {
+		c.walk(f)
+	}
+}
+
+func (l *GentooLicense) String() string {
+	if l.Type == DepTypeAtom {
+		return l.Name
+	}
+
+	children := []string{}
+	for _, c := range l.Children {
+		children = append(children, c.String())
+	}
+	body := strings.Join(children, " ")
+	if l.root && l.Type == DepTypeAllOf {
+		return body
+	}
+
+	group := "( )"
+	if body != "" {
+		group = "( " + body + " )"
+	}
+
+	switch l.Type {
+	case DepTypeAnyOf:
+		return "|| " + group
+	case DepTypeUseConditional:
+		neg := ""
+		if l.UseNegated {
+			neg = "!"
+		}
+		return neg + l.Use + "? " + group
+	}
+
+	return group
+}
+
+// ParseLicenseGroups parses the content of a license_groups file.
+// Every line contains the name of the group followed by the licenses
+// and the nested groups with the @ prefix.
+func ParseLicenseGroups(data []byte) (map[string][]string, error) {
+	ans := make(map[string][]string, 0)
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	nline := 0
+	for scanner.Scan() {
+		nline++
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[0:i]
+		}
+
+		words := strings.Fields(line)
+		if len(words) == 0 {
+			continue
+		}
+
+		if _, ok := ans[words[0]]; ok {
+			return nil, errors.New(
+				fmt.Sprintf("Group %s defined multiple times at line %d", words[0], nline))
+		}
+		ans[words[0]] = words[1:]
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return ans, nil
+}
+
+// LoadLicenseGroups reads the license groups from a license_groups file.
+func LoadLicenseGroups(file string) (map[string][]string, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return ParseLicenseGroups(data)
+}
+
+// NewLicensePolicy creates a policy from the license groups and an
+// ACCEPT_LICENSE value like "* -@EULA" or "-* @FREE". Like in portage
+// the last matching token wins and a license without matching tokens
+// is not accepted.
+func NewLicensePolicy(groups map[string][]string, accept string) (*LicensePolicy, error) {
+	if groups == nil {
+		groups = make(map[string][]string, 0)
+	}
+
+	ans := &LicensePolicy{
+		Groups: groups,
+		Accept: accept,
+		tokens: []licensePolicyToken{},
+	}
+
+	for _, t := range strings.Fields(accept) {
+		token := licensePolicyToken{}
+		if strings.HasPrefix(t, "-") {
+			token.negated = true
+			t = t[1:]
+		}
+
+		switch {
+		case t == "*":
+			token.any = true
+		case strings.HasPrefix(t, LicenseGroupPrefix):
+			licenses, err := ans.ExpandGroup(t[1:])
+			if err != nil {
+				return nil, err
+			}
+			token.licenses = make(map[string]bool, len(licenses))
+			for _, l := range licenses {
+				token.licenses[l] = true
+			}
+		case t == "":
+			return nil, errors.New("Invalid empty token in ACCEPT_LICENSE")
+		default:
+			token.licenses = map[string]bool{t: true}
+		}
+
+		ans.tokens = append(ans.tokens, token)
+	}
+
+	return ans, nil
+}
+
+// ExpandGroup returns the sorted list of licenses of a group
+// resolving the nested groups.
+func (p *LicensePolicy) ExpandGroup(group string) ([]string, error) {
+	m := make(map[string]bool, 0)
+	err := p.expandGroup(group, m, map[string]bool{})
+	if err != nil {
+		return nil, err
+	}
+
+	ans := []string{}
+	for k, _ := range m {
+		ans = append(ans, k)
+	}
+	sort.Strings(ans)
+	return ans, nil
+}
+
+func (p *LicensePolicy) expandGroup(group string, ans, visited map[string]bool) error {
+	licenses, ok := p.Groups[group]
+	if !ok {
+		return errors.New(fmt.Sprintf("License group %s not found", group))
+	}
+	if visited[group] {
+		return errors.New(fmt.Sprintf("Cycle found on license group %s", group))
+	}
+	visited[group] = true
+
+	for _, l := range licenses {
+		if strings.HasPrefix(l, LicenseGroupPrefix) {
+			err := p.expandGroup(l[1:], ans, visited)
+			if err != nil {
+				return err
+			}
+		} else {
+			ans[l] = true
+		}
+	}
+
+	delete(visited, group)
+	return nil
+}
+
+// IsAccepted returns true if the license is accepted by the policy.
+func (p *LicensePolicy) IsAccepted(license string) bool {
+	ans := false
+	for _, t := range p.tokens {
+		if t.any || t.licenses[license] {
+			ans = !t.negated
+		}
+	}
+	return ans
+}
+
+// GetMissingLicenses evaluates the LICENSE tree with the USE flags
+// supplied and returns the licenses that must be accepted to satisfy
+// it. An empty list means that the package is accepted.
+// For the any-of groups not satisfied all the alternatives are returned.
+func (p *LicensePolicy) GetMissingLicenses(l *GentooLicense, uses []string) []string {
+	m := make(map[string]bool, 0)
+	p.missing(l.ReduceUse(uses), m)
+
+	ans := []string{}
+	for k, _ := range m {
+		ans = append(ans, k)
+	}
+	sort.Strings(ans)
+	return ans
+}
+
+func (p *LicensePolicy) missing(l *GentooLicense, ans map[string]bool) bool {
+	switch l.Type {
+	case DepTypeAtom:
+		if p.IsAccepted(l.Name) {
+			return true
+		}
+		ans[l.Name] = true
+		return false
+
+	case DepTypeAnyOf:
+		if len(l.Children) == 0 {
+			return true
+		}
+		alternatives := make(map[string]bool, 0)
+		for _, c := range l.Children {
+			if p.missing(c, alternatives) {
+				return true
+			}
+		}
+		for k, _ := range alternatives {
+			ans[k] = true
+		}
+		return false
+
+	default:
+		accepted := true
+		for _, c := range l.Children {
+			if !p.missing(c, ans) {
+				accepted = false
+			}
+		}
+		return accepted
+	}
+}
+
+// CheckLicense parses the LICENSE string and returns the missing
+// licenses with the USE flags supplied.
+func (p *LicensePolicy) CheckLicense(license string, uses []string) ([]string, error) {
+	l, err := ParseLicense(license)
+	if err != nil {
+		return nil, err
+	}
+	return p.GetMissingLicenses(l, uses), nil
+}
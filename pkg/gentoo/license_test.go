@@ -0,0 +1,143 @@
+/*
+
+Copyright (C) 2017-2021  Daniele Rondina <geaaru@sabayonlinux.org>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+*/
+
+
+package gentoo_test
+
+import (
+	. "github.com/Sabayon/pkgs-checker/pkg/gentoo"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Gentoo License", func() {
+
+	groups, _ := ParseLicenseGroups([]byte(`
+# Comment
+GPL-COMPATIBLE GPL-2 GPL-3 LGPL-2.1 MIT
+FSF-APPROVED @GPL-COMPATIBLE Apache-2.0
+FREE @FSF-APPROVED @OSI-APPROVED
+OSI-APPROVED BSD # inline comment
+EULA NVIDIA-r2 Oracle-BCLA-JavaSE
+`))
+
+	Context("Parse license", func() {
+		license := "GPL-2 || ( MIT BSD ) ssl? ( openssl ) !bindist? ( NVIDIA-r2 )"
+		l, err := ParseLicense(license)
+
+		It("Check error", func() {
+			Expect(err).Should(BeNil())
+		})
+
+		It("Check tree", func() {
+			Expect(l.Type).Should(Equal(DepTypeAllOf))
+			Expect(len(l.Children)).Should(Equal(4))
+			Expect(l.Children[0].Name).Should(Equal("GPL-2"))
+			Expect(l.Children[1].Type).Should(Equal(DepTypeAnyOf))
+			Expect(l.Children[2].Type).Should(Equal(DepTypeUseConditional))
+			Expect(l.Children[2].Use).Should(Equal("ssl"))
+			Expect(l.Children[3].UseNegated).Should(BeTrue())
+		})
+
+		It("Check String", func() {
+			Expect(l.String()).Should(Equal(license))
+		})
+
+		It("Check licenses", func() {
+			Expect(l.GetLicenses()).Should(Equal([]string{
+				"BSD", "GPL-2", "MIT", "NVIDIA-r2", "openssl",
+			}))
+			Expect(l.ReduceUse([]string{"bindist"}).GetLicenses()).Should(Equal([]string{
+				"BSD", "GPL-2", "MIT",
+			}))
+		})
+	})
+
+	DescribeTable("Invalid license",
+		func(license string) {
+			_, err := ParseLicense(license)
+			Expect(err).ShouldNot(BeNil())
+		},
+		Entry("missing close", "|| ( MIT BSD"),
+		Entry("missing open", "ssl? openssl"),
+		Entry("unsupported group", "^^ ( MIT BSD )"),
+		Entry("invalid name", "MIT/BSD"),
+	)
+
+	Context("License groups", func() {
+		It("Check groups", func() {
+			Expect(len(groups)).Should(Equal(5))
+			Expect(groups["OSI-APPROVED"]).Should(Equal([]string{"BSD"}))
+		})
+
+		It("Check expand", func() {
+			p, err := NewLicensePolicy(groups, "")
+			Expect(err).Should(BeNil())
+			Expect(p.ExpandGroup("FREE")).Should(Equal([]string{
+				"Apache-2.0", "BSD", "GPL-2", "GPL-3", "LGPL-2.1", "MIT",
+			}))
+		})
+
+		It("Check duplicate group", func() {
+			_, err := ParseLicenseGroups([]byte("FREE MIT\nFREE BSD\n"))
+			Expect(err).ShouldNot(BeNil())
+		})
+
+		It("Check cycle", func() {
+			_, err := NewLicensePolicy(map[string][]string{
+				"A": []string{"@B"},
+				"B": []string{"@A"},
+			}, "@A")
+			Expect(err).ShouldNot(BeNil())
+		})
+
+		It("Check unknown group", func() {
+			_, err := NewLicensePolicy(groups, "@UNKNOWN")
+			Expect(err).ShouldNot(BeNil())
+		})
+	})
+
+	DescribeTable("Check license policy",
+		func(accept, license string, uses []string, missing []string) {
+			p, err := NewLicensePolicy(groups, accept)
+			Expect(err).Should(BeNil())
+			ans, err := p.CheckLicense(license, uses)
+			Expect(err).Should(BeNil())
+			Expect(ans).Should(Equal(missing))
+		},
+		Entry("accept all", "*", "NVIDIA-r2", nil, []string{}),
+		Entry("default", "* -@EULA", "NVIDIA-r2", nil, []string{"NVIDIA-r2"}),
+		Entry("default free", "* -@EULA", "GPL-2", nil, []string{}),
+		Entry("only free", "-* @FREE", "GPL-2 Apache-2.0", nil, []string{}),
+		Entry("only free with eula", "-* @FREE", "GPL-2 NVIDIA-r2", nil, []string{"NVIDIA-r2"}),
+		Entry("last token wins", "-* @FREE -MIT", "MIT", nil, []string{"MIT"}),
+		Entry("single license", "-* @FREE NVIDIA-r2", "NVIDIA-r2", nil, []string{}),
+		Entry("any-of satisfied", "-* @FREE", "|| ( NVIDIA-r2 MIT )", nil, []string{}),
+		Entry("any-of not satisfied", "-* @FREE", "|| ( NVIDIA-r2 Oracle-BCLA-JavaSE )", nil,
+			[]string{"NVIDIA-r2", "Oracle-BCLA-JavaSE"}),
+		Entry("use enabled", "-* @FREE", "MIT bindist? ( NVIDIA-r2 )", []string{"bindist"},
+			[]string{"NVIDIA-r2"}),
+		Entry("use disabled", "-* @FREE", "MIT bindist? ( NVIDIA-r2 )", []string{}, []string{}),
+		Entry("empty license", "-* @FREE", "", nil, []string{}),
+		Entry("nothing accepted", "", "MIT", nil, []string{"MIT"}),
+	)
+
+})
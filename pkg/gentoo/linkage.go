@@ -0,0 +1,363 @@
+/*
+
+Copyright (C) 2017-2021  Daniele Rondina <geaaru@sabayonlinux.org>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+*/
+
+package gentoo
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// NeededElf2Entry is a line of the NEEDED.ELF.2 file:
+// arch;file;soname;rpath;needed;multilib_category
+type NeededElf2Entry struct {
+	Arch             string   `json:"arch"`
+	File             string   `json:"file"`
+	Soname           string   `json:"soname,omitempty"`
+	Rpath            []string `json:"rpath,omitempty"`
+	Needed           []string `json:"needed,omitempty"`
+	MultilibCategory string   `json:"multilib_category,omitempty"`
+}
+
+// LinkageGraph maps the sonames provided and required by the
+// installed packages for every ABI (multilib category).
+type LinkageGraph struct {
+	Packages map[string]*LinkagePackage `json:"packages"`
+
+	// abi -> soname -> packages
+	providers map[string]map[string][]string
+	consumers map[string]map[string][]string
+}
+
+type LinkagePackage struct {
+	Package  *GentooPackage      `json:"package"`
+	Provides map[string][]string `json:"provides,omitempty"`
+	Requires map[string][]string `json:"requires,omitempty"`
+}
+
+// LinkageSoname is a soname of an ABI required or provided by
+// a package.
+type LinkageSoname struct {
+	Package string `json:"package"`
+	Abi     string `json:"abi"`
+	Soname  string `json:"soname"`
+}
+
+// ParseSonameMap parses the PROVIDES and REQUIRES files. Every line
+// contains the ABI followed by the sonames: "x86_64: libc.so.6 libz.so.1"
+func ParseSonameMap(data string) (map[string][]string, error) {
+	ans := make(map[string][]string, 0)
+
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		i := strings.Index(line, ":")
+		if i <= 0 {
+			return nil, errors.New(
+				fmt.Sprintf("Invalid soname line %s", line))
+		}
+
+		abi := line[0:i]
+		ans[abi] = append(ans[abi], strings.Fields(line[i+1:])...)
+	}
+
+	return ans, nil
+}
+
+// ParseNeededElf2 parses the NEEDED.ELF.2 file.
+func ParseNeededElf2(data string) ([]NeededElf2Entry, error) {
+	ans := []NeededElf2Entry{}
+
+	for _, line := range strings.Split(data, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		words := strings.Split(line, ";")
+		if len(words) < 5 {
+			return nil, errors.New(
+				fmt.Sprintf("Invalid NEEDED.ELF.2 line %s", line))
+		}
+
+		e := NeededElf2Entry{
+			Arch:   words[0],
+			File:   words[1],
+			Soname: words[2],
+		}
+		if words[3] != "" {
+			e.Rpath = strings.Split(words[3], ":")
+		}
+		if words[4] != "" {
+			e.Needed = strings.Split(words[4], ",")
+		}
+		if len(words) > 5 {
+			e.MultilibCategory = words[5]
+		}
+
+		ans = append(ans, e)
+	}
+
+	return ans, nil
+}
+
+func NewLinkageGraph() *LinkageGraph {
+	return &LinkageGraph{
+		Packages:  make(map[string]*LinkagePackage, 0),
+		providers: make(map[string]map[string][]string, 0),
+		consumers: make(map[string]map[string][]string, 0),
+	}
+}
+
+// BuildLinkageGraph creates the graph of the packages of the vdb of
+// the scanner.
+func BuildLinkageGraph(s *VdbScanner) (*LinkageGraph, error) {
+	ans := NewLinkageGraph()
+
+	scanner, err := s.WithFields(MetaFieldSlot, MetaFieldNeededElf2,
+		MetaFieldProvides, MetaFieldRequires)
+	if err != nil {
+		return nil, err
+	}
+
+	err = scanner.Walk(func(pm *PortageMetaData) error {
+		return ans.AddPackage(pm)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ans, nil
+}
+
+// AddPackage adds the sonames of the package to the graph. The PROVIDES
+// and REQUIRES files are used when available, otherwise the sonames
+// are elaborated from the NEEDED.ELF.2 file.
+func (g *LinkageGraph) AddPackage(pm *PortageMetaData) error {
+	pkg := pm.GetPackageNameWithVersion()
+
+	provides, err := ParseSonameMap(pm.PROVIDES)
+	if err != nil {
+		return errors.New(
+			fmt.Sprintf("Error on parse PROVIDES of %s: %s", pkg, err.Error()))
+	}
+	requires, err := ParseSonameMap(pm.REQUIRES)
+	if err != nil {
+		return errors.New(
+			fmt.Sprintf("Error on parse REQUIRES of %s: %s", pkg, err.Error()))
+	}
+
+	if pm.PROVIDES == "" && pm.REQUIRES == "" && pm.NEEDED_ELF2 != "" {
+		provides, requires, err = sonamesFromNeededElf2(pm.NEEDED_ELF2)
+		if err != nil {
+			return errors.New(
+				fmt.Sprintf("Error on parse NEEDED.ELF.2 of %s: %s", pkg, err.Error()))
+		}
+	}
+
+	if old, ok := g.Packages[pkg]; ok {
+		g.removePackage(pkg, old)
+	}
+
+	g.Packages[pkg] = &LinkagePackage{
+		Package:  pm.GentooPackage,
+		Provides: provides,
+		Requires: requires,
+	}
+
+	for abi, sonames := range provides {
+		for _, s := range sonames {
+			addSonamePackage(g.providers, abi, s, pkg)
+		}
+	}
+	for abi, sonames := range requires {
+		for _, s := range sonames {
+			addSonamePackage(g.consumers, abi, s, pkg)
+		}
+	}
+
+	return nil
+}
+
+func sonamesFromNeededElf2(data string) (map[string][]string, map[string][]string, error) {
+	entries, err := ParseNeededElf2(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	provides := make(map[string][]string, 0)
+	requires := make(map[string][]string, 0)
+	provided := make(map[string]map[string]bool, 0)
+	required := make(map[string]map[string]bool, 0)
+
+	for _, e := range entries {
+		abi := e.MultilibCategory
+		if abi == "" {
+			abi = e.Arch
+		}
+		if provided[abi] == nil {
+			provided[abi] = make(map[string]bool, 0)
+			required[abi] = make(map[string]bool, 0)
+		}
+		if e.Soname != "" && !provided[abi][e.Soname] {
+			provided[abi][e.Soname] = true
+			provides[abi] = append(provides[abi], e.Soname)
+		}
+	}
+
+	// The sonames provided by the package itself are not required.
+	for _, e := range entries {
+		abi := e.MultilibCategory
+		if abi == "" {
+			abi = e.Arch
+		}
+		for _, n := range e.Needed {
+			if !provided[abi][n] && !required[abi][n] {
+				required[abi][n] = true
+				requires[abi] = append(requires[abi], n)
+			}
+		}
+	}
+
+	return provides, requires, nil
+}
+
+func addSonamePackage(m map[string]map[string][]string, abi, soname, pkg string) {
+	if m[abi] == nil {
+		m[abi] = make(map[string][]string, 0)
+	}
+	m[abi][soname] = append(m[abi][soname], pkg)
+}
+
+func (g *LinkageGraph) removePackage(pkg string, p *LinkagePackage) {
+	remove := func(m map[string]map[string][]string, sonames map[string][]string) {
+		for abi, list := range sonames {
+			for _, s := range list {
+				pkgs := []string{}
+				for _, p := range m[abi][s] {
+					if p != pkg {
+						pkgs = append(pkgs, p)
+					}
+				}
+				m[abi][s] = pkgs
+			}
+		}
+	}
+	remove(g.providers, p.Provides)
+	remove(g.consumers, p.Requires)
+	delete(g.Packages, pkg)
+}
+
+func sortLinkageSonames(list []LinkageSoname) {
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Package != list[j].Package {
+			return list[i].Package < list[j].Package
+		}
+		if list[i].Abi != list[j].Abi {
+			return list[i].Abi < list[j].Abi
+		}
+		return list[i].Soname < list[j].Soname
+	})
+}
+
+// GetProviders returns the packages that provide the soname
+// for the ABI.
+func (g *LinkageGraph) GetProviders(abi, soname string) []string {
+	ans := append([]string{}, g.providers[abi][soname]...)
+	sort.Strings(ans)
+	return ans
+}
+
+// GetBroken returns the sonames required that no installed package
+// provides.
+func (g *LinkageGraph) GetBroken() []LinkageSoname {
+	ans := []LinkageSoname{}
+	for abi, sonames := range g.consumers {
+		for soname, pkgs := range sonames {
+			if len(g.providers[abi][soname]) > 0 {
+				continue
+			}
+			for _, p := range pkgs {
+				ans = append(ans, LinkageSoname{Package: p, Abi: abi, Soname: soname})
+			}
+		}
+	}
+	sortLinkageSonames(ans)
+	return ans
+}
+
+// GetConsumers returns the packages that require the soname. With
+// an empty ABI all the ABIs are checked.
+func (g *LinkageGraph) GetConsumers(abi, soname string) []LinkageSoname {
+	ans := []LinkageSoname{}
+	for a, sonames := range g.consumers {
+		if abi != "" && a != abi {
+			continue
+		}
+		for _, p := range sonames[soname] {
+			ans = append(ans, LinkageSoname{Package: p, Abi: a, Soname: soname})
+		}
+	}
+	sortLinkageSonames(ans)
+	return ans
+}
+
+// GetRebuildDeps returns the packages that require the sonames
+// provided by the packages matching the atom. These packages must be
+// rebuilt when the library packages change.
+func (g *LinkageGraph) GetRebuildDeps(atom string) ([]LinkageSoname, error) {
+	gp, err := ParseAtom(atom)
+	if err != nil {
+		return nil, err
+	}
+
+	libs := make(map[string]bool, 0)
+	for pkg, p := range g.Packages {
+		if admitted, _ := gp.Admit(p.Package); admitted {
+			libs[pkg] = true
+		}
+	}
+
+	if len(libs) == 0 {
+		return nil, errors.New(
+			fmt.Sprintf("No installed packages match %s", atom))
+	}
+
+	ans := []LinkageSoname{}
+	seen := make(map[LinkageSoname]bool, 0)
+	for pkg, _ := range libs {
+		for abi, sonames := range g.Packages[pkg].Provides {
+			for _, s := range sonames {
+				for _, c := range g.consumers[abi][s] {
+					e := LinkageSoname{Package: c, Abi: abi, Soname: s}
+					if !libs[c] && !seen[e] {
+						seen[e] = true
+						ans = append(ans, e)
+					}
+				}
+			}
+		}
+	}
+	sortLinkageSonames(ans)
+	return ans, nil
+}
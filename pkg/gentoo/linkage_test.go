@@ -0,0 +1,163 @@
+/*
+
+Copyright (C) 2017-2021  Daniele Rondina <geaaru@sabayonlinux.org>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+*/
+
+
+package gentoo_test
+
+import (
+	"io/ioutil"
+	"os"
+
+	. "github.com/Sabayon/pkgs-checker/pkg/gentoo"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Gentoo linkage", func() {
+
+	Context("Parse files", func() {
+		It("Check soname map", func() {
+			m, err := ParseSonameMap("x86_32: libc.so.6\nx86_64: libc.so.6 libz.so.1\n")
+			Expect(err).Should(BeNil())
+			Expect(m).Should(Equal(map[string][]string{
+				"x86_32": []string{"libc.so.6"},
+				"x86_64": []string{"libc.so.6", "libz.so.1"},
+			}))
+
+			_, err = ParseSonameMap("libc.so.6")
+			Expect(err).ShouldNot(BeNil())
+		})
+
+		It("Check NEEDED.ELF.2", func() {
+			entries, err := ParseNeededElf2(
+				"X86_64;/usr/lib64/libssl.so.1.1;libssl.so.1.1;$ORIGIN:/opt/lib;libcrypto.so.1.1,libc.so.6;x86_64\n" +
+					"X86_64;/usr/bin/openssl;;;libssl.so.1.1,libc.so.6;x86_64\n")
+			Expect(err).Should(BeNil())
+			Expect(entries).Should(Equal([]NeededElf2Entry{
+				{
+					Arch: "X86_64", File: "/usr/lib64/libssl.so.1.1", Soname: "libssl.so.1.1",
+					Rpath:  []string{"$ORIGIN", "/opt/lib"},
+					Needed: []string{"libcrypto.so.1.1", "libc.so.6"}, MultilibCategory: "x86_64",
+				},
+				{
+					Arch: "X86_64", File: "/usr/bin/openssl",
+					Needed: []string{"libssl.so.1.1", "libc.so.6"}, MultilibCategory: "x86_64",
+				},
+			}))
+
+			_, err = ParseNeededElf2("X86_64;/usr/bin/foo")
+			Expect(err).ShouldNot(BeNil())
+		})
+	})
+
+	Context("Graph", func() {
+		var tmpdir string
+		var g *LinkageGraph
+
+		BeforeEach(func() {
+			var err error
+			tmpdir, err = ioutil.TempDir("", "linkage")
+			Expect(err).Should(BeNil())
+
+			writeVdbPackage(tmpdir, "sys-libs/glibc-2.33", map[string]string{
+				"PROVIDES": "x86_32: libc.so.6\nx86_64: libc.so.6",
+			})
+			writeVdbPackage(tmpdir, "dev-libs/openssl-1.1.1k", map[string]string{
+				"SLOT":     "0/1.1",
+				"PROVIDES": "x86_64: libcrypto.so.1.1 libssl.so.1.1",
+				"REQUIRES": "x86_64: libc.so.6",
+			})
+			writeVdbPackage(tmpdir, "net-misc/curl-7.76.1", map[string]string{
+				"PROVIDES": "x86_64: libcurl.so.4",
+				"REQUIRES": "x86_32: libssl.so.1.1\nx86_64: libc.so.6 libssl.so.1.1 libz.so.1",
+			})
+			// Without PROVIDES and REQUIRES
+			writeVdbPackage(tmpdir, "net-misc/wget-1.21.1", map[string]string{
+				"NEEDED.ELF.2": "X86_64;/usr/bin/wget;;;libssl.so.1.1,libc.so.6,libwget.so.1;x86_64\n" +
+					"X86_64;/usr/lib64/libwget.so.1;libwget.so.1;;libc.so.6;x86_64",
+			})
+
+			g, err = BuildLinkageGraph(NewVdbScanner(tmpdir, nil))
+			Expect(err).Should(BeNil())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(tmpdir)
+		})
+
+		It("Check providers", func() {
+			Expect(g.GetProviders("x86_64", "libc.so.6")).Should(Equal([]string{"sys-libs/glibc-2.33"}))
+			Expect(g.GetProviders("x86_64", "libwget.so.1")).Should(Equal([]string{"net-misc/wget-1.21.1"}))
+			Expect(g.Packages["net-misc/wget-1.21.1"].Requires).Should(Equal(map[string][]string{
+				"x86_64": []string{"libssl.so.1.1", "libc.so.6"},
+			}))
+		})
+
+		It("Check scanner fields", func() {
+			s := NewVdbScanner(tmpdir, nil)
+			Expect(s.SetFields(MetaFieldCounter)).Should(BeNil())
+			_, err := BuildLinkageGraph(s)
+			Expect(err).Should(BeNil())
+			Expect(s.Fields).Should(Equal([]PortageMetaField{MetaFieldCounter}))
+		})
+
+		It("Check broken", func() {
+			Expect(g.GetBroken()).Should(Equal([]LinkageSoname{
+				{Package: "net-misc/curl-7.76.1", Abi: "x86_32", Soname: "libssl.so.1.1"},
+				{Package: "net-misc/curl-7.76.1", Abi: "x86_64", Soname: "libz.so.1"},
+			}))
+		})
+
+		It("Check consumers", func() {
+			Expect(g.GetConsumers("", "libssl.so.1.1")).Should(Equal([]LinkageSoname{
+				{Package: "net-misc/curl-7.76.1", Abi: "x86_32", Soname: "libssl.so.1.1"},
+				{Package: "net-misc/curl-7.76.1", Abi: "x86_64", Soname: "libssl.so.1.1"},
+				{Package: "net-misc/wget-1.21.1", Abi: "x86_64", Soname: "libssl.so.1.1"},
+			}))
+			Expect(len(g.GetConsumers("x86_32", "libssl.so.1.1"))).Should(Equal(1))
+		})
+
+		It("Check rebuild", func() {
+			ans, err := g.GetRebuildDeps("dev-libs/openssl")
+			Expect(err).Should(BeNil())
+			Expect(ans).Should(Equal([]LinkageSoname{
+				{Package: "net-misc/curl-7.76.1", Abi: "x86_64", Soname: "libssl.so.1.1"},
+				{Package: "net-misc/wget-1.21.1", Abi: "x86_64", Soname: "libssl.so.1.1"},
+			}))
+
+			ans, err = g.GetRebuildDeps("=sys-libs/glibc-2.33")
+			Expect(err).Should(BeNil())
+			Expect(len(ans)).Should(Equal(3))
+
+			_, err = g.GetRebuildDeps("dev-libs/libressl")
+			Expect(err).ShouldNot(BeNil())
+		})
+
+		It("Check package update", func() {
+			gp, _ := ParsePackageStr("net-misc/curl-7.76.1")
+			pm := NewPortageMetaData(gp)
+			pm.REQUIRES = "x86_64: libc.so.6"
+			Expect(g.AddPackage(pm)).Should(BeNil())
+			Expect(len(g.GetBroken())).Should(Equal(0))
+			Expect(len(g.GetConsumers("", "libssl.so.1.1"))).Should(Equal(1))
+		})
+	})
+
+})
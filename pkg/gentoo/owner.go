@@ -0,0 +1,265 @@
+/*
+
+Copyright (C) 2017-2021  Daniele Rondina <geaaru@sabayonlinux.org>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+*/
+
+package gentoo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+type OwnerQueryMode int
+
+const (
+	// Match the full path of the file
+	OwnerQueryExact OwnerQueryMode = iota
+	// Match only the name of the file
+	OwnerQueryBasename
+	// Match the full path or the name of the file with a shell pattern
+	OwnerQueryGlob
+)
+
+const (
+	OwnerIndexVersion = 1
+)
+
+// OwnerIndex is a reverse index of the CONTENTS entries of the vdb.
+// The index could be saved to disk and it is updated only for the
+// packages with a different COUNTER.
+type OwnerIndex struct {
+	Version  int                           `json:"version"`
+	Packages map[string]*OwnerIndexPackage `json:"packages"`
+
+	files     map[string][]OwnerMatch
+	basenames map[string][]string
+}
+
+type OwnerIndexPackage struct {
+	Counter  string               `json:"counter"`
+	Slot     string               `json:"slot,omitempty"`
+	Contents []PortageContentElem `json:"contents,omitempty"`
+}
+
+type OwnerMatch struct {
+	Package string `json:"package"`
+	Slot    string `json:"slot,omitempty"`
+	File    string `json:"file"`
+	Type    string `json:"type"`
+	Hash    string `json:"hash,omitempty"`
+}
+
+func NewOwnerIndex() *OwnerIndex {
+	return &OwnerIndex{
+		Version:   OwnerIndexVersion,
+		Packages:  make(map[string]*OwnerIndexPackage, 0),
+		files:     make(map[string][]OwnerMatch, 0),
+		basenames: make(map[string][]string, 0),
+	}
+}
+
+// LoadOwnerIndex reads an index saved with Save. An index of a
+// different version returns an empty index.
+func LoadOwnerIndex(file string) (*OwnerIndex, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	ans := NewOwnerIndex()
+	err = json.Unmarshal(data, ans)
+	if err != nil {
+		return nil, errors.New(
+			fmt.Sprintf("Error on parse owner index %s: %s", file, err.Error()))
+	}
+
+	if ans.Version != OwnerIndexVersion || ans.Packages == nil {
+		return NewOwnerIndex(), nil
+	}
+
+	ans.rebuild()
+	return ans, nil
+}
+
+func (idx *OwnerIndex) Save(file string) error {
+	data, err := json.Marshal(idx)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(file), 0755)
+	if err != nil {
+		return err
+	}
+
+	// Write the index atomically to avoid broken caches.
+	tmp := file + ".tmp"
+	err = ioutil.WriteFile(tmp, data, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, file)
+}
+
+// Sync updates the index with the packages of the vdb of the scanner.
+// Only the CONTENTS of the packages new or with a different COUNTER
+// are loaded. It returns true if the index is been changed.
+func (idx *OwnerIndex) Sync(s *VdbScanner) (bool, error) {
+	changed := false
+	present := make(map[string]bool, len(idx.Packages))
+	toLoad := []*PortageMetaData{}
+	dirs := make(map[*PortageMetaData]string, 0)
+
+	scanner, err := s.WithFields(MetaFieldCounter, MetaFieldSlot)
+	if err != nil {
+		return false, err
+	}
+
+	err = scanner.WalkEntries(func(dir string, pm *PortageMetaData) error {
+		pkg := pm.GetPackageNameWithVersion()
+		present[pkg] = true
+		if p, ok := idx.Packages[pkg]; !ok || p.Counter != pm.COUNTER || pm.COUNTER == "" {
+			toLoad = append(toLoad, pm)
+			dirs[pm] = dir
+		}
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	for pkg, _ := range idx.Packages {
+		if !present[pkg] {
+			delete(idx.Packages, pkg)
+			changed = true
+		}
+	}
+
+	for _, pm := range toLoad {
+		file := filepath.Join(dirs[pm], "CONTENTS")
+		if _, err := os.Stat(file); err != nil {
+			return changed, errors.New(
+				fmt.Sprintf("Error on read CONTENTS of %s: %s",
+					pm.GetPackageNameWithVersion(), err.Error()))
+		}
+
+		contents, err := GetCONTENTS(file)
+		if err != nil {
+			return changed, err
+		}
+		idx.Packages[pm.GetPackageNameWithVersion()] = &OwnerIndexPackage{
+			Counter:  pm.COUNTER,
+			Slot:     pm.GetSlotStr(),
+			Contents: contents,
+		}
+		changed = true
+	}
+
+	if changed {
+		idx.rebuild()
+	}
+
+	return changed, nil
+}
+
+func (idx *OwnerIndex) rebuild() {
+	idx.files = make(map[string][]OwnerMatch, 0)
+	idx.basenames = make(map[string][]string, 0)
+
+	for pkg, p := range idx.Packages {
+		for _, e := range p.Contents {
+			file := filepath.Clean(e.File)
+			if _, ok := idx.files[file]; !ok {
+				base := filepath.Base(file)
+				idx.basenames[base] = append(idx.basenames[base], file)
+			}
+			idx.files[file] = append(idx.files[file], OwnerMatch{
+				Package: pkg,
+				Slot:    p.Slot,
+				File:    file,
+				Type:    e.Type,
+				Hash:    e.Hash,
+			})
+		}
+	}
+}
+
+// Query returns the owners of the files matching the query sorted by
+// file and package.
+func (idx *OwnerIndex) Query(q string, mode OwnerQueryMode) ([]OwnerMatch, error) {
+	ans := []OwnerMatch{}
+
+	switch mode {
+	case OwnerQueryExact:
+		ans = append(ans, idx.files[filepath.Clean(q)]...)
+
+	case OwnerQueryBasename:
+		for _, f := range idx.basenames[q] {
+			ans = append(ans, idx.files[f]...)
+		}
+
+	case OwnerQueryGlob:
+		// Without a path separator the pattern matches the name.
+		withPath := strings.Contains(q, "/")
+		if _, err := filepath.Match(q, ""); err != nil {
+			return nil, errors.New(
+				fmt.Sprintf("Invalid pattern %s: %s", q, err.Error()))
+		}
+
+		for f, owners := range idx.files {
+			name := f
+			if !withPath {
+				name = filepath.Base(f)
+			}
+			if matched, _ := filepath.Match(q, name); matched {
+				ans = append(ans, owners...)
+			}
+		}
+
+	default:
+		return nil, errors.New("Invalid query mode")
+	}
+
+	sort.Slice(ans, func(i, j int) bool {
+		if ans[i].File == ans[j].File {
+			return ans[i].Package < ans[j].Package
+		}
+		return ans[i].File < ans[j].File
+	})
+
+	return ans, nil
+}
+
+// GetOwnerQueryMode returns the mode to use with the query:
+// glob if there are pattern chars, exact for paths and basename for
+// names.
+func GetOwnerQueryMode(q string) OwnerQueryMode {
+	if strings.ContainsAny(q, "*?[") {
+		return OwnerQueryGlob
+	}
+	if strings.Contains(q, "/") {
+		return OwnerQueryExact
+	}
+	return OwnerQueryBasename
+}
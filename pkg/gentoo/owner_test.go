@@ -0,0 +1,188 @@
+/*
+
+Copyright (C) 2017-2021  Daniele Rondina <geaaru@sabayonlinux.org>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+*/
+
+
+package gentoo_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/Sabayon/pkgs-checker/pkg/gentoo"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Gentoo OwnerIndex", func() {
+
+	var tmpdir, vdb string
+
+	BeforeEach(func() {
+		var err error
+		tmpdir, err = ioutil.TempDir("", "owner")
+		Expect(err).Should(BeNil())
+		vdb = filepath.Join(tmpdir, "db")
+
+		writeVdbPackage(vdb, "dev-libs/openssl-1.1.1k", map[string]string{
+			"COUNTER": "10",
+			"SLOT":    "0/1.1",
+			"CONTENTS": "dir /usr/lib64\n" +
+				"obj /usr/lib64/libssl.so.1.1 d3b07384d113edec49eaa6238ad5ff00 1600000000\n" +
+				"obj /usr/lib64/libcrypto.so.1.1 d3b07384d113edec49eaa6238ad5ff00 1600000000\n" +
+				"sym /usr/lib64/libssl.so -> libssl.so.1.1 1600000000",
+		})
+		writeVdbPackage(vdb, "sys-libs/zlib-1.2.11", map[string]string{
+			"COUNTER": "11",
+			"CONTENTS": "dir /usr/lib64\n" +
+				"obj /usr/lib64/libz.so.1 d3b07384d113edec49eaa6238ad5ff00 1600000000",
+		})
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tmpdir)
+	})
+
+	It("Check queries", func() {
+		idx := NewOwnerIndex()
+		changed, err := idx.Sync(NewVdbScanner(vdb, nil))
+		Expect(err).Should(BeNil())
+		Expect(changed).Should(BeTrue())
+
+		owners, err := idx.Query("/usr/lib64/libssl.so", OwnerQueryExact)
+		Expect(err).Should(BeNil())
+		Expect(owners).Should(Equal([]OwnerMatch{
+			{Package: "dev-libs/openssl-1.1.1k", Slot: "0/1.1", File: "/usr/lib64/libssl.so", Type: "sym"},
+		}))
+
+		owners, err = idx.Query("/usr/lib64/", OwnerQueryExact)
+		Expect(err).Should(BeNil())
+		Expect(len(owners)).Should(Equal(2))
+		Expect(owners[0].Package).Should(Equal("dev-libs/openssl-1.1.1k"))
+		Expect(owners[1].Package).Should(Equal("sys-libs/zlib-1.2.11"))
+
+		owners, err = idx.Query("libz.so.1", OwnerQueryBasename)
+		Expect(err).Should(BeNil())
+		Expect(len(owners)).Should(Equal(1))
+		Expect(owners[0].File).Should(Equal("/usr/lib64/libz.so.1"))
+
+		owners, err = idx.Query("lib*.so.1*", OwnerQueryGlob)
+		Expect(err).Should(BeNil())
+		Expect(len(owners)).Should(Equal(3))
+		Expect(owners[0].File).Should(Equal("/usr/lib64/libcrypto.so.1.1"))
+
+		owners, err = idx.Query("/usr/*/libz*", OwnerQueryGlob)
+		Expect(err).Should(BeNil())
+		Expect(len(owners)).Should(Equal(1))
+
+		owners, err = idx.Query("/usr/bin/missing", OwnerQueryExact)
+		Expect(err).Should(BeNil())
+		Expect(len(owners)).Should(Equal(0))
+
+		_, err = idx.Query("[", OwnerQueryGlob)
+		Expect(err).ShouldNot(BeNil())
+	})
+
+	It("Check cache", func() {
+		cache := filepath.Join(tmpdir, "cache", "owner.json")
+		idx := NewOwnerIndex()
+		_, err := idx.Sync(NewVdbScanner(vdb, nil))
+		Expect(err).Should(BeNil())
+		Expect(idx.Save(cache)).Should(BeNil())
+
+		idx, err = LoadOwnerIndex(cache)
+		Expect(err).Should(BeNil())
+		Expect(len(idx.Packages)).Should(Equal(2))
+		owners, _ := idx.Query("libssl.so", OwnerQueryBasename)
+		Expect(len(owners)).Should(Equal(1))
+
+		changed, err := idx.Sync(NewVdbScanner(vdb, nil))
+		Expect(err).Should(BeNil())
+		Expect(changed).Should(BeFalse())
+
+		// A CONTENTS change without a COUNTER change is not seen.
+		writeVdbPackage(vdb, "sys-libs/zlib-1.2.11", map[string]string{
+			"CONTENTS": "obj /lib64/libz.so.1 d3b07384d113edec49eaa6238ad5ff00 1600000000",
+		})
+		changed, err = idx.Sync(NewVdbScanner(vdb, nil))
+		Expect(err).Should(BeNil())
+		Expect(changed).Should(BeFalse())
+
+		writeVdbPackage(vdb, "sys-libs/zlib-1.2.11", map[string]string{
+			"COUNTER": "12",
+		})
+		changed, err = idx.Sync(NewVdbScanner(vdb, nil))
+		Expect(err).Should(BeNil())
+		Expect(changed).Should(BeTrue())
+		owners, _ = idx.Query("/lib64/libz.so.1", OwnerQueryExact)
+		Expect(len(owners)).Should(Equal(1))
+		owners, _ = idx.Query("/usr/lib64/libz.so.1", OwnerQueryExact)
+		Expect(len(owners)).Should(Equal(0))
+
+		Expect(os.RemoveAll(filepath.Join(vdb, "dev-libs"))).Should(BeNil())
+		changed, err = idx.Sync(NewVdbScanner(vdb, nil))
+		Expect(err).Should(BeNil())
+		Expect(changed).Should(BeTrue())
+		Expect(len(idx.Packages)).Should(Equal(1))
+		owners, _ = idx.Query("libssl.so", OwnerQueryBasename)
+		Expect(len(owners)).Should(Equal(0))
+	})
+
+	It("Check entry with build", func() {
+		writeVdbPackage(vdb, "net-vpn/wireguard-0.6.0+5", map[string]string{
+			"COUNTER":  "12",
+			"CONTENTS": "obj /usr/bin/wg d3b07384d113edec49eaa6238ad5ff00 1600000000",
+		})
+
+		idx := NewOwnerIndex()
+		_, err := idx.Sync(NewVdbScanner(vdb, nil))
+		Expect(err).Should(BeNil())
+
+		owners, err := idx.Query("wg", OwnerQueryBasename)
+		Expect(err).Should(BeNil())
+		Expect(len(owners)).Should(Equal(1))
+		Expect(owners[0].Package).Should(Equal("net-vpn/wireguard-0.6.0"))
+	})
+
+	It("Check missing CONTENTS", func() {
+		writeVdbPackage(vdb, "app-misc/foo-1.0", map[string]string{
+			"COUNTER": "13",
+		})
+
+		_, err := NewOwnerIndex().Sync(NewVdbScanner(vdb, nil))
+		Expect(err).ShouldNot(BeNil())
+	})
+
+	It("Check scanner fields", func() {
+		s := NewVdbScanner(vdb, nil)
+		Expect(s.SetFields(MetaFieldRdepend)).Should(BeNil())
+
+		_, err := NewOwnerIndex().Sync(s)
+		Expect(err).Should(BeNil())
+		Expect(s.Fields).Should(Equal([]PortageMetaField{MetaFieldRdepend}))
+	})
+
+	It("Check query mode", func() {
+		Expect(GetOwnerQueryMode("/usr/bin/foo")).Should(Equal(OwnerQueryExact))
+		Expect(GetOwnerQueryMode("foo")).Should(Equal(OwnerQueryBasename))
+		Expect(GetOwnerQueryMode("/usr/bin/f*")).Should(Equal(OwnerQueryGlob))
+	})
+
+})
@@ -22,10 +22,7 @@ import (
 	"errors"
 	"fmt"
 	"regexp"
-	"sort"
 	"strings"
-
-	version "github.com/hashicorp/go-version"
 )
 
 // ----------------------------------
@@ -59,11 +56,25 @@ const (
 	PkgCondNotGreater = 10
 )
 
+const (
+	// :SLOT= or := rebuild on slot/sub-slot change
+	SlotOperatorEqual = "="
+	// :* any slot without rebuild
+	SlotOperatorAny = "*"
+)
+
 const (
 	RegexCatString     = `(^[a-z]+[0-9]*[a-z]*[-]*[a-z]+[0-9]*[a-z]*|^virtual)`
 	RegexPkgNameString = `([a-zA-Z]*[0-9a-zA-Z\.\-_]*[a-zA-Z0-9]+|[a-zA-Z\-]+[+]+[-]+[0-9a-zA-Z\.]*|[a-zA-Z\-]+[+]+)`
 )
 
+// Category and package name as described by PMS section 3.1. The
+// dot is admitted also on package name (ex. app-misc/geoclue-2.0-2.5.3).
+var regexNameValid = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9+_.-]*$`)
+
+// Build as dot-separated identifiers (ex. 1, r1, 0.dev, pre2_p20191024.1).
+var regexBuildValid = regexp.MustCompile(`^[0-9A-Za-z_]+(\.[0-9A-Za-z_]+)*$`)
+
 type GentooPackage struct {
 	Name          string `json:"name,omitempty"`
 	Category      string `json:"category,omitempty"`
@@ -71,14 +82,25 @@ type GentooPackage struct {
 	VersionSuffix string `json:"version_suffix,omitempty"`
 	VersionBuild  string `json:"version_build,omitempty"`
 	Slot          string `json:"slot,omitempty"`
+	SubSlot       string `json:"subslot,omitempty"`
+	SlotOperator  string `json:"slot_operator,omitempty"`
 	Condition     PackageCond
 	Repository    string   `json:"repository,omitempty"`
 	UseFlags      []string `json:"use_flags,omitempty"`
 	License       string   `json:"license,omitempty"`
+	// The blocker is a strong blocker (!!, !!< and !!>).
+	StrongBlocker bool `json:"strong_blocker,omitempty"`
 }
 
 func (p *GentooPackage) String() string {
-	// TODO
+	ans := p.GetPackageNameWithVersion()
+	if slot := p.GetSlotStr(); slot != "" && slot != "0" {
+		ans += ":" + slot
+	}
+	return ans
+}
+
+func (p *GentooPackage) GetPackageNameWithVersion() string {
 	opt := ""
 	if p.Version != "" {
 		opt = "-"
@@ -88,6 +110,75 @@ func (p *GentooPackage) String() string {
 		p.Version, p.VersionSuffix)
 }
 
+// Format returns the full atom in the canonical format:
+// <op><cat>/<pn>-<pvr>[+build][*][:slot[/subslot][op]][::repo][[use,...]]
+// The string returned is parsed by ParsePackageStr to the same package.
+func (p *GentooPackage) Format() string {
+	var b strings.Builder
+
+	if p.StrongBlocker {
+		b.WriteString("!")
+	}
+
+	if p.Condition == PkgCondMatchVersion {
+		b.WriteString("=")
+	} else {
+		b.WriteString(p.Condition.String())
+	}
+
+	b.WriteString(p.GetPackageNameWithVersion())
+
+	if p.VersionBuild != "" {
+		b.WriteString("+" + p.VersionBuild)
+	}
+
+	if p.Condition == PkgCondMatchVersion {
+		b.WriteString("*")
+	}
+
+	if slot := p.GetSlotStr(); slot != "0" && slot != "" {
+		b.WriteString(":" + slot)
+	}
+
+	if p.Repository != "" {
+		b.WriteString("::" + p.Repository)
+	}
+
+	if p.UseFlags != nil {
+		b.WriteString("[" + strings.Join(p.UseFlags, ",") + "]")
+	}
+
+	return b.String()
+}
+
+// SetSlotStr parses a slot string in the format SLOT[/SUBSLOT][=|*]
+// as used in the atoms and in the SLOT file of the vdb.
+func (p *GentooPackage) SetSlotStr(slot string) {
+	p.SlotOperator = ""
+	p.SubSlot = ""
+
+	if strings.HasSuffix(slot, SlotOperatorEqual) || strings.HasSuffix(slot, SlotOperatorAny) {
+		p.SlotOperator = slot[len(slot)-1:]
+		slot = slot[0 : len(slot)-1]
+	}
+
+	if idx := strings.Index(slot, "/"); idx >= 0 {
+		p.SubSlot = slot[idx+1:]
+		slot = slot[0:idx]
+	}
+
+	p.Slot = slot
+}
+
+// GetSlotStr returns the slot in the format SLOT[/SUBSLOT][=|*].
+func (p *GentooPackage) GetSlotStr() string {
+	ans := p.Slot
+	if p.SubSlot != "" {
+		ans += "/" + p.SubSlot
+	}
+	return ans + p.SlotOperator
+}
+
 func (p PackageCond) String() (ans string) {
 	if p == PkgCondInvalid {
 		ans = ""
@@ -145,17 +236,6 @@ func (p PackageCond) Int() (ans int) {
 	return
 }
 
-func sanitizeVersion(v string) string {
-	// https://devmanual.gentoo.org/ebuild-writing/file-format/index.html
-	ans := strings.ReplaceAll(v, "_alpha", "-alpha")
-	ans = strings.ReplaceAll(ans, "_beta", "-beta")
-	ans = strings.ReplaceAll(ans, "_pre", "-pre")
-	ans = strings.ReplaceAll(ans, "_rc", "-rc")
-	ans = strings.ReplaceAll(ans, "_p", "-p")
-
-	return ans
-}
-
 func (p *GentooPackage) OfPackage(i *GentooPackage) (ans bool) {
 	if p.Category == i.Category && p.Name == i.Name {
 		ans = true
@@ -171,7 +251,7 @@ func (p *GentooPackage) GetPackageName() (ans string) {
 }
 
 func (p *GentooPackage) GetPackageNameWithSlot() (ans string) {
-	if p.Slot != "0" {
+	if p.Slot != "0" && p.Slot != "" {
 		ans = fmt.Sprintf("%s:%s", p.GetPackageName(), p.Slot)
 	} else {
 		ans = p.GetPackageName()
@@ -209,9 +289,18 @@ func (p *GentooPackage) GetPF() string {
 	return fmt.Sprintf("%s-%s", p.GetPN(), p.GetPVR())
 }
 
-func (p *GentooPackage) getVersions(i *GentooPackage) (*version.Version, *version.Version, error) {
-	var v1 *version.Version = nil
-	var v2 *version.Version = nil
+// GetVersion returns the parsed version of the package.
+func (p *GentooPackage) GetVersion() (*GentooVersion, error) {
+	v := p.Version + p.VersionSuffix
+	if p.VersionBuild != "" {
+		v += "+" + p.VersionBuild
+	}
+	return ParseVersion(v)
+}
+
+func (p *GentooPackage) getVersions(i *GentooPackage) (*GentooVersion, *GentooVersion, error) {
+	var v1 *GentooVersion = nil
+	var v2 *GentooVersion = nil
 	var err error
 
 	if p.Category != i.Category {
@@ -234,20 +323,11 @@ func (p *GentooPackage) getVersions(i *GentooPackage) (*version.Version, *versio
 			fmt.Sprintf("Package supply without version. I can't compare versions."))
 	}
 
-	v1s := p.Version
-	v2s := i.Version
-
-	if p.VersionBuild != "" {
-		v1s = p.Version + "+" + p.VersionBuild
-	}
-	v1, err = version.NewVersion(v1s)
+	v1, err = p.GetVersion()
 	if err != nil {
 		return nil, nil, err
 	}
-	if i.VersionBuild != "" {
-		v2s = i.Version + "+" + i.VersionBuild
-	}
-	v2, err = version.NewVersion(v2s)
+	v2, err = i.GetVersion()
 	if err != nil {
 		return nil, nil, err
 	}
@@ -268,147 +348,81 @@ func (p *GentooPackage) orderDifferentPkgs(i *GentooPackage, mode int) bool {
 	return p.Name > i.Name
 }
 
+// CompareVersion returns -1, 0 or 1 if the version of the package is
+// respectively less than, equal to or greater than the version of i.
+func (p *GentooPackage) CompareVersion(i *GentooPackage) (int, error) {
+	v1, v2, err := p.getVersions(i)
+	if err != nil {
+		return 0, err
+	}
+	return v1.Compare(v2), nil
+}
+
 func (p *GentooPackage) GreaterThan(i *GentooPackage) (bool, error) {
-	var ans bool
 	if p.Category != i.Category || p.Name != i.Name {
 		return p.orderDifferentPkgs(i, 1), nil
 	}
-	v1, v2, err := p.getVersions(i)
+	ans, err := p.CompareVersion(i)
 	if err != nil {
 		return false, err
 	}
-
-	if v1.Equal(v2) {
-		// Order suffix and VersionBuild
-		versionsSuffix := []string{
-			p.VersionSuffix + "+" + p.VersionBuild,
-			i.VersionSuffix + "+" + i.VersionBuild,
-		}
-
-		sort.Strings(versionsSuffix)
-		if versionsSuffix[1] == p.VersionSuffix+"+"+p.VersionBuild {
-			ans = true
-		} else {
-			ans = false
-		}
-
-	} else {
-		ans = v1.GreaterThan(v2)
-	}
-	return ans, nil
+	return ans > 0, nil
 }
 
 func (p *GentooPackage) LessThan(i *GentooPackage) (bool, error) {
-	var ans bool
-
 	if p.Category != i.Category || p.Name != i.Name {
 		return p.orderDifferentPkgs(i, 0), nil
 	}
-	v1, v2, err := p.getVersions(i)
+	ans, err := p.CompareVersion(i)
 	if err != nil {
 		return false, err
 	}
-
-	if v1.Equal(v2) {
-		// Order suffix and VersionBuild
-		versionsSuffix := []string{
-			p.VersionSuffix + "+" + p.VersionBuild,
-			i.VersionSuffix + "+" + i.VersionBuild,
-		}
-
-		sort.Strings(versionsSuffix)
-		if versionsSuffix[0] == p.VersionSuffix+"+"+p.VersionBuild {
-			ans = true
-		} else {
-			ans = false
-		}
-
-	} else {
-		ans = v1.LessThan(v2)
-	}
-	return ans, nil
+	return ans < 0, nil
 }
 
 func (p *GentooPackage) LessThanOrEqual(i *GentooPackage) (bool, error) {
-	var ans bool
 	if p.Category != i.Category || p.Name != i.Name {
 		return p.orderDifferentPkgs(i, 0), nil
 	}
-	v1, v2, err := p.getVersions(i)
+	ans, err := p.CompareVersion(i)
 	if err != nil {
 		return false, err
 	}
-
-	if v1.Equal(v2) {
-		// Order suffix and VersionBuild
-		versionsSuffix := []string{
-			p.VersionSuffix + "+" + p.VersionBuild,
-			i.VersionSuffix + "+" + i.VersionBuild,
-		}
-
-		sort.Strings(versionsSuffix)
-		if versionsSuffix[0] == p.VersionSuffix+"+"+p.VersionBuild {
-			ans = true
-		} else {
-			ans = false
-		}
-
-	} else {
-		ans = v1.LessThanOrEqual(v2)
-	}
-	return ans, nil
+	return ans <= 0, nil
 }
 
 func (p *GentooPackage) GreaterThanOrEqual(i *GentooPackage) (bool, error) {
-	var ans bool
-
 	if p.Category != i.Category || p.Name != i.Name {
 		return p.orderDifferentPkgs(i, 1), nil
 	}
-	v1, v2, err := p.getVersions(i)
+	ans, err := p.CompareVersion(i)
 	if err != nil {
 		return false, err
 	}
-
-	if v1.Equal(v2) {
-		// Order suffix and VersionBuild
-		versionsSuffix := []string{
-			p.VersionSuffix + "+" + p.VersionBuild,
-			i.VersionSuffix + "+" + i.VersionBuild,
-		}
-
-		sort.Strings(versionsSuffix)
-		if versionsSuffix[1] == p.VersionSuffix+"+"+p.VersionBuild {
-			ans = true
-		} else {
-			ans = false
-		}
-
-	} else {
-		ans = v1.LessThanOrEqual(v2)
-	}
-	return ans, nil
+	return ans >= 0, nil
 }
 
 func (p *GentooPackage) Equal(i *GentooPackage) (bool, error) {
-	v1, v2, err := p.getVersions(i)
+	ans, err := p.CompareVersion(i)
 	if err != nil {
 		return false, err
 	}
-	ans := v1.Equal(v2)
+	return ans == 0, nil
+}
 
-	if ans && (p.VersionSuffix != i.VersionSuffix || p.VersionBuild != i.VersionBuild) {
-		ans = false
+// Admit checks if the package i satisfies the atom p. The USE
+// dependencies of the atom are checked only if the package i carries
+// its USE flags; see AdmitWithUse for the USE conditionals.
+func (p *GentooPackage) Admit(i *GentooPackage) (bool, error) {
+	ans, err := p.admitAtom(i)
+	if err != nil || !ans || len(i.UseFlags) == 0 {
+		return ans, err
 	}
-
-	return ans, nil
+	return p.admitUse(i, nil)
 }
 
-func (p *GentooPackage) Admit(i *GentooPackage) (bool, error) {
+func (p *GentooPackage) admitAtom(i *GentooPackage) (bool, error) {
 	var ans bool = false
-	var v1 *version.Version = nil
-	var v2 *version.Version = nil
-	var err error
 
 	if p.Category != i.Category {
 		return false, errors.New(
@@ -420,116 +434,153 @@ func (p *GentooPackage) Admit(i *GentooPackage) (bool, error) {
 			fmt.Sprintf("Wrong name for package %s", i.Name))
 	}
 
-	// Check Slot
+	// A blocker admits all the packages that it doesn't block.
+	if p.IsBlocker() {
+		blocked, err := p.Blocks(i)
+		return !blocked, err
+	}
+
+	// Check Slot. The slot operators := and :* don't restrict the
+	// slot but only how the dependency is rebuilt.
 	if p.Slot != "" && i.Slot != "" && p.Slot != i.Slot {
 		return false, nil
 	}
 
-	v1s := p.Version
-	v2s := i.Version
-
-	if v1s != "" {
-		if p.VersionBuild != "" {
-			v1s = p.Version + "+" + p.VersionBuild
-		}
-		v1, err = version.NewVersion(v1s)
-		if err != nil {
-			return false, err
-		}
-	}
-	if v2s != "" {
-		if i.VersionBuild != "" {
-			v2s = i.Version + "+" + i.VersionBuild
-		}
-		v2, err = version.NewVersion(v2s)
-		if err != nil {
-			return false, err
-		}
+	// Check SubSlot
+	if p.SubSlot != "" && i.SubSlot != "" && p.SubSlot != i.SubSlot {
+		return false, nil
 	}
 
 	// If package doesn't define version admit all versions of the package.
 	if p.Version == "" {
-		ans = true
-	} else {
-		if p.Condition == PkgCondInvalid || p.Condition == PkgCondEqual {
-			// case 1: source-pkg-1.0 and dest-pkg-1.0 or dest-pkg without version
-			if i.Version != "" && i.Version == p.Version && p.VersionSuffix == i.VersionSuffix &&
-				p.VersionBuild == i.VersionBuild {
-				ans = true
-			}
-		} else if p.Condition == PkgCondAnyRevision {
-			if v1 != nil && v2 != nil {
-				ans = v1.Equal(v2)
-			}
-		} else if p.Condition == PkgCondMatchVersion {
-			// TODO: case of 7.3* where 7.30 is accepted.
-			if v1 != nil && v2 != nil {
-				segments := v1.Segments()
-				n := strings.Count(p.Version, ".")
-				switch n {
-				case 0:
-					segments[0]++
-				case 1:
-					segments[1]++
-				case 2:
-					segments[2]++
-				default:
-					segments[len(segments)-1]++
-				}
-				nextVersion := strings.Trim(strings.Replace(fmt.Sprint(segments), " ", ".", -1), "[]")
-				constraints, err := version.NewConstraint(
-					fmt.Sprintf(">= %s, < %s", p.Version, nextVersion),
-				)
-				if err != nil {
-					return false, err
-				}
-				ans = constraints.Check(v2)
-			}
-		} else if v1 != nil && v2 != nil {
-
-			switch p.Condition {
-			case PkgCondGreaterEqual:
-				ans = v2.GreaterThanOrEqual(v1)
-			case PkgCondLessEqual:
-				ans = v2.LessThanOrEqual(v1)
-			case PkgCondGreater:
-				ans = v2.GreaterThan(v1)
-			case PkgCondLess:
-				ans = v2.LessThan(v1)
-			case PkgCondNot:
-				ans = !v2.Equal(v1)
-			}
+		return true, nil
+	}
 
-		}
+	// A package without version can't satisfy a version condition.
+	if i.Version == "" {
+		return false, nil
+	}
 
+	v1, v2, err := p.getVersions(i)
+	if err != nil {
+		return false, err
+	}
+
+	switch p.Condition {
+	case PkgCondInvalid, PkgCondEqual:
+		ans = v2.Compare(v1) == 0
+	case PkgCondAnyRevision:
+		ans = v2.CompareWithoutRevision(v1) == 0
+	case PkgCondMatchVersion:
+		ans = versionMatchGlob(p.Version+p.VersionSuffix, i.Version+i.VersionSuffix)
+	case PkgCondGreaterEqual:
+		ans = v2.Compare(v1) >= 0
+	case PkgCondLessEqual:
+		ans = v2.Compare(v1) <= 0
+	case PkgCondGreater:
+		ans = v2.Compare(v1) > 0
+	case PkgCondLess:
+		ans = v2.Compare(v1) < 0
 	}
 
 	return ans, nil
 }
 
+// IsBlocker returns true if the atom is a blocker (!, !< and !>).
+// The strong blockers have the same conditions with StrongBlocker.
+func (p *GentooPackage) IsBlocker() bool {
+	return p.Condition == PkgCondNot ||
+		p.Condition == PkgCondNotLess ||
+		p.Condition == PkgCondNotGreater
+}
+
+// Blocks returns true if the package i is blocked by the atom.
+// The ! blocker blocks the same version (or all the versions if the
+// atom is without version), !< the versions less than the atom and
+// !> the versions greater than the atom. A package without version
+// is blocked only by a blocker without version.
+func (p *GentooPackage) Blocks(i *GentooPackage) (bool, error) {
+	if !p.IsBlocker() || p.Category != i.Category || p.Name != i.Name {
+		return false, nil
+	}
+
+	if p.Slot != "" && i.Slot != "" && p.Slot != i.Slot {
+		return false, nil
+	}
+
+	if p.Version == "" {
+		return true, nil
+	}
+	if i.Version == "" {
+		return false, nil
+	}
+
+	v1, v2, err := p.getVersions(i)
+	if err != nil {
+		return false, err
+	}
+
+	ans := v2.Compare(v1)
+	switch p.Condition {
+	case PkgCondNotLess:
+		return ans < 0, nil
+	case PkgCondNotGreater:
+		return ans > 0, nil
+	}
+	return ans == 0, nil
+}
+
+// GentooPackageParseOpts contains the options of the parser of the
+// package strings.
+type GentooPackageParseOpts struct {
+	// Leave the slot empty when the string doesn't define it, so that
+	// the package matches all the slots. Without it the slot is 0.
+	AnySlot bool `json:"any_slot,omitempty" yaml:"any_slot,omitempty"`
+}
+
+func NewGentooPackageParseOpts() *GentooPackageParseOpts {
+	return &GentooPackageParseOpts{
+		AnySlot: false,
+	}
+}
+
 // return category, package, version, slot, condition
 func ParsePackageStr(pkg string) (*GentooPackage, error) {
+	return ParsePackageStrWithOpts(pkg, nil)
+}
+
+// ParsePackageStrWithOpts parses a package string with the options
+// supplied. Without options it's equal to ParsePackageStr.
+func ParsePackageStrWithOpts(pkg string, opts *GentooPackageParseOpts) (*GentooPackage, error) {
 	if pkg == "" {
 		return nil, errors.New("Invalid package string")
 	}
+	if opts == nil {
+		opts = NewGentooPackageParseOpts()
+	}
 
 	ans := GentooPackage{
 		Slot:         "0",
 		Condition:    PkgCondInvalid,
 		VersionBuild: "",
 	}
+	if opts.AnySlot {
+		ans.Slot = ""
+	}
 
 	// Check if pkg string contains inline use flags
-	regexUses := regexp.MustCompile(
-		"\\[([a-z]*[-]*[0-9]*[,]*)+\\]*$",
-	)
-	mUses := regexUses.FindAllString(pkg, -1)
-	if len(mUses) > 0 {
-		ans.UseFlags = strings.Split(
-			pkg[len(pkg)-len(mUses[0])+1:len(pkg)-1],
-			",",
-		)
-		pkg = pkg[:len(pkg)-len(mUses[0])]
+	// (ex. foo[bar,-baz,python_targets_python3_8(-)?])
+	if strings.HasSuffix(pkg, "]") && strings.Index(pkg, "[") > 0 {
+		idx := strings.Index(pkg, "[")
+		ans.UseFlags = strings.Split(pkg[idx+1:len(pkg)-1], ",")
+		pkg = pkg[:idx]
+	}
+
+	// The strong blocker blocks as the weak blocker and it's only
+	// a different resolution for the package manager.
+	if strings.HasPrefix(pkg, "!!") {
+		pkg = pkg[1:]
+		ans.StrongBlocker = true
 	}
 
 	if strings.HasPrefix(pkg, ">=") {
@@ -546,9 +597,17 @@ func ParsePackageStr(pkg string) (*GentooPackage, error) {
 		ans.Condition = PkgCondLess
 	} else if strings.HasPrefix(pkg, "=") {
 		pkg = pkg[1:]
-		if strings.HasSuffix(pkg, "*") {
+		// The * of the version glob is before the slot
+		// (ex. =dev-lang/python-3.9*:3.9/3.9=)
+		ver := pkg
+		slot := ""
+		if idx := strings.Index(pkg, ":"); idx >= 0 {
+			ver = pkg[0:idx]
+			slot = pkg[idx:]
+		}
+		if strings.HasSuffix(ver, "*") {
 			ans.Condition = PkgCondMatchVersion
-			pkg = pkg[0 : len(pkg)-1]
+			pkg = ver[0:len(ver)-1] + slot
 		} else {
 			ans.Condition = PkgCondEqual
 		}
@@ -566,30 +625,8 @@ func ParsePackageStr(pkg string) (*GentooPackage, error) {
 		ans.Condition = PkgCondNot
 	}
 
-	regexVerString := fmt.Sprintf("[-](%s|%s|%s|%s|%s|%s)((%s|%s|%s|%s|%s|%s||%s)+)*",
-		// Version regex
-		// 1.1
-		"[0-9]+[.][0-9]+[a-z]*",
-		// 1
-		"[0-9]+[a-z]*",
-		// 1.1.1
-		"[0-9]+[.][0-9]+[.][0-9]+[a-z]*",
-		// 1.1.1.1
-		"[0-9]+[.][0-9]+[.][0-9]+[.][0-9]+[a-z]*",
-		// 1.1.1.1.1
-		"[0-9]+[.][0-9]+[.][0-9]+[.][0-9]+[.][0-9]+[a-z]*",
-		// 1.1.1.1.1.1
-		"[0-9]+[.][0-9]+[.][0-9]+[.][0-9]+[.][0-9]+[.][0-9]+[a-z]*",
-		// suffix
-		"-r[0-9]+",
-		"_p[0-9]+",
-		"_pre[0-9]*",
-		"_rc[0-9]+",
-		// handle also rc without number
-		"_rc",
-		"_alpha[0-9-a-z]*",
-		"_beta[0-9-a-z]*",
-	)
+	// The version follows the same grammar of ParseVersion.
+	regexVerString := fmt.Sprintf("[-](%s)", RegexVersionString)
 
 	// The slash is used also in slot.
 	if strings.Index(pkg, "/") < 0 {
@@ -643,11 +680,24 @@ func ParsePackageStr(pkg string) (*GentooPackage, error) {
 			//      | <identifier characters> <non-digit> <identifier characters>
 			ans.VersionBuild = pkgname[buildIdx+1:]
 			pkgname = pkgname[0:buildIdx]
+
+			// The build could be followed by the slot or the repository
+			// (ex. foo-1.0+2:0::repo)
+			if idx := strings.Index(ans.VersionBuild, ":"); idx >= 0 {
+				pkgname += ans.VersionBuild[idx:]
+				ans.VersionBuild = ans.VersionBuild[0:idx]
+			}
+
+			// The * is the version glob and not a build (ex. =foo-1.0+*)
+			if !regexBuildValid.MatchString(ans.VersionBuild) {
+				return nil, errors.New(
+					fmt.Sprintf("Invalid build %s for package %s", ans.VersionBuild, pkg))
+			}
 		}
 	}
 
 	// Check if there are use flags annotation
-	if strings.Index(pkgname, "[") > 0 {
+	if strings.Index(pkgname, "[") > 0 && strings.Index(pkgname, "]") > strings.Index(pkgname, "[") {
 		useFlags := pkgname[strings.Index(pkgname, "[")+1 : strings.Index(pkgname, "]")]
 		ans.UseFlags = strings.Split(useFlags, ",")
 		p := pkgname[0:strings.Index(pkgname, "[")]
@@ -667,7 +717,10 @@ func ParsePackageStr(pkg string) (*GentooPackage, error) {
 	// Check if has slot
 	if strings.Contains(pkgname, ":") {
 		words := strings.Split(pkgname, ":")
-		ans.Slot = words[1]
+		if words[1] == "" {
+			return nil, errors.New(fmt.Sprintf("Invalid slot for package %s", pkg))
+		}
+		ans.SetSlotStr(words[1])
 		pkgname = words[0]
 	}
 
@@ -685,26 +738,19 @@ func ParsePackageStr(pkg string) (*GentooPackage, error) {
 
 		matches = regexPkg.FindAllString(pkgname, -1)
 
-		// NOTE: Now suffix comples like _alpha_rc1 are not supported.
 		if len(matches) > 0 {
-			// Check if there patch
-			if strings.Contains(matches[0], "_p") {
-				ans.Version = matches[0][1:strings.Index(matches[0], "_p")]
-				ans.VersionSuffix = matches[0][strings.Index(matches[0], "_p"):]
-			} else if strings.Contains(matches[0], "_rc") {
-				ans.Version = matches[0][1:strings.Index(matches[0], "_rc")]
-				ans.VersionSuffix = matches[0][strings.Index(matches[0], "_rc"):]
-			} else if strings.Contains(matches[0], "_alpha") {
-				ans.Version = matches[0][1:strings.Index(matches[0], "_alpha")]
-				ans.VersionSuffix = matches[0][strings.Index(matches[0], "_alpha"):]
-			} else if strings.Contains(matches[0], "_beta") {
-				ans.Version = matches[0][1:strings.Index(matches[0], "_beta")]
-				ans.VersionSuffix = matches[0][strings.Index(matches[0], "_beta"):]
-			} else if strings.Contains(matches[0], "-r") {
-				ans.Version = matches[0][1:strings.Index(matches[0], "-r")]
-				ans.VersionSuffix = matches[0][strings.Index(matches[0], "-r"):]
+			// The version suffixes start from the first _ or -r
+			// (ex. 1.2_alpha1_pre3-r1).
+			v := matches[0][1:]
+			idx := strings.Index(v, "_")
+			if rIdx := strings.Index(v, "-r"); rIdx >= 0 && (idx < 0 || rIdx < idx) {
+				idx = rIdx
+			}
+			if idx >= 0 {
+				ans.Version = v[0:idx]
+				ans.VersionSuffix = v[idx:]
 			} else {
-				ans.Version = matches[0][1:]
+				ans.Version = v
 			}
 			ans.Name = pkgname[0 : len(pkgname)-len(ans.Version)-1-len(ans.VersionSuffix)]
 		} else {
@@ -712,6 +758,14 @@ func ParsePackageStr(pkg string) (*GentooPackage, error) {
 		}
 	}
 
+	// Validate category and name
+	if !regexNameValid.MatchString(ans.Category) {
+		return nil, errors.New(fmt.Sprintf("Invalid category %s", ans.Category))
+	}
+	if !regexNameValid.MatchString(ans.Name) {
+		return nil, errors.New(fmt.Sprintf("Invalid package name %s", ans.Name))
+	}
+
 	// Set condition if there isn't a prefix but only a version
 	if ans.Condition == PkgCondInvalid && ans.Version != "" {
 		ans.Condition = PkgCondEqual
@@ -43,7 +43,8 @@ var _ = Describe("Gentoo Packages", func() {
 				Name:          "ncurses",
 				Category:      "sys-libs",
 				Condition:     PkgCondGreaterEqual,
-				Slot:          "0=",
+				Slot:          "0",
+				SlotOperator:  SlotOperatorEqual,
 				Version:       "5.2",
 				VersionSuffix: "-r5",
 				VersionBuild:  "",
@@ -81,7 +82,8 @@ var _ = Describe("Gentoo Packages", func() {
 				Name:          "ncurses",
 				Category:      "sys-libs",
 				Condition:     PkgCondGreaterEqual,
-				Slot:          "0*",
+				Slot:          "0",
+				SlotOperator:  SlotOperatorAny,
 				Version:       "5.2",
 				VersionSuffix: "-r5",
 				VersionBuild:  "",
@@ -119,7 +121,8 @@ var _ = Describe("Gentoo Packages", func() {
 				Name:          "ncurses",
 				Category:      "sys-libs",
 				Condition:     PkgCondGreaterEqual,
-				Slot:          "*",
+				Slot:          "",
+				SlotOperator:  SlotOperatorAny,
 				Version:       "5.2",
 				VersionSuffix: "-r5",
 				VersionBuild:  "",
@@ -1747,8 +1750,10 @@ var _ = Describe("Gentoo Packages", func() {
 				Expect(err).Should(BeNil())
 			})
 
+			// The ~ operator ignores only the revision: 2.0_rc1 is
+			// a different version.
 			It("Check Admit", func() {
-				Expect(admitted).Should(Equal(true))
+				Expect(admitted).Should(Equal(false))
 			})
 
 		})
@@ -2103,6 +2108,74 @@ var _ = Describe("Gentoo Packages", func() {
 			})
 		})
 
+		Context("Version with patch suffix without number", func() {
+			gp, err := ParsePackageStr("app-misc/foo-1.0_p")
+			It("Check error", func() {
+				Expect(err).Should(BeNil())
+			})
+
+			It("Check package name", func() {
+				Expect(gp.GetPackageName()).Should(Equal("app-misc/foo"))
+			})
+
+			It("Check package version", func() {
+				Expect(gp.Version).Should(Equal("1.0"))
+				Expect(gp.VersionSuffix).Should(Equal("_p"))
+			})
+		})
+
+		Context("Version with seven components", func() {
+			gp, err := ParsePackageStr("cat/foo-1.2.3.4.5.6.7")
+			It("Check error", func() {
+				Expect(err).Should(BeNil())
+			})
+
+			It("Check package name", func() {
+				Expect(gp.GetPackageName()).Should(Equal("cat/foo"))
+			})
+
+			It("Check package version", func() {
+				Expect(gp.Version).Should(Equal("1.2.3.4.5.6.7"))
+				Expect(gp.VersionSuffix).Should(Equal(""))
+			})
+		})
+
+		Context("Invalid build", func() {
+			It("Check glob as build", func() {
+				_, err := ParsePackageStr("0/0-0+*")
+				Expect(err).ShouldNot(BeNil())
+			})
+
+			It("Check empty build", func() {
+				_, err := ParsePackageStr("cat/foo-1.0+:0")
+				Expect(err).ShouldNot(BeNil())
+			})
+
+			It("Check glob after build", func() {
+				gp, err := ParsePackageStr("=cat/foo-1.0+2*")
+				Expect(err).Should(BeNil())
+				Expect(gp.VersionBuild).Should(Equal("2"))
+				Expect(gp.Condition.String()).Should(Equal("=*"))
+			})
+		})
+
+		Context("Version with letter, suffixes and revision", func() {
+			gp, err := ParsePackageStr("=dev-libs/foo-bar-2.1b_alpha_p3-r2:0")
+			It("Check error", func() {
+				Expect(err).Should(BeNil())
+			})
+
+			It("Check package name", func() {
+				Expect(gp.GetPackageName()).Should(Equal("dev-libs/foo-bar"))
+				Expect(gp.Slot).Should(Equal("0"))
+			})
+
+			It("Check package version", func() {
+				Expect(gp.Version).Should(Equal("2.1b"))
+				Expect(gp.VersionSuffix).Should(Equal("_alpha_p3-r2"))
+			})
+		})
+
 		Context("ConfrontVersions", func() {
 			gp, err := ParsePackageStr(">=sys-power-5.3/acpi_call-3.17.5.3.2.1")
 			It("Check error", func() {
@@ -0,0 +1,276 @@
+/*
+
+Copyright (C) 2017-2021  Daniele Rondina <geaaru@sabayonlinux.org>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+*/
+
+package gentoo
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	DepKindRdepend = "RDEPEND"
+	DepKindDepend  = "DEPEND"
+	DepKindBdepend = "BDEPEND"
+	DepKindPdepend = "PDEPEND"
+)
+
+var DefaultDepKinds = []string{DepKindRdepend, DepKindDepend, DepKindPdepend}
+
+// DependencyEdge is a dependency between two installed packages.
+type DependencyEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Atom string `json:"atom"`
+	Kind string `json:"kind"`
+}
+
+// DependencyGraph contains the dependencies of the installed packages
+// resolved with the USE flags recorded in the vdb.
+type DependencyGraph struct {
+	Packages map[string]*PortageMetaData `json:"-"`
+
+	deps  map[string][]DependencyEdge
+	rdeps map[string][]DependencyEdge
+	names map[string][]string
+}
+
+// ReverseDeps is the result of a reverse dependencies query.
+// Depth is the distance of every package from the targets.
+type ReverseDeps struct {
+	Targets  []string         `json:"targets"`
+	Packages map[string]int   `json:"packages"`
+	Edges    []DependencyEdge `json:"edges"`
+}
+
+func NewDependencyGraph() *DependencyGraph {
+	return &DependencyGraph{
+		Packages: make(map[string]*PortageMetaData, 0),
+		deps:     make(map[string][]DependencyEdge, 0),
+		rdeps:    make(map[string][]DependencyEdge, 0),
+		names:    make(map[string][]string, 0),
+	}
+}
+
+// BuildDependencyGraph loads the packages of the vdb of the scanner and
+// resolves the dependencies of the kinds supplied.
+func BuildDependencyGraph(s *VdbScanner, kinds []string) (*DependencyGraph, error) {
+	ans := NewDependencyGraph()
+
+	fields := []PortageMetaField{MetaFieldSlot, MetaFieldIUseEffective, MetaFieldUse}
+	for _, k := range kinds {
+		switch k {
+		case DepKindRdepend, DepKindDepend, DepKindBdepend, DepKindPdepend:
+			fields = append(fields, PortageMetaField(k))
+		default:
+			return nil, errors.New("Invalid dependency kind " + k)
+		}
+	}
+
+	scanner, err := s.WithFields(fields...)
+	if err != nil {
+		return nil, err
+	}
+
+	err = scanner.Walk(func(pm *PortageMetaData) error {
+		ans.AddPackage(pm)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = ans.Resolve(kinds)
+	if err != nil {
+		return nil, err
+	}
+
+	return ans, nil
+}
+
+func (g *DependencyGraph) AddPackage(pm *PortageMetaData) {
+	pkg := pm.GetPackageNameWithVersion()
+	if _, ok := g.Packages[pkg]; !ok {
+		g.names[pm.GetPackageName()] = append(g.names[pm.GetPackageName()], pkg)
+	}
+	g.Packages[pkg] = pm
+}
+
+func (m *PortageMetaData) getDependencies(kind string) string {
+	switch kind {
+	case DepKindRdepend:
+		return m.RDEPEND
+	case DepKindDepend:
+		return m.DEPEND
+	case DepKindBdepend:
+		return m.BDEPEND
+	case DepKindPdepend:
+		return m.PDEPEND
+	}
+	return ""
+}
+
+// Match returns the installed packages admitted by the atom. The USE
+// dependencies are evaluated with the USE flags supplied.
+func (g *DependencyGraph) Match(atom *GentooPackage, uses []string) []string {
+	ans := []string{}
+	for _, pkg := range g.names[atom.GetPackageName()] {
+		admitted, _ := atom.AdmitWithUse(g.Packages[pkg].GentooPackage, uses)
+		if admitted {
+			ans = append(ans, pkg)
+		}
+	}
+	sort.Strings(ans)
+	return ans
+}
+
+// Resolve creates the edges of the graph from the dependencies of
+// the kinds supplied.
+func (g *DependencyGraph) Resolve(kinds []string) error {
+	g.deps = make(map[string][]DependencyEdge, 0)
+	g.rdeps = make(map[string][]DependencyEdge, 0)
+
+	for pkg, pm := range g.Packages {
+		for _, kind := range kinds {
+			deps, err := ParseDependencies(pm.getDependencies(kind))
+			if err != nil {
+				return errors.New(
+					fmt.Sprintf("Error on parse %s of %s: %s", kind, pkg, err.Error()))
+			}
+
+			for _, atom := range deps.Reduce(pm.Use) {
+				for _, to := range g.Match(atom, pm.Use) {
+					if to == pkg {
+						continue
+					}
+					e := DependencyEdge{
+						From: pkg,
+						To:   to,
+						Atom: atom.Format(),
+						Kind: kind,
+					}
+					g.deps[pkg] = append(g.deps[pkg], e)
+					g.rdeps[to] = append(g.rdeps[to], e)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// GetDeps returns the dependencies of the installed package.
+func (g *DependencyGraph) GetDeps(pkg string) []DependencyEdge {
+	return g.deps[pkg]
+}
+
+// GetReverseDeps returns the installed packages that depend on the
+// packages matching the atom. With transitive the reverse dependencies
+// of the reverse dependencies are returned too.
+func (g *DependencyGraph) GetReverseDeps(atom string, transitive bool) (*ReverseDeps, error) {
+	gp, err := ParseAtom(atom)
+	if err != nil {
+		return nil, err
+	}
+
+	ans := &ReverseDeps{
+		Targets:  g.Match(gp, nil),
+		Packages: make(map[string]int, 0),
+		Edges:    []DependencyEdge{},
+	}
+	if len(ans.Targets) == 0 {
+		return nil, errors.New(
+			fmt.Sprintf("No installed packages match %s", atom))
+	}
+
+	visited := make(map[string]bool, 0)
+	queue := []string{}
+	for _, t := range ans.Targets {
+		visited[t] = true
+		queue = append(queue, t)
+	}
+
+	depth := 1
+	for len(queue) > 0 {
+		next := []string{}
+		for _, pkg := range queue {
+			for _, e := range g.rdeps[pkg] {
+				ans.Edges = append(ans.Edges, e)
+				if visited[e.From] {
+					continue
+				}
+				visited[e.From] = true
+				ans.Packages[e.From] = depth
+				next = append(next, e.From)
+			}
+		}
+
+		if !transitive {
+			break
+		}
+		queue = next
+		depth++
+	}
+
+	sort.Slice(ans.Edges, func(i, j int) bool {
+		if ans.Edges[i].From != ans.Edges[j].From {
+			return ans.Edges[i].From < ans.Edges[j].From
+		}
+		if ans.Edges[i].To != ans.Edges[j].To {
+			return ans.Edges[i].To < ans.Edges[j].To
+		}
+		return ans.Edges[i].Kind < ans.Edges[j].Kind
+	})
+
+	return ans, nil
+}
+
+// GetPackages returns the reverse dependencies sorted by depth and name.
+func (r *ReverseDeps) GetPackages() []string {
+	ans := []string{}
+	for p, _ := range r.Packages {
+		ans = append(ans, p)
+	}
+	sort.Slice(ans, func(i, j int) bool {
+		if r.Packages[ans[i]] != r.Packages[ans[j]] {
+			return r.Packages[ans[i]] < r.Packages[ans[j]]
+		}
+		return ans[i] < ans[j]
+	})
+	return ans
+}
+
+// Dot returns the graph of the reverse dependencies in DOT format.
+func (r *ReverseDeps) Dot() string {
+	var b strings.Builder
+
+	b.WriteString("digraph rdeps {\n")
+	for _, t := range r.Targets {
+		b.WriteString(fmt.Sprintf("  %q [shape=box];\n", t))
+	}
+	for _, e := range r.Edges {
+		b.WriteString(fmt.Sprintf("  %q -> %q [label=%q];\n",
+			e.From, e.To, e.Kind+" "+e.Atom))
+	}
+	b.WriteString("}\n")
+
+	return b.String()
+}
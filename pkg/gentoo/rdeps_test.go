@@ -0,0 +1,150 @@
+/*
+
+Copyright (C) 2017-2021  Daniele Rondina <geaaru@sabayonlinux.org>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+*/
+
+package gentoo_test
+
+import (
+	"io/ioutil"
+	"os"
+
+	. "github.com/Sabayon/pkgs-checker/pkg/gentoo"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Gentoo reverse dependencies", func() {
+
+	var tmpdir string
+	var g *DependencyGraph
+
+	BeforeEach(func() {
+		var err error
+		tmpdir, err = ioutil.TempDir("", "rdeps")
+		Expect(err).Should(BeNil())
+
+		writeVdbPackage(tmpdir, "dev-libs/openssl-1.1.1k", map[string]string{
+			"SLOT":    "0/1.1",
+			"RDEPEND": ">=sys-libs/zlib-1.2.8-r1",
+		})
+		writeVdbPackage(tmpdir, "sys-libs/zlib-1.2.11", map[string]string{
+			"SLOT": "0/1",
+		})
+		writeVdbPackage(tmpdir, "dev-lang/python-3.9.4", map[string]string{
+			"SLOT":           "3.9/3.9",
+			"IUSE_EFFECTIVE": "ssl sqlite",
+			"USE":            "ssl",
+			"RDEPEND":        "ssl? ( dev-libs/openssl:0= ) sqlite? ( dev-db/sqlite:3 ) sys-libs/zlib:=",
+		})
+		writeVdbPackage(tmpdir, "net-misc/curl-7.76.1", map[string]string{
+			"IUSE_EFFECTIVE": "ssl",
+			"USE":            "",
+			"RDEPEND":        "ssl? ( dev-libs/openssl:0= )",
+			"DEPEND":         "dev-libs/openssl",
+		})
+		writeVdbPackage(tmpdir, "app-portage/gentoolkit-0.5.0", map[string]string{
+			"RDEPEND": "dev-lang/python:3.9[ssl] !<dev-libs/openssl-1.0",
+		})
+		writeVdbPackage(tmpdir, "app-misc/needs-sqlite-1.0", map[string]string{
+			"RDEPEND": "dev-lang/python[sqlite]",
+		})
+
+		g, err = BuildDependencyGraph(NewVdbScanner(tmpdir, nil), DefaultDepKinds)
+		Expect(err).Should(BeNil())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tmpdir)
+	})
+
+	It("Check direct reverse dependencies", func() {
+		r, err := g.GetReverseDeps("dev-libs/openssl:0", false)
+		Expect(err).Should(BeNil())
+		Expect(r.Targets).Should(Equal([]string{"dev-libs/openssl-1.1.1k"}))
+		Expect(r.GetPackages()).Should(Equal([]string{
+			"dev-lang/python-3.9.4",
+			"net-misc/curl-7.76.1",
+		}))
+		Expect(r.Edges).Should(Equal([]DependencyEdge{
+			{From: "dev-lang/python-3.9.4", To: "dev-libs/openssl-1.1.1k", Atom: "dev-libs/openssl:0=", Kind: DepKindRdepend},
+			{From: "net-misc/curl-7.76.1", To: "dev-libs/openssl-1.1.1k", Atom: "dev-libs/openssl", Kind: DepKindDepend},
+		}))
+	})
+
+	It("Check transitive reverse dependencies", func() {
+		r, err := g.GetReverseDeps("sys-libs/zlib", true)
+		Expect(err).Should(BeNil())
+		Expect(r.GetPackages()).Should(Equal([]string{
+			"dev-lang/python-3.9.4",
+			"dev-libs/openssl-1.1.1k",
+			"app-portage/gentoolkit-0.5.0",
+			"net-misc/curl-7.76.1",
+		}))
+		Expect(r.Packages["app-portage/gentoolkit-0.5.0"]).Should(Equal(2))
+	})
+
+	It("Check dependencies", func() {
+		Expect(len(g.GetDeps("app-misc/needs-sqlite-1.0"))).Should(Equal(0))
+		Expect(g.GetDeps("app-portage/gentoolkit-0.5.0")).Should(Equal([]DependencyEdge{
+			{From: "app-portage/gentoolkit-0.5.0", To: "dev-lang/python-3.9.4", Atom: "dev-lang/python:3.9[ssl]", Kind: DepKindRdepend},
+		}))
+	})
+
+	It("Check DOT output", func() {
+		r, err := g.GetReverseDeps("=dev-libs/openssl-1.1.1k", false)
+		Expect(err).Should(BeNil())
+		Expect(r.Dot()).Should(Equal(`digraph rdeps {
+  "dev-libs/openssl-1.1.1k" [shape=box];
+  "dev-lang/python-3.9.4" -> "dev-libs/openssl-1.1.1k" [label="RDEPEND dev-libs/openssl:0="];
+  "net-misc/curl-7.76.1" -> "dev-libs/openssl-1.1.1k" [label="DEPEND dev-libs/openssl"];
+}
+`))
+	})
+
+	It("Check missing package", func() {
+		_, err := g.GetReverseDeps("dev-libs/libressl", false)
+		Expect(err).ShouldNot(BeNil())
+	})
+
+	It("Check invalid kind", func() {
+		_, err := BuildDependencyGraph(NewVdbScanner(tmpdir, nil), []string{"RDEPENDS"})
+		Expect(err).ShouldNot(BeNil())
+	})
+
+	It("Check scanner fields", func() {
+		s := NewVdbScanner(tmpdir, nil)
+		Expect(s.SetFields(MetaFieldCounter)).Should(BeNil())
+		_, err := BuildDependencyGraph(s, DefaultDepKinds)
+		Expect(err).Should(BeNil())
+		Expect(s.Fields).Should(Equal([]PortageMetaField{MetaFieldCounter}))
+	})
+
+	It("Check atom without slot", func() {
+		gp, err := ParseAtom("dev-lang/python[ssl]")
+		Expect(err).Should(BeNil())
+		Expect(gp.Slot).Should(Equal(""))
+		gp, err = ParseAtom("dev-lang/python::gentoo")
+		Expect(err).Should(BeNil())
+		Expect(gp.Slot).Should(Equal(""))
+		gp, err = ParseAtom("dev-lang/python:3.9::gentoo")
+		Expect(err).Should(BeNil())
+		Expect(gp.Slot).Should(Equal("3.9"))
+	})
+
+})
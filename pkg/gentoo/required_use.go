@@ -0,0 +1,227 @@
+/*
+
+Copyright (C) 2017-2021  Daniele Rondina <geaaru@sabayonlinux.org>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+*/
+
+package gentoo
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Parser of the REQUIRED_USE variable as described by PMS section 8.2.
+
+type GentooRequiredUse struct {
+	Type GentooDependencyType `json:"type"`
+
+	// Valorized only for DepTypeAtom
+	Flag    string `json:"flag,omitempty"`
+	Negated bool   `json:"negated,omitempty"`
+
+	// Valorized only for DepTypeUseConditional
+	Use        string `json:"use,omitempty"`
+	UseNegated bool   `json:"use_negated,omitempty"`
+
+	Children []*GentooRequiredUse `json:"children,omitempty"`
+
+	root bool
+}
+
+var requiredUseParser = &depSpecParser{
+	name:      "REQUIRED_USE",
+	operators: depSpecAllOperators,
+	newGroup: func(t GentooDependencyType, use string, useNegated bool) depSpecNode {
+		return &GentooRequiredUse{
+			Type:       t,
+			Use:        use,
+			UseNegated: useNegated,
+			Children:   []*GentooRequiredUse{},
+		}
+	},
+	newLeaf: func(token string, pos int) (depSpecNode, error) {
+		ans := &GentooRequiredUse{
+			Type: DepTypeAtom,
+			Flag: token,
+		}
+		if strings.HasPrefix(token, "!") {
+			ans.Negated = true
+			ans.Flag = token[1:]
+		}
+		if !regexNameValid.MatchString(ans.Flag) {
+			return nil, errors.New(
+				fmt.Sprintf("Invalid USE flag %s at position %d", token, pos))
+		}
+		return ans, nil
+	},
+}
+
+// ParseRequiredUse parses a REQUIRED_USE string and returns the root
+// of the tree as a DepTypeAllOf group.
+func ParseRequiredUse(requiredUse string) (*GentooRequiredUse, error) {
+	node, err := requiredUseParser.parse(requiredUse)
+	if err != nil {
+		return nil, err
+	}
+	ans := node.(*GentooRequiredUse)
+	ans.root = true
+	return ans, nil
+}
+
+func (r *GentooRequiredUse) addChild(child depSpecNode) {
+	r.Children = append(r.Children, child.(*GentooRequiredUse))
+}
+
+// Check evaluates the tree against the enabled USE flags and returns
+// the list of the constraints not satisfied. An empty list means that
+// the USE combination is valid.
+func (r *GentooRequiredUse) Check(uses []string) []string {
+	return r.check(newUseSet(uses))
+}
+
+func (r *GentooRequiredUse) check(uses map[string]bool) []string {
+	ans := []string{}
+
+	switch r.Type {
+	case DepTypeAllOf:
+		for _, c := range r.Children {
+			ans = append(ans, c.check(uses)...)
+		}
+
+	case DepTypeUseConditional:
+		if !r.isActive(uses) {
+			break
+		}
+		for _, c := range r.Children {
+			for _, v := range c.check(uses) {
+				ans = append(ans, r.conditionPrefix()+"( "+v+" )")
+			}
+		}
+
+	default:
+		if !r.isSatisfied(uses) {
+			ans = append(ans, r.String())
+		}
+	}
+
+	return ans
+}
+
+// IsSatisfied returns true if the constraint is satisfied with the
+// enabled USE flags.
+func (r *GentooRequiredUse) IsSatisfied(uses []string) bool {
+	return r.isSatisfied(newUseSet(uses))
+}
+
+func (r *GentooRequiredUse) isActive(uses map[string]bool) bool {
+	return uses[r.Use] != r.UseNegated
+}
+
+func (r *GentooRequiredUse) isSatisfied(uses map[string]bool) bool {
+	if r.Type == DepTypeAtom {
+		return uses[r.Flag] != r.Negated
+	}
+
+	if r.Type == DepTypeUseConditional && !r.isActive(uses) {
+		return true
+	}
+
+	satisfied := 0
+	children := 0
+	for _, c := range r.Children {
+		// The inactive conditional groups are ignored
+		// by the any-of, exactly-one and at-most-one groups.
+		if c.Type == DepTypeUseConditional && !c.isActive(uses) {
+			continue
+		}
+		children++
+		if c.isSatisfied(uses) {
+			satisfied++
+		}
+	}
+
+	switch r.Type {
+	case DepTypeAnyOf:
+		return children == 0 || satisfied > 0
+	case DepTypeExactlyOne:
+		return children == 0 || satisfied == 1
+	case DepTypeAtMostOne:
+		return satisfied <= 1
+	}
+
+	return satisfied == children
+}
+
+func (r *GentooRequiredUse) conditionPrefix() string {
+	neg := ""
+	if r.UseNegated {
+		neg = "!"
+	}
+	return neg + r.Use + "? "
+}
+
+func (r *GentooRequiredUse) String() string {
+	if r.Type == DepTypeAtom {
+		if r.Negated {
+			return "!" + r.Flag
+		}
+		return r.Flag
+	}
+
+	children := []string{}
+	for _, c := range r.Children {
+		children = append(children, c.String())
+	}
+	body := strings.Join(children, " ")
+	if r.root && r.Type == DepTypeAllOf {
+		return body
+	}
+
+	group := "( )"
+	if body != "" {
+		group = "( " + body + " )"
+	}
+
+	switch r.Type {
+	case DepTypeAnyOf:
+		return "|| " + group
+	case DepTypeExactlyOne:
+		return "^^ " + group
+	case DepTypeAtMostOne:
+		return "?? " + group
+	case DepTypeUseConditional:
+		return r.conditionPrefix() + group
+	}
+
+	return group
+}
+
+// CheckRequiredUse validates the REQUIRED_USE of the package with
+// the USE flags recorded.
+func (m *PortageMetaData) CheckRequiredUse() ([]string, error) {
+	if m.REQUIRED_USE == "" {
+		return []string{}, nil
+	}
+
+	r, err := ParseRequiredUse(m.REQUIRED_USE)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.Check(m.Use), nil
+}
@@ -0,0 +1,134 @@
+/*
+
+Copyright (C) 2017-2021  Daniele Rondina <geaaru@sabayonlinux.org>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+*/
+
+
+package gentoo_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/Sabayon/pkgs-checker/pkg/gentoo"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Gentoo REQUIRED_USE", func() {
+
+	Context("Parse REQUIRED_USE", func() {
+		requiredUse := "^^ ( python_targets_python3_8 python_targets_python3_9 ) ssl? ( || ( gnutls openssl ) !libressl ) ?? ( a b ) ( c d )"
+		r, err := ParseRequiredUse(requiredUse)
+
+		It("Check error", func() {
+			Expect(err).Should(BeNil())
+		})
+
+		It("Check tree", func() {
+			Expect(len(r.Children)).Should(Equal(4))
+			Expect(r.Children[0].Type).Should(Equal(DepTypeExactlyOne))
+			Expect(r.Children[1].Type).Should(Equal(DepTypeUseConditional))
+			Expect(r.Children[1].Children[1].Negated).Should(BeTrue())
+			Expect(r.Children[1].Children[1].Flag).Should(Equal("libressl"))
+		})
+
+		It("Check String", func() {
+			Expect(r.String()).Should(Equal(requiredUse))
+		})
+	})
+
+	DescribeTable("Check REQUIRED_USE",
+		func(requiredUse string, uses []string, unsatisfied []string) {
+			r, err := ParseRequiredUse(requiredUse)
+			Expect(err).Should(BeNil())
+			Expect(r.Check(uses)).Should(Equal(unsatisfied))
+			Expect(r.IsSatisfied(uses)).Should(Equal(len(unsatisfied) == 0))
+		},
+		Entry("empty", "", []string{}, []string{}),
+		Entry("flag enabled", "a", []string{"a"}, []string{}),
+		Entry("flag disabled", "a", []string{}, []string{"a"}),
+		Entry("negated flag", "!a", []string{"a"}, []string{"!a"}),
+		Entry("any-of ok", "|| ( a b )", []string{"b"}, []string{}),
+		Entry("any-of ko", "|| ( a b )", []string{"c"}, []string{"|| ( a b )"}),
+		Entry("exactly-one ok", "^^ ( a b c )", []string{"b"}, []string{}),
+		Entry("exactly-one none", "^^ ( a b c )", []string{}, []string{"^^ ( a b c )"}),
+		Entry("exactly-one two", "^^ ( a b c )", []string{"a", "c"}, []string{"^^ ( a b c )"}),
+		Entry("at-most-one none", "?? ( a b )", []string{}, []string{}),
+		Entry("at-most-one two", "?? ( a b )", []string{"a", "b"}, []string{"?? ( a b )"}),
+		Entry("conditional inactive", "ssl? ( || ( gnutls openssl ) )", []string{}, []string{}),
+		Entry("conditional active", "ssl? ( || ( gnutls openssl ) )", []string{"ssl"},
+			[]string{"ssl? ( || ( gnutls openssl ) )"}),
+		Entry("negated conditional", "!ssl? ( !openssl )", []string{"openssl"},
+			[]string{"!ssl? ( !openssl )"}),
+		Entry("nested conditional", "a? ( b? ( c ) )", []string{"a", "b"},
+			[]string{"a? ( b? ( c ) )"}),
+		Entry("conditional in exactly-one", "^^ ( a? ( b ) c )", []string{"c"}, []string{}),
+		Entry("conditional in exactly-one active", "^^ ( a? ( b ) c )", []string{"a", "b", "c"},
+			[]string{"^^ ( a? ( b ) c )"}),
+		Entry("all-of in any-of", "|| ( ( a b ) c )", []string{"a", "b"}, []string{}),
+		Entry("all-of in any-of ko", "|| ( ( a b ) c )", []string{"a"}, []string{"|| ( ( a b ) c )"}),
+		Entry("multiple errors", "a b", []string{}, []string{"a", "b"}),
+	)
+
+	DescribeTable("Invalid REQUIRED_USE",
+		func(requiredUse string) {
+			_, err := ParseRequiredUse(requiredUse)
+			Expect(err).ShouldNot(BeNil())
+		},
+		Entry("missing close", "^^ ( a b"),
+		Entry("missing open", "^^ a b"),
+		Entry("invalid conditional", "? ( a )"),
+		Entry("invalid flag", "!"),
+	)
+
+	Context("Check vdb package", func() {
+		var tmpdir string
+
+		BeforeEach(func() {
+			var err error
+			tmpdir, err = ioutil.TempDir("", "required-use")
+			Expect(err).Should(BeNil())
+
+			pkgdir := filepath.Join(tmpdir, "dev-lang", "python-3.9.1")
+			Expect(os.MkdirAll(pkgdir, 0755)).Should(BeNil())
+			Expect(ioutil.WriteFile(filepath.Join(pkgdir, "REQUIRED_USE"),
+				[]byte("^^ ( ssl libressl )\n"), 0644)).Should(BeNil())
+			Expect(ioutil.WriteFile(filepath.Join(pkgdir, "USE"),
+				[]byte("amd64 elibc_glibc libressl ssl\n"), 0644)).Should(BeNil())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(tmpdir)
+		})
+
+		It("Check invalid USE", func() {
+			pkgs, err := ParseMetadataDir(tmpdir, &PortageUseParseOpts{})
+			Expect(err).Should(BeNil())
+			Expect(len(pkgs)).Should(Equal(1))
+			Expect(pkgs[0].REQUIRED_USE).Should(Equal("^^ ( ssl libressl )"))
+
+			unsatisfied, err := pkgs[0].CheckRequiredUse()
+			Expect(err).Should(BeNil())
+			Expect(unsatisfied).Should(Equal([]string{"^^ ( ssl libressl )"}))
+		})
+	})
+
+})
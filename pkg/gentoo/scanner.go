@@ -0,0 +1,496 @@
+/*
+
+Copyright (C) 2017-2021  Daniele Rondina <geaaru@sabayonlinux.org>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+*/
+
+package gentoo
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// PortageMetaField identifies a metadata file of a vdb package.
+type PortageMetaField string
+
+const (
+	MetaFieldBdepend       PortageMetaField = "BDEPEND"
+	MetaFieldRdepend       PortageMetaField = "RDEPEND"
+	MetaFieldDepend        PortageMetaField = "DEPEND"
+	MetaFieldPdepend       PortageMetaField = "PDEPEND"
+	MetaFieldBuildTime     PortageMetaField = "BUILD_TIME"
+	MetaFieldCbuild        PortageMetaField = "CBUILD"
+	MetaFieldCounter       PortageMetaField = "COUNTER"
+	MetaFieldDefinedPhases PortageMetaField = "DEFINED_PHASES"
+	MetaFieldDescription   PortageMetaField = "DESCRIPTION"
+	MetaFieldFeatures      PortageMetaField = "FEATURES"
+	MetaFieldHomepage      PortageMetaField = "HOMEPAGE"
+	MetaFieldInherited     PortageMetaField = "INHERITED"
+	MetaFieldNeeded        PortageMetaField = "NEEDED"
+	MetaFieldNeededElf2    PortageMetaField = "NEEDED.ELF.2"
+	MetaFieldPkgUse        PortageMetaField = "PKGUSE"
+	MetaFieldRestrict      PortageMetaField = "RESTRICT"
+	MetaFieldRequiredUse   PortageMetaField = "REQUIRED_USE"
+	MetaFieldSlot          PortageMetaField = "SLOT"
+	MetaFieldEapi          PortageMetaField = "EAPI"
+	MetaFieldCFlags        PortageMetaField = "CFLAGS"
+	MetaFieldCxxFlags      PortageMetaField = "CXXFLAGS"
+	MetaFieldLdFlags       PortageMetaField = "LDFLAGS"
+	MetaFieldCHost         PortageMetaField = "CHOST"
+	MetaFieldLicense       PortageMetaField = "LICENSE"
+	MetaFieldRepository    PortageMetaField = "repository"
+	MetaFieldRequires      PortageMetaField = "REQUIRES"
+	MetaFieldKeywords      PortageMetaField = "KEYWORDS"
+	MetaFieldProvides      PortageMetaField = "PROVIDES"
+	MetaFieldSize          PortageMetaField = "SIZE"
+	MetaFieldIUse          PortageMetaField = "IUSE"
+	MetaFieldIUseEffective PortageMetaField = "IUSE_EFFECTIVE"
+	MetaFieldUse           PortageMetaField = "USE"
+	MetaFieldEbuild        PortageMetaField = "ebuild"
+	MetaFieldContents      PortageMetaField = "CONTENTS"
+)
+
+var AllPortageMetaFields = []PortageMetaField{
+	MetaFieldBdepend,
+	MetaFieldRdepend,
+	MetaFieldDepend,
+	MetaFieldPdepend,
+	MetaFieldBuildTime,
+	MetaFieldCbuild,
+	MetaFieldCounter,
+	MetaFieldDefinedPhases,
+	MetaFieldDescription,
+	MetaFieldFeatures,
+	MetaFieldHomepage,
+	MetaFieldInherited,
+	MetaFieldNeeded,
+	MetaFieldNeededElf2,
+	MetaFieldPkgUse,
+	MetaFieldRestrict,
+	MetaFieldRequiredUse,
+	MetaFieldSlot,
+	MetaFieldEapi,
+	MetaFieldCFlags,
+	MetaFieldCxxFlags,
+	MetaFieldLdFlags,
+	MetaFieldCHost,
+	MetaFieldLicense,
+	MetaFieldRepository,
+	MetaFieldRequires,
+	MetaFieldKeywords,
+	MetaFieldProvides,
+	MetaFieldSize,
+	MetaFieldIUse,
+	MetaFieldIUseEffective,
+	MetaFieldUse,
+	MetaFieldEbuild,
+	MetaFieldContents,
+}
+
+// The USE flags of the package are elaborated only when these fields
+// are loaded.
+var UsePortageMetaFields = []PortageMetaField{
+	MetaFieldSlot,
+	MetaFieldIUse,
+	MetaFieldIUseEffective,
+	MetaFieldUse,
+}
+
+// metaFileReader returns the content of a metadata file of the
+// package or an empty string if the file is not available.
+type metaFileReader func(name string) (string, error)
+
+func newDirMetaFileReader(metaDir string) metaFileReader {
+	return func(name string) (string, error) {
+		return parseMetaFile(filepath.Join(metaDir, name), false)
+	}
+}
+
+type portageMetaFieldLoader func(m *PortageMetaData, read metaFileReader) error
+
+func stringFieldLoader(file string, field func(m *PortageMetaData) *string) portageMetaFieldLoader {
+	return func(m *PortageMetaData, read metaFileReader) error {
+		value, err := read(file)
+		*field(m) = strings.TrimRight(value, "\n")
+		return err
+	}
+}
+
+func listFieldLoader(file string, field func(m *PortageMetaData) *[]string) portageMetaFieldLoader {
+	return func(m *PortageMetaData, read metaFileReader) error {
+		value, err := read(file)
+		if err != nil {
+			return err
+		}
+		value = strings.TrimRight(value, "\n")
+		if value != "" {
+			*field(m) = strings.Split(value, " ")
+		}
+		return nil
+	}
+}
+
+var portageMetaFieldLoaders = map[PortageMetaField]portageMetaFieldLoader{
+	MetaFieldBdepend: stringFieldLoader("BDEPEND",
+		func(m *PortageMetaData) *string { return &m.BDEPEND }),
+	MetaFieldRdepend: stringFieldLoader("RDEPEND",
+		func(m *PortageMetaData) *string { return &m.RDEPEND }),
+	MetaFieldDepend: stringFieldLoader("DEPEND",
+		func(m *PortageMetaData) *string { return &m.DEPEND }),
+	MetaFieldPdepend: stringFieldLoader("PDEPEND",
+		func(m *PortageMetaData) *string { return &m.PDEPEND }),
+	MetaFieldBuildTime: stringFieldLoader("BUILD_TIME",
+		func(m *PortageMetaData) *string { return &m.BUILD_TIME }),
+	MetaFieldCbuild: stringFieldLoader("CBUILD",
+		func(m *PortageMetaData) *string { return &m.CBUILD }),
+	MetaFieldCounter: stringFieldLoader("COUNTER",
+		func(m *PortageMetaData) *string { return &m.COUNTER }),
+	MetaFieldDefinedPhases: stringFieldLoader("DEFINED_PHASES",
+		func(m *PortageMetaData) *string { return &m.DEFINED_PHASES }),
+	MetaFieldDescription: stringFieldLoader("DESCRIPTION",
+		func(m *PortageMetaData) *string { return &m.DESCRIPTION }),
+	MetaFieldFeatures: stringFieldLoader("FEATURES",
+		func(m *PortageMetaData) *string { return &m.FEATURES }),
+	MetaFieldHomepage: stringFieldLoader("HOMEPAGE",
+		func(m *PortageMetaData) *string { return &m.HOMEPAGE }),
+	MetaFieldInherited: stringFieldLoader("INHERITED",
+		func(m *PortageMetaData) *string { return &m.INHERITED }),
+	MetaFieldNeeded: stringFieldLoader("NEEDED",
+		func(m *PortageMetaData) *string { return &m.NEEDED }),
+	MetaFieldNeededElf2: stringFieldLoader("NEEDED.ELF.2",
+		func(m *PortageMetaData) *string { return &m.NEEDED_ELF2 }),
+	MetaFieldPkgUse: stringFieldLoader("PKGUSE",
+		func(m *PortageMetaData) *string { return &m.PKGUSE }),
+	MetaFieldRestrict: stringFieldLoader("RESTRICT",
+		func(m *PortageMetaData) *string { return &m.RESTRICT }),
+	MetaFieldRequiredUse: stringFieldLoader("REQUIRED_USE",
+		func(m *PortageMetaData) *string { return &m.REQUIRED_USE }),
+	MetaFieldSlot: func(m *PortageMetaData, read metaFileReader) error {
+		slot, err := read("SLOT")
+		if err != nil {
+			return err
+		}
+		m.GentooPackage.SetSlotStr(strings.TrimRight(slot, "\n"))
+		return nil
+	},
+	MetaFieldEapi: stringFieldLoader("EAPI",
+		func(m *PortageMetaData) *string { return &m.Eapi }),
+	MetaFieldCFlags: stringFieldLoader("CFLAGS",
+		func(m *PortageMetaData) *string { return &m.CFlags }),
+	MetaFieldCxxFlags: stringFieldLoader("CXXFLAGS",
+		func(m *PortageMetaData) *string { return &m.CxxFlags }),
+	MetaFieldLdFlags: stringFieldLoader("LDFLAGS",
+		func(m *PortageMetaData) *string { return &m.LdFlags }),
+	MetaFieldCHost: stringFieldLoader("CHOST",
+		func(m *PortageMetaData) *string { return &m.CHost }),
+	MetaFieldLicense: stringFieldLoader("LICENSE",
+		func(m *PortageMetaData) *string { return &m.GentooPackage.License }),
+	MetaFieldRepository: stringFieldLoader("repository",
+		func(m *PortageMetaData) *string { return &m.GentooPackage.Repository }),
+	MetaFieldRequires: stringFieldLoader("REQUIRES",
+		func(m *PortageMetaData) *string { return &m.REQUIRES }),
+	MetaFieldKeywords: stringFieldLoader("KEYWORDS",
+		func(m *PortageMetaData) *string { return &m.KEYWORDS }),
+	MetaFieldProvides: stringFieldLoader("PROVIDES",
+		func(m *PortageMetaData) *string { return &m.PROVIDES }),
+	MetaFieldSize: stringFieldLoader("SIZE",
+		func(m *PortageMetaData) *string { return &m.SIZE }),
+	MetaFieldIUse: listFieldLoader("IUSE",
+		func(m *PortageMetaData) *[]string { return &m.IUse }),
+	MetaFieldIUseEffective: listFieldLoader("IUSE_EFFECTIVE",
+		func(m *PortageMetaData) *[]string { return &m.IUseEffective }),
+	MetaFieldUse: listFieldLoader("USE",
+		func(m *PortageMetaData) *[]string { return &m.Use }),
+	MetaFieldEbuild: func(m *PortageMetaData, read metaFileReader) error {
+		ebuild, err := read(m.GentooPackage.GetPF() + ".ebuild")
+		m.Ebuild = strings.TrimRight(ebuild, "\n")
+		return err
+	},
+	MetaFieldContents: func(m *PortageMetaData, read metaFileReader) error {
+		contents, err := read("CONTENTS")
+		if err != nil {
+			return err
+		}
+		m.CONTENTS = ParseCONTENTS(contents)
+		return nil
+	},
+}
+
+// VdbScanner walks a vdb directory (/var/db/pkg) and loads the
+// metadata of the packages with a pool of workers.
+type VdbScanner struct {
+	Dir         string
+	Opts        *PortageUseParseOpts
+	Fields      []PortageMetaField
+	Concurrency int
+}
+
+type vdbScannerJob struct {
+	seq int
+	dir string
+}
+
+type vdbScannerResp struct {
+	seq int
+	dir string
+	pm  *PortageMetaData
+	err error
+}
+
+func NewVdbScanner(dir string, opts *PortageUseParseOpts) *VdbScanner {
+	if opts == nil {
+		opts = &PortageUseParseOpts{}
+	}
+	return &VdbScanner{
+		Dir:         dir,
+		Opts:        opts,
+		Fields:      []PortageMetaField{},
+		Concurrency: 1,
+	}
+}
+
+// SetFields defines the metadata files to load. Without fields all
+// the metadata files are loaded.
+func (s *VdbScanner) SetFields(fields ...PortageMetaField) error {
+	for _, f := range fields {
+		if _, ok := portageMetaFieldLoaders[f]; !ok {
+			return errors.New("Invalid metadata field " + string(f))
+		}
+	}
+	s.Fields = fields
+	return nil
+}
+
+// WithFields returns a copy of the scanner that loads only the metadata
+// files of the fields. The scanner is not modified.
+func (s *VdbScanner) WithFields(fields ...PortageMetaField) (*VdbScanner, error) {
+	ans := *s
+	err := ans.SetFields(fields...)
+	if err != nil {
+		return nil, err
+	}
+	return &ans, nil
+}
+
+func (s *VdbScanner) getFields() []PortageMetaField {
+	if len(s.Fields) == 0 || len(s.Opts.Packages) == 0 {
+		return s.Fields
+	}
+
+	// The package filters need the slot and the USE flags.
+	ans := append([]PortageMetaField{}, s.Fields...)
+	for _, f := range UsePortageMetaFields {
+		present := false
+		for _, f2 := range s.Fields {
+			if f == f2 {
+				present = true
+				break
+			}
+		}
+		if !present {
+			ans = append(ans, f)
+		}
+	}
+	return ans
+}
+
+// isDirAdmit checks the category and the name of the package
+// directory before loading the metadata.
+func (s *VdbScanner) isDirAdmit(cat, pf string) bool {
+	if len(s.Opts.Packages) == 0 {
+		return true
+	}
+
+	gp, err := ParsePackageStr(cat + "/" + pf)
+	if err != nil {
+		// Let the parser return the error.
+		return true
+	}
+
+	for _, f := range s.Opts.Packages {
+		gpF, err := ParsePackageStr(f)
+		if err != nil || (gpF.Category == gp.Category && gpF.Name == gp.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *VdbScanner) parse(dir string) (*PortageMetaData, error) {
+	pm, err := ParsePackageMetadataDirFields(dir, s.Opts, s.getFields())
+	if err != nil {
+		return nil, errors.New(
+			fmt.Sprintf("Error on parse directory %s: %s", dir, err.Error()))
+	}
+	if !s.Opts.IsGentooPkgAdmit(pm.GentooPackage) {
+		return nil, nil
+	}
+	return pm, nil
+}
+
+// produce sends the directories of the packages to the jobs channel
+// until the stop channel is closed.
+func (s *VdbScanner) produce(jobs chan<- vdbScannerJob, stop <-chan bool) error {
+	defer close(jobs)
+
+	cats, err := ioutil.ReadDir(s.Dir)
+	if err != nil {
+		return err
+	}
+
+	seq := 0
+	for _, cat := range cats {
+		if !cat.IsDir() || isVdbTempDir(cat.Name()) || !s.Opts.IsCatAdmit(cat.Name()) {
+			continue
+		}
+
+		catDir := filepath.Join(s.Dir, cat.Name())
+		files, err := ioutil.ReadDir(catDir)
+		if err != nil {
+			return errors.New(
+				fmt.Sprintf("Error on parse directory %s: %s",
+					cat.Name(), err.Error()))
+		}
+
+		for _, file := range files {
+			if !file.IsDir() || isVdbTempDir(file.Name()) ||
+				!s.isDirAdmit(cat.Name(), file.Name()) {
+				continue
+			}
+
+			select {
+			case jobs <- vdbScannerJob{seq: seq, dir: filepath.Join(catDir, file.Name())}:
+				seq++
+			case <-stop:
+				return nil
+			}
+		}
+	}
+
+	return nil
+}
+
+// Walk calls the callback for every package admitted by the filters.
+// The callback is never called concurrently but with more workers the
+// packages are not sorted. The walk stops at the first error.
+func (s *VdbScanner) Walk(f func(pm *PortageMetaData) error) error {
+	return s.walk(func(seq int, dir string, pm *PortageMetaData) error {
+		return f(pm)
+	})
+}
+
+// WalkEntries is like Walk but the callback receives also the
+// directory of the vdb entry of the package.
+func (s *VdbScanner) WalkEntries(f func(dir string, pm *PortageMetaData) error) error {
+	return s.walk(func(seq int, dir string, pm *PortageMetaData) error {
+		return f(dir, pm)
+	})
+}
+
+func (s *VdbScanner) walk(f func(seq int, dir string, pm *PortageMetaData) error) error {
+	workers := s.Concurrency
+	if workers < 1 {
+		workers = 1
+	}
+
+	jobs := make(chan vdbScannerJob, workers)
+	resps := make(chan vdbScannerResp, workers)
+	stop := make(chan bool)
+	prodDone := make(chan bool)
+	var prodErr error
+
+	go func() {
+		prodErr = s.produce(jobs, stop)
+		close(prodDone)
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				pm, err := s.parse(job.dir)
+				select {
+				case resps <- vdbScannerResp{seq: job.seq, dir: job.dir, pm: pm, err: err}:
+				case <-stop:
+					return
+				}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(resps)
+	}()
+
+	var err error
+	for resp := range resps {
+		if resp.err == nil && resp.pm != nil {
+			resp.err = f(resp.seq, resp.dir, resp.pm)
+		}
+		if resp.err != nil {
+			err = resp.err
+			close(stop)
+			break
+		}
+	}
+
+	// Drain the workers before return.
+	for range resps {
+	}
+	<-prodDone
+
+	if err != nil {
+		return err
+	}
+	return prodErr
+}
+
+// Scan returns the packages admitted by the filters sorted by
+// the directory.
+func (s *VdbScanner) Scan() ([]*PortageMetaData, error) {
+	type scanned struct {
+		seq int
+		pm  *PortageMetaData
+	}
+	list := []scanned{}
+
+	err := s.walk(func(seq int, dir string, pm *PortageMetaData) error {
+		list = append(list, scanned{seq: seq, pm: pm})
+		return nil
+	})
+	if err != nil {
+		return []*PortageMetaData{}, err
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].seq < list[j].seq
+	})
+
+	ans := make([]*PortageMetaData, 0, len(list))
+	for _, e := range list {
+		ans = append(ans, e.pm)
+	}
+	return ans, nil
+}
@@ -0,0 +1,157 @@
+/*
+
+Copyright (C) 2017-2021  Daniele Rondina <geaaru@sabayonlinux.org>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+*/
+
+
+package gentoo_test
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/Sabayon/pkgs-checker/pkg/gentoo"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func writeVdbPackage(dir, pkg string, files map[string]string) {
+	pkgdir := filepath.Join(dir, pkg)
+	Expect(os.MkdirAll(pkgdir, 0755)).Should(BeNil())
+	for f, content := range files {
+		Expect(ioutil.WriteFile(filepath.Join(pkgdir, f),
+			[]byte(content+"\n"), 0644)).Should(BeNil())
+	}
+}
+
+var _ = Describe("Gentoo VdbScanner", func() {
+
+	var tmpdir string
+
+	BeforeEach(func() {
+		var err error
+		tmpdir, err = ioutil.TempDir("", "vdb-scanner")
+		Expect(err).Should(BeNil())
+
+		for i := 0; i < 20; i++ {
+			writeVdbPackage(tmpdir, fmt.Sprintf("app-misc/foo%02d-1.%d", i, i), map[string]string{
+				"SLOT":           "0",
+				"IUSE_EFFECTIVE": "ssl gnutls",
+				"USE":            "ssl",
+				"KEYWORDS":       "amd64 ~arm",
+				"CONTENTS":       "obj /usr/bin/foo 5a4c5d1b9a8d7b0b7b4b0e1e8b0c5d55 1600000000",
+			})
+		}
+		writeVdbPackage(tmpdir, "dev-lang/python-3.9.1", map[string]string{
+			"SLOT":     "3.9/3.9",
+			"KEYWORDS": "amd64",
+		})
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tmpdir)
+	})
+
+	It("Check scan with all fields", func() {
+		pkgs, err := NewVdbScanner(tmpdir, nil).Scan()
+		Expect(err).Should(BeNil())
+		Expect(len(pkgs)).Should(Equal(21))
+		Expect(pkgs[0].GetPackageName()).Should(Equal("app-misc/foo00"))
+		Expect(len(pkgs[0].CONTENTS)).Should(Equal(1))
+		Expect(pkgs[0].UseFlags).Should(Equal([]string{"ssl", "-gnutls"}))
+		Expect(pkgs[20].Slot).Should(Equal("3.9"))
+		Expect(pkgs[20].SubSlot).Should(Equal("3.9"))
+	})
+
+	It("Check scan with fields", func() {
+		s := NewVdbScanner(tmpdir, nil)
+		Expect(s.SetFields(MetaFieldKeywords)).Should(BeNil())
+		pkgs, err := s.Scan()
+		Expect(err).Should(BeNil())
+		Expect(len(pkgs)).Should(Equal(21))
+		Expect(pkgs[0].KEYWORDS).Should(Equal("amd64 ~arm"))
+		Expect(len(pkgs[0].CONTENTS)).Should(Equal(0))
+		Expect(len(pkgs[0].UseFlags)).Should(Equal(0))
+	})
+
+	It("Check invalid field", func() {
+		s := NewVdbScanner(tmpdir, nil)
+		Expect(s.SetFields("INVALID")).ShouldNot(BeNil())
+	})
+
+	It("Check concurrent scan is sorted", func() {
+		s := NewVdbScanner(tmpdir, nil)
+		s.Concurrency = 4
+		pkgs, err := s.Scan()
+		Expect(err).Should(BeNil())
+		Expect(len(pkgs)).Should(Equal(21))
+		for i := 0; i < 20; i++ {
+			Expect(pkgs[i].GetPackageName()).Should(Equal(fmt.Sprintf("app-misc/foo%02d", i)))
+		}
+	})
+
+	It("Check concurrent walk", func() {
+		s := NewVdbScanner(tmpdir, nil)
+		s.Concurrency = 4
+		s.SetFields(UsePortageMetaFields...)
+		n := 0
+		err := s.Walk(func(pm *PortageMetaData) error {
+			n++
+			return nil
+		})
+		Expect(err).Should(BeNil())
+		Expect(n).Should(Equal(21))
+	})
+
+	It("Check walk stops on error", func() {
+		s := NewVdbScanner(tmpdir, nil)
+		s.Concurrency = 4
+		n := 0
+		err := s.Walk(func(pm *PortageMetaData) error {
+			n++
+			if n == 3 {
+				return errors.New("stop")
+			}
+			return nil
+		})
+		Expect(err).ShouldNot(BeNil())
+		Expect(n).Should(Equal(3))
+	})
+
+	It("Check walk with package filters", func() {
+		opts := &PortageUseParseOpts{
+			Packages: []string{"app-misc/foo01", "dev-lang/python:3.9", "app-misc/foo02[gnutls]"},
+		}
+		s := NewVdbScanner(tmpdir, opts)
+		s.SetFields(MetaFieldKeywords)
+		pkgs, err := s.Scan()
+		Expect(err).Should(BeNil())
+		Expect(len(pkgs)).Should(Equal(2))
+		Expect(pkgs[0].GetPackageName()).Should(Equal("app-misc/foo01"))
+		Expect(pkgs[1].GetPackageName()).Should(Equal("dev-lang/python"))
+	})
+
+	It("Check missing directory", func() {
+		_, err := NewVdbScanner(filepath.Join(tmpdir, "missing"), nil).Scan()
+		Expect(err).ShouldNot(BeNil())
+	})
+
+})
@@ -0,0 +1,107 @@
+/*
+
+Copyright (C) 2017-2021  Daniele Rondina <geaaru@sabayonlinux.org>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+*/
+
+
+package gentoo_test
+
+import (
+	. "github.com/Sabayon/pkgs-checker/pkg/gentoo"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Gentoo Slots", func() {
+
+	DescribeTable("Parse slot",
+		func(atom, slot, subslot, op string) {
+			gp, err := ParsePackageStr(atom)
+			Expect(err).Should(BeNil())
+			Expect(gp.Slot).Should(Equal(slot))
+			Expect(gp.SubSlot).Should(Equal(subslot))
+			Expect(gp.SlotOperator).Should(Equal(op))
+			Expect(gp.String()).Should(Equal(atom[len(gp.Condition.String()):]))
+		},
+		Entry("slot", "dev-lang/python:3.9", "3.9", "", ""),
+		Entry("slot and subslot", "dev-lang/python:3.9/3.9m", "3.9", "3.9m", ""),
+		Entry("any slot", "dev-libs/openssl:*", "", "", SlotOperatorAny),
+		Entry("rebuild on any slot", "dev-libs/openssl:=", "", "", SlotOperatorEqual),
+		Entry("rebuild on slot", "dev-libs/openssl:0=", "0", "", SlotOperatorEqual),
+		Entry("rebuild on subslot", "dev-libs/openssl:0/1.1=", "0", "1.1", SlotOperatorEqual),
+		Entry("with version", ">=dev-libs/openssl-1.1.1k:0/1.1=", "0", "1.1", SlotOperatorEqual),
+	)
+
+	Context("Parse strong blocker with slot", func() {
+		gp, err := ParsePackageStr("!!<dev-libs/openssl-1.1:0/1.1")
+
+		It("Check fields", func() {
+			Expect(err).Should(BeNil())
+			Expect(gp.StrongBlocker).Should(BeTrue())
+			Expect(gp.Condition.String()).Should(Equal("!<"))
+			Expect(gp.Version).Should(Equal("1.1"))
+			Expect(gp.Slot).Should(Equal("0"))
+			Expect(gp.SubSlot).Should(Equal("1.1"))
+			Expect(gp.Format()).Should(Equal("!!<dev-libs/openssl-1.1:0/1.1"))
+		})
+	})
+
+	Context("Parse glob with slot", func() {
+		gp, err := ParsePackageStr("=dev-lang/python-3.9*:3.9/3.9=")
+
+		It("Check fields", func() {
+			Expect(err).Should(BeNil())
+			Expect(gp.Condition).Should(Equal(PackageCond(PkgCondMatchVersion)))
+			Expect(gp.Version).Should(Equal("3.9"))
+			Expect(gp.GetSlotStr()).Should(Equal("3.9/3.9="))
+			Expect(gp.GetPackageNameWithSlot()).Should(Equal("dev-lang/python:3.9"))
+		})
+	})
+
+	DescribeTable("Admit with slot",
+		func(atom, pkg string, expected bool) {
+			gpA, err := ParsePackageStr(atom)
+			Expect(err).Should(BeNil())
+			gpB, err := ParsePackageStr(pkg)
+			Expect(err).Should(BeNil())
+			ans, err := gpA.Admit(gpB)
+			Expect(err).Should(BeNil())
+			Expect(ans).Should(Equal(expected))
+		},
+		Entry("same slot", "dev-lang/python:3.9", "dev-lang/python-3.9.5:3.9/3.9", true),
+		Entry("different slot", "dev-lang/python:3.9", "dev-lang/python-3.8.10:3.8/3.8", false),
+		Entry("same subslot", "dev-lang/python:3.9/3.9", "dev-lang/python-3.9.5:3.9/3.9", true),
+		Entry("different subslot", "dev-libs/openssl:0/1.1", "dev-libs/openssl-3.0.0:0/3", false),
+		Entry("any slot", "dev-lang/python:*", "dev-lang/python-3.8.10:3.8/3.8", true),
+		Entry("rebuild on any slot", ">=dev-lang/python-3.8:=", "dev-lang/python-3.9.5:3.9/3.9", true),
+		Entry("rebuild on slot", ">=dev-lang/python-3.8:3.9=", "dev-lang/python-3.8.10:3.8/3.8", false),
+	)
+
+	Context("Filter packages with subslot", func() {
+		opts := &PortageUseParseOpts{
+			Packages: []string{"dev-lang/python:3.9"},
+		}
+
+		It("Check IsPkgAdmit", func() {
+			Expect(opts.IsPkgAdmit("dev-lang/python:3.9/3.9")).Should(BeTrue())
+			Expect(opts.IsPkgAdmit("dev-lang/python:3.8/3.8")).Should(BeFalse())
+		})
+	})
+
+})
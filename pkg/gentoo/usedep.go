@@ -0,0 +1,177 @@
+/*
+
+Copyright (C) 2017-2021  Daniele Rondina <geaaru@sabayonlinux.org>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package gentoo
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// USE dependencies of the atoms as described by PMS section 8.3.4:
+// https://projects.gentoo.org/pms/latest/pms.html#x1-820008.3.4
+
+const (
+	// foo? and !foo?
+	UseDepConditional = "?"
+	// foo= and !foo=
+	UseDepEqual = "="
+
+	// foo(+)
+	UseDepDefaultEnabled = "+"
+	// foo(-)
+	UseDepDefaultDisabled = "-"
+)
+
+var regexUseDep = regexp.MustCompile(
+	`^([!-]?)([A-Za-z0-9][A-Za-z0-9+_@-]*)(?:\(([+-])\))?([?=]?)$`,
+)
+
+type GentooUseDep struct {
+	Flag string `json:"flag"`
+	// Valorized for -foo, !foo? and !foo=
+	Negated     bool   `json:"negated,omitempty"`
+	Default     string `json:"default,omitempty"`
+	Conditional string `json:"conditional,omitempty"`
+}
+
+// ParseUseDep parses a single USE dependency of an atom
+// (ex. foo, -foo, foo(+), foo?, !foo?, foo=, !foo=).
+func ParseUseDep(dep string) (*GentooUseDep, error) {
+	matches := regexUseDep.FindStringSubmatch(dep)
+	if matches == nil {
+		return nil, errors.New(fmt.Sprintf("Invalid USE dependency %s", dep))
+	}
+
+	ans := &GentooUseDep{
+		Flag:        matches[2],
+		Negated:     matches[1] != "",
+		Default:     matches[3],
+		Conditional: matches[4],
+	}
+
+	// The ! prefix is valid only with the conditionals and the -
+	// prefix only without.
+	if (matches[1] == "!" && ans.Conditional == "") ||
+		(matches[1] == "-" && ans.Conditional != "") {
+		return nil, errors.New(fmt.Sprintf("Invalid USE dependency %s", dep))
+	}
+
+	return ans, nil
+}
+
+func (u *GentooUseDep) String() string {
+	ans := u.Flag
+	if u.Negated {
+		if u.Conditional == "" {
+			ans = "-" + ans
+		} else {
+			ans = "!" + ans
+		}
+	}
+	if u.Default != "" {
+		ans += "(" + u.Default + ")"
+	}
+	return ans + u.Conditional
+}
+
+// IsConditional returns true if the requirement depends on the USE
+// flags of the package that defines the atom.
+func (u *GentooUseDep) IsConditional() bool {
+	return u.Conditional != ""
+}
+
+// Check verifies the USE dependency against the USE flags of the
+// candidate package. The uses of the parent package are used to
+// evaluate the conditionals. The disabled flags are supplied with
+// the - prefix and a flag not present in the candidate list uses the
+// default of the dependency or it's handled as disabled.
+func (u *GentooUseDep) Check(uses, pkgUses []string) bool {
+	return u.check(newUseSet(uses), newUseSet(pkgUses))
+}
+
+func (u *GentooUseDep) check(parent, pkg map[string]bool) bool {
+	enabled, ok := pkg[u.Flag]
+	if !ok {
+		enabled = u.Default == UseDepDefaultEnabled
+	}
+	parentEnabled := parent[u.Flag]
+
+	switch u.Conditional {
+	case UseDepConditional:
+		if u.Negated {
+			return parentEnabled || !enabled
+		}
+		return !parentEnabled || enabled
+	case UseDepEqual:
+		if u.Negated {
+			return enabled != parentEnabled
+		}
+		return enabled == parentEnabled
+	}
+
+	return enabled != u.Negated
+}
+
+// GetUseDeps returns the parsed USE dependencies of the atom.
+func (p *GentooPackage) GetUseDeps() ([]*GentooUseDep, error) {
+	ans := []*GentooUseDep{}
+	for _, u := range p.UseFlags {
+		u = strings.TrimSpace(u)
+		if u == "" {
+			continue
+		}
+		dep, err := ParseUseDep(u)
+		if err != nil {
+			return nil, err
+		}
+		ans = append(ans, dep)
+	}
+	return ans, nil
+}
+
+// AdmitWithUse checks if the package i satisfies the atom p including
+// the USE dependencies. The uses are the USE flags of the package that
+// defines the atom and they are used to evaluate the conditionals
+// (foo?, !foo?, foo=, !foo=).
+func (p *GentooPackage) AdmitWithUse(i *GentooPackage, uses []string) (bool, error) {
+	ans, err := p.admitAtom(i)
+	if err != nil || !ans {
+		return ans, err
+	}
+	return p.admitUse(i, uses)
+}
+
+func (p *GentooPackage) admitUse(i *GentooPackage, uses []string) (bool, error) {
+	deps, err := p.GetUseDeps()
+	if err != nil {
+		return false, err
+	}
+
+	parent := newUseSet(uses)
+	pkg := newUseSet(i.UseFlags)
+	for _, d := range deps {
+		if !d.check(parent, pkg) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
@@ -0,0 +1,144 @@
+/*
+
+Copyright (C) 2017-2021  Daniele Rondina <geaaru@sabayonlinux.org>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+*/
+
+
+package gentoo_test
+
+import (
+	. "github.com/Sabayon/pkgs-checker/pkg/gentoo"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Gentoo USE dependencies", func() {
+
+	DescribeTable("ParseUseDep",
+		func(dep string, expected GentooUseDep) {
+			u, err := ParseUseDep(dep)
+			Expect(err).Should(BeNil())
+			Expect(*u).Should(Equal(expected))
+			Expect(u.String()).Should(Equal(dep))
+		},
+		Entry("enabled", "foo", GentooUseDep{Flag: "foo"}),
+		Entry("disabled", "-foo", GentooUseDep{Flag: "foo", Negated: true}),
+		Entry("default enabled", "foo(+)", GentooUseDep{Flag: "foo", Default: "+"}),
+		Entry("default disabled", "-foo(-)", GentooUseDep{Flag: "foo", Negated: true, Default: "-"}),
+		Entry("conditional", "foo?", GentooUseDep{Flag: "foo", Conditional: "?"}),
+		Entry("negated conditional", "!foo?", GentooUseDep{Flag: "foo", Negated: true, Conditional: "?"}),
+		Entry("equal", "foo=", GentooUseDep{Flag: "foo", Conditional: "="}),
+		Entry("negated equal", "!foo=", GentooUseDep{Flag: "foo", Negated: true, Conditional: "="}),
+		Entry("python target", "python_targets_python3_8(-)?",
+			GentooUseDep{Flag: "python_targets_python3_8", Default: "-", Conditional: "?"}),
+	)
+
+	DescribeTable("Invalid USE dependencies",
+		func(dep string) {
+			_, err := ParseUseDep(dep)
+			Expect(err).ShouldNot(BeNil())
+		},
+		Entry("empty", ""),
+		Entry("negated without conditional", "!foo"),
+		Entry("disabled with conditional", "-foo?"),
+		Entry("invalid default", "foo(x)"),
+		Entry("invalid char", "fo$o"),
+	)
+
+	DescribeTable("Check",
+		func(dep string, uses, pkgUses []string, expected bool) {
+			u, err := ParseUseDep(dep)
+			Expect(err).Should(BeNil())
+			Expect(u.Check(uses, pkgUses)).Should(Equal(expected))
+		},
+		Entry("foo enabled", "foo", nil, []string{"foo"}, true),
+		Entry("foo disabled", "foo", nil, []string{"-foo"}, false),
+		Entry("foo missing", "foo", nil, []string{}, false),
+		Entry("-foo disabled", "-foo", nil, []string{"-foo"}, true),
+		Entry("-foo missing", "-foo", nil, []string{}, true),
+		Entry("foo(+) missing", "foo(+)", nil, []string{}, true),
+		Entry("foo(+) disabled", "foo(+)", nil, []string{"-foo"}, false),
+		Entry("-foo(+) missing", "-foo(+)", nil, []string{}, false),
+		Entry("foo(-) missing", "foo(-)", nil, []string{}, false),
+		Entry("foo? parent enabled", "foo?", []string{"foo"}, []string{"-foo"}, false),
+		Entry("foo? parent disabled", "foo?", []string{"-foo"}, []string{"-foo"}, true),
+		Entry("!foo? parent disabled", "!foo?", []string{}, []string{"foo"}, false),
+		Entry("!foo? parent enabled", "!foo?", []string{"foo"}, []string{"foo"}, true),
+		Entry("foo= same", "foo=", []string{"foo"}, []string{"foo"}, true),
+		Entry("foo= different", "foo=", []string{}, []string{"foo"}, false),
+		Entry("!foo= different", "!foo=", []string{}, []string{"foo"}, true),
+		Entry("!foo= same", "!foo=", []string{"-foo"}, []string{"-foo"}, false),
+	)
+
+	DescribeTable("AdmitWithUse",
+		func(atom, pkg string, uses []string, expected bool) {
+			gpA, err := ParsePackageStr(atom)
+			Expect(err).Should(BeNil())
+			gpB, err := ParsePackageStr(pkg)
+			Expect(err).Should(BeNil())
+			ans, err := gpA.AdmitWithUse(gpB, uses)
+			Expect(err).Should(BeNil())
+			Expect(ans).Should(Equal(expected))
+		},
+		Entry("with vulkan", "media-libs/mesa[vulkan]", "media-libs/mesa-21.1.2[vulkan,-llvm]", nil, true),
+		Entry("without vulkan", "media-libs/mesa[vulkan]", "media-libs/mesa-21.1.2[-vulkan,llvm]", nil, false),
+		Entry("without use flags", "media-libs/mesa[vulkan]", "media-libs/mesa-21.1.2", nil, false),
+		Entry("version mismatch", ">=media-libs/mesa-21.2[vulkan]", "media-libs/mesa-21.1.2[vulkan]", nil, false),
+		Entry("conditional", "media-libs/mesa[abi_x86_32(-)?,llvm]",
+			"media-libs/mesa-21.1.2[llvm]", []string{"abi_x86_32"}, false),
+		Entry("conditional with default", "media-libs/mesa[abi_x86_32(+)?,llvm]",
+			"media-libs/mesa-21.1.2[llvm]", []string{"abi_x86_32"}, true),
+	)
+
+	Context("Admit", func() {
+		gpA, _ := ParsePackageStr("media-libs/mesa[vulkan]")
+
+		It("Check package without USE flags", func() {
+			gpB, _ := ParsePackageStr("media-libs/mesa-21.1.2")
+			Expect(gpA.Admit(gpB)).Should(BeTrue())
+		})
+
+		It("Check package with USE flags", func() {
+			gpB, _ := ParsePackageStr("media-libs/mesa-21.1.2[-vulkan]")
+			Expect(gpA.Admit(gpB)).Should(BeFalse())
+		})
+
+		It("Check invalid USE dependency", func() {
+			gpC, _ := ParsePackageStr("media-libs/mesa[!vulkan]")
+			gpB, _ := ParsePackageStr("media-libs/mesa-21.1.2[vulkan]")
+			_, err := gpC.Admit(gpB)
+			Expect(err).ShouldNot(BeNil())
+		})
+	})
+
+	Context("Filter packages with USE flags", func() {
+		opts := &PortageUseParseOpts{
+			Packages: []string{"media-libs/mesa[vulkan]"},
+		}
+
+		It("Check IsGentooPkgAdmit", func() {
+			gp, _ := ParsePackageStr("media-libs/mesa-21.1.2")
+			gp.UseFlags = []string{"vulkan", "-llvm"}
+			Expect(opts.IsGentooPkgAdmit(gp)).Should(BeTrue())
+			gp.UseFlags = []string{"-vulkan", "llvm"}
+			Expect(opts.IsGentooPkgAdmit(gp)).Should(BeFalse())
+		})
+	})
+
+})
@@ -41,6 +41,7 @@ type PortageMetaData struct {
 	BDEPEND        string   `json:"bdepend,omitempty"`
 	RDEPEND        string   `json:"rdepend,omitempty"`
 	DEPEND         string   `json:"depend,omitempty"`
+	PDEPEND        string   `json:"pdepend,omitempty"`
 	REQUIRES       string   `json:"requires,omitempty"`
 	KEYWORDS       string   `json:"keywords,omitempty"`
 	PROVIDES       string   `json:"provides,omitempty"`
@@ -57,6 +58,7 @@ type PortageMetaData struct {
 	NEEDED_ELF2    string   `json:"needed_elf2,omitempty"`
 	PKGUSE         string   `json:"pkguse,omitempty"`
 	RESTRICT       string   `json:"restrict,omitempty"`
+	REQUIRED_USE   string   `json:"required_use,omitempty"`
 
 	Ebuild string `json:"ebuild,omitempty"`
 
@@ -90,6 +92,7 @@ func NewPortageMetaData(pkg *GentooPackage) *PortageMetaData {
 		BDEPEND:        "",
 		RDEPEND:        "",
 		DEPEND:         "",
+		PDEPEND:        "",
 		BUILD_TIME:     "",
 		CBUILD:         "",
 		COUNTER:        "",
@@ -102,6 +105,7 @@ func NewPortageMetaData(pkg *GentooPackage) *PortageMetaData {
 		NEEDED_ELF2:    "",
 		PKGUSE:         "",
 		RESTRICT:       "",
+		REQUIRED_USE:   "",
 		REQUIRES:       "",
 		SIZE:           "",
 		CONTENTS:       make([]PortageContentElem, 0),
@@ -141,25 +145,27 @@ func (o *PortageUseParseOpts) IsCatAdmit(cat string) bool {
 }
 
 func (o *PortageUseParseOpts) IsPkgAdmit(pkg string) bool {
+	if len(o.Packages) == 0 {
+		return true
+	}
+
+	gp, err := ParsePackageStr(pkg)
+	if err != nil {
+		return false
+	}
+
+	return o.IsGentooPkgAdmit(gp)
+}
+
+// IsGentooPkgAdmit checks the package filters against the package
+// and its USE flags.
+func (o *PortageUseParseOpts) IsGentooPkgAdmit(gp *GentooPackage) bool {
 	ans := false
+	pkg := gp.String()
 
 	// Prepare regex
 	if len(o.Packages) > 0 {
 
-		gp, err := ParsePackageStr(pkg)
-		if err == nil && gp.Slot == "0" {
-			pkg = gp.GetPackageName() + ":0"
-		}
-
-		// Ignoring sub-slot
-		if strings.Contains(gp.Slot, "/") {
-			gp.Slot = gp.Slot[0:strings.Index(gp.Slot, "/")]
-			if o.Verbose {
-				fmt.Println(
-					fmt.Sprintf("[%s] Force using slot %s", pkg, gp.Slot))
-			}
-		}
-
 		for _, f := range o.Packages {
 			gpF, err := ParsePackageStr(f)
 			if err == nil {
@@ -183,27 +189,7 @@ func (o *PortageUseParseOpts) IsPkgAdmit(pkg string) bool {
 }
 
 func ParseMetadataDir(dir string, opts *PortageUseParseOpts) ([]*PortageMetaData, error) {
-	ans := make([]*PortageMetaData, 0)
-
-	files, err := ioutil.ReadDir(dir)
-	if err != nil {
-		return ans, err
-	}
-
-	for _, file := range files {
-		if file.IsDir() && opts.IsCatAdmit(file.Name()) {
-			pkgs, err := ParseMetadataCatDir(filepath.Join(dir, file.Name()), opts)
-			if err != nil {
-				return ans, errors.New(
-					fmt.Sprintf("Error on parse directory %s: %s",
-						file.Name(), err.Error()))
-			}
-
-			ans = append(ans, pkgs...)
-		}
-	}
-
-	return ans, nil
+	return NewVdbScanner(dir, opts).Scan()
 }
 
 func ParseMetadataCatDir(dir string, opts *PortageUseParseOpts) ([]*PortageMetaData, error) {
@@ -215,7 +201,7 @@ func ParseMetadataCatDir(dir string, opts *PortageUseParseOpts) ([]*PortageMetaD
 	}
 
 	for _, file := range files {
-		if file.IsDir() {
+		if file.IsDir() && !isVdbTempDir(file.Name()) {
 			pm, err := ParsePackageMetadataDir(filepath.Join(dir, file.Name()), opts)
 			if err != nil {
 				return ans, errors.New(
@@ -223,7 +209,7 @@ func ParseMetadataCatDir(dir string, opts *PortageUseParseOpts) ([]*PortageMetaD
 						dir, file.Name(), err.Error()))
 			}
 
-			if opts.IsPkgAdmit(pm.GetPackageNameWithSlot()) {
+			if opts.IsGentooPkgAdmit(pm.GentooPackage) {
 				ans = append(ans, pm)
 			}
 		}
@@ -233,8 +219,18 @@ func ParseMetadataCatDir(dir string, opts *PortageUseParseOpts) ([]*PortageMetaD
 }
 
 func ParsePackageMetadataDir(dir string, opts *PortageUseParseOpts) (*PortageMetaData, error) {
+	return ParsePackageMetadataDirFields(dir, opts, nil)
+}
+
+// ParsePackageMetadataDirFields parses only the metadata files of the
+// fields supplied. With an empty list all the fields are loaded.
+func ParsePackageMetadataDirFields(dir string, opts *PortageUseParseOpts, fields []PortageMetaField) (*PortageMetaData, error) {
 	var ans *PortageMetaData = nil
 
+	if opts == nil {
+		opts = &PortageUseParseOpts{}
+	}
+
 	// Check if the directory is valid
 	fi, err := os.Stat(dir)
 	if err != nil {
@@ -268,210 +264,38 @@ func ParsePackageMetadataDir(dir string, opts *PortageUseParseOpts) (*PortageMet
 
 	ans = NewPortageMetaData(gp)
 
-	ans.BDEPEND, err = parseMetaFile(filepath.Join(metaDir, "BDEPEND"), true)
-	if err != nil {
-		return nil, err
-	}
-
-	ans.RDEPEND, err = parseMetaFile(filepath.Join(metaDir, "RDEPEND"), true)
-	if err != nil {
-		return nil, err
-	}
-
-	ans.DEPEND, err = parseMetaFile(filepath.Join(metaDir, "DEPEND"), true)
-	if err != nil {
-		return nil, err
-	}
-
-	ans.BUILD_TIME, err = parseMetaFile(filepath.Join(metaDir, "BUILD_TIME"), true)
-	if err != nil {
-		return nil, err
-	}
-
-	ans.CBUILD, err = parseMetaFile(filepath.Join(metaDir, "CBUILD"), true)
-	if err != nil {
-		return nil, err
-	}
-
-	ans.COUNTER, err = parseMetaFile(filepath.Join(metaDir, "COUNTER"), true)
-	if err != nil {
-		return nil, err
-	}
-
-	ans.DEFINED_PHASES, err = parseMetaFile(filepath.Join(metaDir, "DEFINED_PHASES"), true)
-	if err != nil {
-		return nil, err
-	}
-
-	ans.DESCRIPTION, err = parseMetaFile(filepath.Join(metaDir, "DESCRIPTION"), true)
-	if err != nil {
-		return nil, err
-	}
-
-	ans.FEATURES, err = parseMetaFile(filepath.Join(metaDir, "FEATURES"), true)
-	if err != nil {
-		return nil, err
-	}
-
-	ans.HOMEPAGE, err = parseMetaFile(filepath.Join(metaDir, "HOMEPAGE"), true)
-	if err != nil {
-		return nil, err
-	}
-
-	ans.INHERITED, err = parseMetaFile(filepath.Join(metaDir, "INHERITED"), true)
-	if err != nil {
-		return nil, err
-	}
-
-	ans.NEEDED, err = parseMetaFile(filepath.Join(metaDir, "NEEDED"), true)
-	if err != nil {
-		return nil, err
-	}
-
-	ans.NEEDED_ELF2, err = parseMetaFile(filepath.Join(metaDir, "NEEDED_ELF2"), true)
-	if err != nil {
-		return nil, err
-	}
-
-	ans.PKGUSE, err = parseMetaFile(filepath.Join(metaDir, "PKGUSE"), true)
-	if err != nil {
-		return nil, err
-	}
-
-	ans.RESTRICT, err = parseMetaFile(filepath.Join(metaDir, "RESTRICT"), true)
-	if err != nil {
-		return nil, err
-	}
-
-	ans.GentooPackage.Slot, err = parseMetaFile(
-		filepath.Join(metaDir, "SLOT"), true,
-	)
-	if err != nil {
-		return nil, err
-	}
-
-	ans.Eapi, err = parseMetaFile(
-		filepath.Join(metaDir, "EAPI"), true,
-	)
-	if err != nil {
-		return nil, err
-	}
-
-	ans.CFlags, err = parseMetaFile(
-		filepath.Join(metaDir, "CFLAGS"), true,
-	)
-	if err != nil {
-		return nil, err
-	}
-
-	ans.CxxFlags, err = parseMetaFile(
-		filepath.Join(metaDir, "CXXFLAGS"), true,
-	)
-	if err != nil {
-		return nil, err
-	}
-
-	ans.LdFlags, err = parseMetaFile(
-		filepath.Join(metaDir, "LDFLAGS"), true,
-	)
+	err = ans.loadFields(newDirMetaFileReader(metaDir), opts, fields)
 	if err != nil {
 		return nil, err
 	}
 
-	ans.CHost, err = parseMetaFile(
-		filepath.Join(metaDir, "CHOST"), true,
-	)
-	if err != nil {
-		return nil, err
-	}
-
-	ans.GentooPackage.License, err = parseMetaFile(
-		filepath.Join(metaDir, "LICENSE"), true,
-	)
-	if err != nil {
-		return nil, err
-	}
-
-	ans.GentooPackage.Repository, err = parseMetaFile(
-		filepath.Join(metaDir, "repository"), true,
-	)
-	if err != nil {
-		return nil, err
-	}
-
-	ans.REQUIRES, err = parseMetaFile(
-		filepath.Join(metaDir, "REQUIRES"), true,
-	)
-	if err != nil {
-		return nil, err
-	}
-
-	ans.KEYWORDS, err = parseMetaFile(
-		filepath.Join(metaDir, "KEYWORDS"), true,
-	)
-	if err != nil {
-		return nil, err
-	}
-
-	ans.PROVIDES, err = parseMetaFile(
-		filepath.Join(metaDir, "PROVIDES"), true,
-	)
-	if err != nil {
-		return nil, err
-	}
-
-	ans.SIZE, err = parseMetaFile(
-		filepath.Join(metaDir, "SIZE"), true,
-	)
-	if err != nil {
-		return nil, err
-	}
-
-	iuse, err := parseMetaFile(
-		filepath.Join(metaDir, "IUSE"), true,
-	)
-	if err != nil {
-		return nil, err
-	}
-	if iuse != "" {
-		ans.IUse = strings.Split(iuse, " ")
-	}
-
-	iuseEffective, err := parseMetaFile(
-		filepath.Join(metaDir, "IUSE_EFFECTIVE"), true,
-	)
-	if err != nil {
-		return nil, err
-	}
-	if iuseEffective != "" {
-		ans.IUseEffective = strings.Split(iuseEffective, " ")
-	}
+	return ans, nil
+}
 
-	use, err := parseMetaFile(
-		filepath.Join(metaDir, "USE"), true,
-	)
-	if err != nil {
-		return nil, err
-	}
-	if use != "" {
-		ans.Use = strings.Split(use, " ")
+// loadFields loads the metadata of the fields supplied with the reader.
+// With an empty list all the fields are loaded.
+func (m *PortageMetaData) loadFields(read metaFileReader, opts *PortageUseParseOpts, fields []PortageMetaField) error {
+	if len(fields) == 0 {
+		fields = AllPortageMetaFields
 	}
 
-	if len(ans.IUseEffective) > 0 {
-		ans.GentooPackage.UseFlags = elaborateUses(ans.IUseEffective, ans.Use, opts)
-	}
+	for _, f := range fields {
+		loader, ok := portageMetaFieldLoaders[f]
+		if !ok {
+			return errors.New("Invalid metadata field " + string(f))
+		}
 
-	ans.Ebuild, err = parseMetaFile(filepath.Join(metaDir, ans.GentooPackage.GetPF()+".ebuild"), true)
-	if err != nil {
-		return nil, err
+		err := loader(m, read)
+		if err != nil {
+			return err
+		}
 	}
 
-	ans.CONTENTS, err = GetCONTENTS(filepath.Join(metaDir, "CONTENTS"))
-	if err != nil {
-		return nil, err
+	if len(m.IUseEffective) > 0 {
+		m.GentooPackage.UseFlags = elaborateUses(m.IUseEffective, m.Use, opts)
 	}
 
-	return ans, nil
+	return nil
 }
 
 func useInArray(use string, arr []string) bool {
@@ -555,22 +379,19 @@ func parseMetaFile(file string, dropLn bool) (string, error) {
 }
 
 func GetCONTENTS(file string) ([]PortageContentElem, error) {
-	ans := []PortageContentElem{}
-
-	_, err := os.Stat(file)
+	data, err := parseMetaFile(file, false)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return ans, nil
-		}
-		return ans, err
+		return []PortageContentElem{}, err
 	}
 
-	data, err := ioutil.ReadFile(file)
-	if err != nil {
-		return ans, err
-	}
+	return ParseCONTENTS(data), nil
+}
 
-	lines := strings.Split(string(data), "\n")
+// ParseCONTENTS parses the content of a CONTENTS file.
+func ParseCONTENTS(data string) []PortageContentElem {
+	ans := []PortageContentElem{}
+
+	lines := strings.Split(data, "\n")
 
 	for _, line := range lines {
 
@@ -604,7 +425,7 @@ func GetCONTENTS(file string) ([]PortageContentElem, error) {
 
 	}
 
-	return ans, nil
+	return ans
 }
 
 func (e PortageContentElem) String() string {
@@ -621,19 +442,10 @@ func (e PortageContentElem) String() string {
 	return ans
 }
 
-func (m *PortageMetaData) WriteMetadata2Dir(dir string, opts *PortageUseParseOpts) error {
-
-	metadir := filepath.Join(dir,
-		fmt.Sprintf("/var/db/pkg/%s-%s", m.GetPackageName(), m.GetPVR()),
-	)
-
-	var fileMode os.FileMode
-	fileMode = os.ModeDir | 0744
-
-	err := os.MkdirAll(metadir, fileMode)
-	if err != nil {
-		return err
-	}
+// writeMetadataFiles writes the metadata files of the package in
+// the directory metadir.
+func (m *PortageMetaData) writeMetadataFiles(metadir string) error {
+	var err error
 
 	// Write BDEPEND file
 	if m.BDEPEND != "" {
@@ -868,6 +680,16 @@ func (m *PortageMetaData) WriteMetadata2Dir(dir string, opts *PortageUseParseOpt
 		}
 	}
 
+	// Write PDEPEND
+	if m.PDEPEND != "" {
+		err = os.WriteFile(filepath.Join(metadir, "PDEPEND"),
+			[]byte(m.PDEPEND+"\n"), 0644,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Write RDEPEND
 	if m.RDEPEND != "" {
 		err = os.WriteFile(filepath.Join(metadir, "RDEPEND"),
@@ -886,6 +708,16 @@ func (m *PortageMetaData) WriteMetadata2Dir(dir string, opts *PortageUseParseOpt
 		return err
 	}
 
+	// Write REQUIRED_USE
+	if m.REQUIRED_USE != "" {
+		err = os.WriteFile(filepath.Join(metadir, "REQUIRED_USE"),
+			[]byte(m.REQUIRED_USE+"\n"), 0644,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Write REQUIRES
 	if m.REQUIRES != "" {
 		err = os.WriteFile(filepath.Join(metadir, "REQUIRES"),
@@ -915,7 +747,7 @@ func (m *PortageMetaData) WriteMetadata2Dir(dir string, opts *PortageUseParseOpt
 	}
 
 	err = os.WriteFile(filepath.Join(metadir, "SLOT"),
-		[]byte(m.GentooPackage.Slot+"\n"), 0644,
+		[]byte(m.GentooPackage.GetSlotStr()+"\n"), 0644,
 	)
 	if err != nil {
 		return err
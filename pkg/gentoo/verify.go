@@ -0,0 +1,186 @@
+/*
+
+Copyright (C) 2017-2021  Daniele Rondina <geaaru@sabayonlinux.org>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+*/
+
+package gentoo
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+const (
+	VerifyIssueMissing = "missing"
+	VerifyIssueType    = "type"
+	VerifyIssueMd5     = "md5"
+	VerifyIssueMtime   = "mtime"
+	VerifyIssueSymlink = "symlink"
+)
+
+const (
+	ContentTypeDir = "dir"
+	ContentTypeObj = "obj"
+	ContentTypeSym = "sym"
+)
+
+type ContentsVerifyIssue struct {
+	Type     string `json:"type"`
+	File     string `json:"file"`
+	Expected string `json:"expected,omitempty"`
+	Actual   string `json:"actual,omitempty"`
+}
+
+type ContentsVerifyOpts struct {
+	IgnoreMtime bool `json:"ignore_mtime,omitempty" yaml:"ignore_mtime,omitempty"`
+	IgnoreMd5   bool `json:"ignore_md5,omitempty" yaml:"ignore_md5,omitempty"`
+}
+
+func (i ContentsVerifyIssue) String() string {
+	switch i.Type {
+	case VerifyIssueMissing:
+		return fmt.Sprintf("%s: missing", i.File)
+	}
+	return fmt.Sprintf("%s: %s changed (expected %s, found %s)",
+		i.File, i.Type, i.Expected, i.Actual)
+}
+
+func contentFileType(fi os.FileInfo) string {
+	switch {
+	case fi.Mode()&os.ModeSymlink != 0:
+		return ContentTypeSym
+	case fi.IsDir():
+		return ContentTypeDir
+	case fi.Mode().IsRegular():
+		return ContentTypeObj
+	}
+	return fi.Mode().Type().String()
+}
+
+func fileMd5(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := md5.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Verify checks the entry against the filesystem under the root
+// directory and returns the differences found.
+func (e PortageContentElem) Verify(root string, opts *ContentsVerifyOpts) ([]ContentsVerifyIssue, error) {
+	ans := []ContentsVerifyIssue{}
+	if opts == nil {
+		opts = &ContentsVerifyOpts{}
+	}
+
+	path := filepath.Join(root, e.File)
+	fi, err := os.Lstat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			ans = append(ans, ContentsVerifyIssue{
+				Type: VerifyIssueMissing,
+				File: e.File,
+			})
+			return ans, nil
+		}
+		return ans, err
+	}
+
+	ftype := contentFileType(fi)
+	if ftype != e.Type {
+		ans = append(ans, ContentsVerifyIssue{
+			Type:     VerifyIssueType,
+			File:     e.File,
+			Expected: e.Type,
+			Actual:   ftype,
+		})
+		return ans, nil
+	}
+
+	switch e.Type {
+	case ContentTypeObj:
+		if !opts.IgnoreMd5 {
+			hash, err := fileMd5(path)
+			if err != nil {
+				return ans, errors.New(
+					fmt.Sprintf("Error on calculate md5 of %s: %s", path, err.Error()))
+			}
+			if hash != e.Hash {
+				ans = append(ans, ContentsVerifyIssue{
+					Type:     VerifyIssueMd5,
+					File:     e.File,
+					Expected: e.Hash,
+					Actual:   hash,
+				})
+			}
+		}
+
+	case ContentTypeSym:
+		link, err := os.Readlink(path)
+		if err != nil {
+			return ans, err
+		}
+		if link != e.Link {
+			ans = append(ans, ContentsVerifyIssue{
+				Type:     VerifyIssueSymlink,
+				File:     e.File,
+				Expected: e.Link,
+				Actual:   link,
+			})
+		}
+	}
+
+	if e.Type != ContentTypeDir && !opts.IgnoreMtime && e.UnixTimestamp != "" {
+		mtime := strconv.FormatInt(fi.ModTime().Unix(), 10)
+		if mtime != e.UnixTimestamp {
+			ans = append(ans, ContentsVerifyIssue{
+				Type:     VerifyIssueMtime,
+				File:     e.File,
+				Expected: e.UnixTimestamp,
+				Actual:   mtime,
+			})
+		}
+	}
+
+	return ans, nil
+}
+
+// VerifyContents checks all the CONTENTS entries of the package
+// under the root directory.
+func (m *PortageMetaData) VerifyContents(root string, opts *ContentsVerifyOpts) ([]ContentsVerifyIssue, error) {
+	ans := []ContentsVerifyIssue{}
+	for _, e := range m.CONTENTS {
+		issues, err := e.Verify(root, opts)
+		if err != nil {
+			return ans, err
+		}
+		ans = append(ans, issues...)
+	}
+	return ans, nil
+}
@@ -0,0 +1,121 @@
+/*
+
+Copyright (C) 2017-2021  Daniele Rondina <geaaru@sabayonlinux.org>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+*/
+
+
+package gentoo_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/Sabayon/pkgs-checker/pkg/gentoo"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Gentoo CONTENTS verify", func() {
+
+	var root string
+	mtime := time.Unix(1600000000, 0)
+
+	BeforeEach(func() {
+		var err error
+		root, err = ioutil.TempDir("", "verify")
+		Expect(err).Should(BeNil())
+
+		Expect(os.MkdirAll(filepath.Join(root, "usr", "bin"), 0755)).Should(BeNil())
+		Expect(ioutil.WriteFile(filepath.Join(root, "usr", "bin", "foo"),
+			[]byte("foo\n"), 0755)).Should(BeNil())
+		Expect(os.Chtimes(filepath.Join(root, "usr", "bin", "foo"), mtime, mtime)).Should(BeNil())
+		Expect(os.Symlink("foo", filepath.Join(root, "usr", "bin", "bar"))).Should(BeNil())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(root)
+	})
+
+	newMeta := func(contents []PortageContentElem) *PortageMetaData {
+		gp, err := ParsePackageStr("app-misc/foo-1.0")
+		Expect(err).Should(BeNil())
+		m := NewPortageMetaData(gp)
+		m.CONTENTS = contents
+		return m
+	}
+
+	It("Check valid contents", func() {
+		m := newMeta([]PortageContentElem{
+			{Type: "dir", File: "/usr/bin"},
+			{Type: "obj", File: "/usr/bin/foo", Hash: "d3b07384d113edec49eaa6238ad5ff00", UnixTimestamp: "1600000000"},
+			{Type: "sym", File: "/usr/bin/bar", Link: "foo"},
+		})
+		issues, err := m.VerifyContents(root, nil)
+		Expect(err).Should(BeNil())
+		Expect(issues).Should(Equal([]ContentsVerifyIssue{}))
+	})
+
+	It("Check issues", func() {
+		m := newMeta([]PortageContentElem{
+			{Type: "obj", File: "/usr/bin/missing", Hash: "d3b07384d113edec49eaa6238ad5ff00", UnixTimestamp: "1600000000"},
+			{Type: "obj", File: "/usr/bin/foo", Hash: "00000000000000000000000000000000", UnixTimestamp: "1500000000"},
+			{Type: "sym", File: "/usr/bin/bar", Link: "baz"},
+			{Type: "obj", File: "/usr/bin", Hash: "00000000000000000000000000000000"},
+		})
+		issues, err := m.VerifyContents(root, nil)
+		Expect(err).Should(BeNil())
+		Expect(issues).Should(Equal([]ContentsVerifyIssue{
+			{Type: VerifyIssueMissing, File: "/usr/bin/missing"},
+			{Type: VerifyIssueMd5, File: "/usr/bin/foo",
+				Expected: "00000000000000000000000000000000", Actual: "d3b07384d113edec49eaa6238ad5ff00"},
+			{Type: VerifyIssueMtime, File: "/usr/bin/foo", Expected: "1500000000", Actual: "1600000000"},
+			{Type: VerifyIssueSymlink, File: "/usr/bin/bar", Expected: "baz", Actual: "foo"},
+			{Type: VerifyIssueType, File: "/usr/bin", Expected: "obj", Actual: "dir"},
+		}))
+		Expect(issues[0].String()).Should(Equal("/usr/bin/missing: missing"))
+		Expect(issues[3].String()).Should(Equal("/usr/bin/bar: symlink changed (expected baz, found foo)"))
+	})
+
+	It("Check ignore options", func() {
+		m := newMeta([]PortageContentElem{
+			{Type: "obj", File: "/usr/bin/foo", Hash: "00000000000000000000000000000000", UnixTimestamp: "1500000000"},
+		})
+		issues, err := m.VerifyContents(root, &ContentsVerifyOpts{IgnoreMtime: true, IgnoreMd5: true})
+		Expect(err).Should(BeNil())
+		Expect(len(issues)).Should(Equal(0))
+	})
+
+	It("Check CONTENTS from file", func() {
+		Expect(ioutil.WriteFile(filepath.Join(root, "CONTENTS"), []byte(
+			"dir /usr/bin\n"+
+				"obj /usr/bin/foo d3b07384d113edec49eaa6238ad5ff00 1600000000\n"+
+				"sym /usr/bin/bar -> foo 1600000000\n"), 0644)).Should(BeNil())
+		contents, err := GetCONTENTS(filepath.Join(root, "CONTENTS"))
+		Expect(err).Should(BeNil())
+
+		issues, err := newMeta(contents).VerifyContents(root, nil)
+		Expect(err).Should(BeNil())
+		// The mtime of the symlink is the time of the creation.
+		Expect(len(issues)).Should(Equal(1))
+		Expect(issues[0].Type).Should(Equal(VerifyIssueMtime))
+		Expect(issues[0].File).Should(Equal("/usr/bin/bar"))
+	})
+
+})
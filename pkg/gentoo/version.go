@@ -0,0 +1,323 @@
+/*
+
+Copyright (C) 2017-2021  Daniele Rondina <geaaru@sabayonlinux.org>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package gentoo
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Version comparison based on the algorithm described by the
+// Package Manager Specification (section 3.3):
+// https://projects.gentoo.org/pms/latest/pms.html#x1-250003.3
+
+const (
+	VersionSuffixAlpha = "alpha"
+	VersionSuffixBeta  = "beta"
+	VersionSuffixPre   = "pre"
+	VersionSuffixRc    = "rc"
+	VersionSuffixP     = "p"
+)
+
+// Grammar of the version used by ParseVersion and by the atom parsing.
+const (
+	regexVersionNumberString   = `[0-9]+`
+	regexVersionNumbersString  = `(?:\.[0-9]+)*`
+	regexVersionLetterString   = `[a-z]?`
+	regexVersionSuffixString   = `_(alpha|beta|pre|rc|p)([0-9]*)`
+	regexVersionSuffixesString = `(?:_(?:alpha|beta|pre|rc|p)[0-9]*)*`
+	regexVersionRevisionString = `[0-9]+`
+
+	// RegexVersionString matches a version without the build.
+	RegexVersionString = regexVersionNumberString + regexVersionNumbersString +
+		regexVersionLetterString + regexVersionSuffixesString +
+		`(?:-r` + regexVersionRevisionString + `)?`
+)
+
+var (
+	regexVersion = regexp.MustCompile(
+		`^(` + regexVersionNumberString + `)(` + regexVersionNumbersString + `)(` +
+			regexVersionLetterString + `)(` + regexVersionSuffixesString + `)(?:-r(` +
+			regexVersionRevisionString + `))?$`,
+	)
+	regexVersionSuffix = regexp.MustCompile(regexVersionSuffixString)
+)
+
+type GentooVersionSuffix struct {
+	Type   string `json:"type"`
+	Number string `json:"number,omitempty"`
+}
+
+type GentooVersion struct {
+	Numbers  []string              `json:"numbers"`
+	Letter   string                `json:"letter,omitempty"`
+	Suffixes []GentooVersionSuffix `json:"suffixes,omitempty"`
+	Revision string                `json:"revision,omitempty"`
+	// Sabayon extension used to rebuild the same package
+	// version (cat/foo-1.0+2). It's compared after the revision.
+	Build string `json:"build,omitempty"`
+}
+
+func versionSuffixWeight(s string) int {
+	switch s {
+	case VersionSuffixAlpha:
+		return 0
+	case VersionSuffixBeta:
+		return 1
+	case VersionSuffixPre:
+		return 2
+	case VersionSuffixRc:
+		return 3
+	case VersionSuffixP:
+		return 5
+	}
+	// No suffix
+	return 4
+}
+
+// ParseVersion parses a version string in the format
+// <numbers>[letter][_suffix[N]...][-rN][+build].
+func ParseVersion(v string) (*GentooVersion, error) {
+	if v == "" {
+		return nil, errors.New("Invalid version string")
+	}
+
+	ans := &GentooVersion{
+		Numbers:  []string{},
+		Suffixes: []GentooVersionSuffix{},
+	}
+
+	if idx := strings.Index(v, "+"); idx >= 0 {
+		ans.Build = v[idx+1:]
+		v = v[0:idx]
+	}
+
+	matches := regexVersion.FindStringSubmatch(v)
+	if matches == nil {
+		return nil, errors.New(fmt.Sprintf("Invalid version string %s", v))
+	}
+
+	ans.Numbers = append(ans.Numbers, matches[1])
+	if matches[2] != "" {
+		ans.Numbers = append(ans.Numbers, strings.Split(matches[2][1:], ".")...)
+	}
+	ans.Letter = matches[3]
+
+	if matches[4] != "" {
+		for _, s := range regexVersionSuffix.FindAllStringSubmatch(matches[4], -1) {
+			ans.Suffixes = append(ans.Suffixes, GentooVersionSuffix{
+				Type:   s[1],
+				Number: s[2],
+			})
+		}
+	}
+	ans.Revision = matches[5]
+
+	return ans, nil
+}
+
+// CompareVersions compares two version strings and returns -1, 0 or 1
+// if v1 is respectively less than, equal to or greater than v2.
+func CompareVersions(v1, v2 string) (int, error) {
+	gv1, err := ParseVersion(v1)
+	if err != nil {
+		return 0, err
+	}
+	gv2, err := ParseVersion(v2)
+	if err != nil {
+		return 0, err
+	}
+	return gv1.Compare(gv2), nil
+}
+
+func (v *GentooVersion) String() string {
+	ans := strings.Join(v.Numbers, ".") + v.Letter
+	for _, s := range v.Suffixes {
+		ans += "_" + s.Type + s.Number
+	}
+	if v.Revision != "" {
+		ans += "-r" + v.Revision
+	}
+	if v.Build != "" {
+		ans += "+" + v.Build
+	}
+	return ans
+}
+
+// Compare returns -1, 0 or 1 if v is respectively less than,
+// equal to or greater than o.
+func (v *GentooVersion) Compare(o *GentooVersion) int {
+	ans := v.CompareWithoutRevision(o)
+	if ans != 0 {
+		return ans
+	}
+
+	ans = compareIntStrings(v.Revision, o.Revision)
+	if ans != 0 {
+		return ans
+	}
+
+	return compareBuilds(v.Build, o.Build)
+}
+
+// CompareWithoutRevision compares the two versions ignoring the
+// revision and the build. It's used by the ~ operator.
+func (v *GentooVersion) CompareWithoutRevision(o *GentooVersion) int {
+	// Algorithm 3.2: compare the first numeric component as integers.
+	ans := compareIntStrings(v.Numbers[0], o.Numbers[0])
+	if ans != 0 {
+		return ans
+	}
+
+	// Algorithm 3.3: compare the others numeric components.
+	for idx := 1; idx < len(v.Numbers) && idx < len(o.Numbers); idx++ {
+		a := v.Numbers[idx]
+		b := o.Numbers[idx]
+		if strings.HasPrefix(a, "0") || strings.HasPrefix(b, "0") {
+			ans = strings.Compare(
+				strings.TrimRight(a, "0"), strings.TrimRight(b, "0"))
+		} else {
+			ans = compareIntStrings(a, b)
+		}
+		if ans != 0 {
+			return ans
+		}
+	}
+	if len(v.Numbers) != len(o.Numbers) {
+		if len(v.Numbers) > len(o.Numbers) {
+			return 1
+		}
+		return -1
+	}
+
+	// Algorithm 3.4: compare letters.
+	ans = strings.Compare(v.Letter, o.Letter)
+	if ans != 0 {
+		return ans
+	}
+
+	// Algorithm 3.5: compare suffixes.
+	for idx := 0; idx < len(v.Suffixes) && idx < len(o.Suffixes); idx++ {
+		a := v.Suffixes[idx]
+		b := o.Suffixes[idx]
+		if a.Type != b.Type {
+			if versionSuffixWeight(a.Type) > versionSuffixWeight(b.Type) {
+				return 1
+			}
+			return -1
+		}
+		ans = compareIntStrings(a.Number, b.Number)
+		if ans != 0 {
+			return ans
+		}
+	}
+	if len(v.Suffixes) > len(o.Suffixes) {
+		if v.Suffixes[len(o.Suffixes)].Type == VersionSuffixP {
+			return 1
+		}
+		return -1
+	} else if len(v.Suffixes) < len(o.Suffixes) {
+		if o.Suffixes[len(v.Suffixes)].Type == VersionSuffixP {
+			return -1
+		}
+		return 1
+	}
+
+	return 0
+}
+
+// compareIntStrings compares two strings of digits as integers
+// without size limits. An empty string is handled as 0.
+func compareIntStrings(a, b string) int {
+	a = strings.TrimLeft(a, "0")
+	b = strings.TrimLeft(b, "0")
+	if len(a) != len(b) {
+		if len(a) > len(b) {
+			return 1
+		}
+		return -1
+	}
+	return strings.Compare(a, b)
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// compareBuilds compares the dot-separated identifiers of the build
+// version. Numeric identifiers are compared as integers.
+func compareBuilds(a, b string) int {
+	if a == b {
+		return 0
+	}
+	if a == "" {
+		return -1
+	}
+	if b == "" {
+		return 1
+	}
+
+	aw := strings.Split(a, ".")
+	bw := strings.Split(b, ".")
+	for idx := 0; idx < len(aw) && idx < len(bw); idx++ {
+		var ans int
+		if isDigits(aw[idx]) && isDigits(bw[idx]) {
+			ans = compareIntStrings(aw[idx], bw[idx])
+		} else {
+			ans = strings.Compare(aw[idx], bw[idx])
+		}
+		if ans != 0 {
+			return ans
+		}
+	}
+
+	if len(aw) > len(bw) {
+		return 1
+	} else if len(aw) < len(bw) {
+		return -1
+	}
+	return 0
+}
+
+// versionMatchGlob checks if the version match the =<pkg>-<glob>*
+// condition. The glob matches only on boundaries between version
+// parts, so 1* does not match 10.
+func versionMatchGlob(glob, v string) bool {
+	if !strings.HasPrefix(v, glob) {
+		return false
+	}
+	next := v[len(glob):]
+	if next == "" || glob == "" {
+		return true
+	}
+	if strings.ContainsAny(next[0:1], "._-") {
+		return true
+	}
+	return isDigits(glob[len(glob)-1:]) != isDigits(next[0:1])
+}
@@ -0,0 +1,203 @@
+/*
+
+Copyright (C) 2017-2021  Daniele Rondina <geaaru@sabayonlinux.org>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+*/
+
+package gentoo_test
+
+import (
+	"sort"
+
+	. "github.com/Sabayon/pkgs-checker/pkg/gentoo"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Gentoo Versions", func() {
+
+	// Tests based on the PMS version comparison rules:
+	// https://projects.gentoo.org/pms/latest/pms.html#x1-250003.3
+	DescribeTable("CompareVersions",
+		func(v1, v2 string, expected int) {
+			ans, err := CompareVersions(v1, v2)
+			Expect(err).Should(BeNil())
+			Expect(ans).Should(Equal(expected))
+
+			// Check symmetry
+			ans, err = CompareVersions(v2, v1)
+			Expect(err).Should(BeNil())
+			Expect(ans).Should(Equal(-expected))
+		},
+		// Numeric components
+		Entry("1 = 1", "1", "1", 0),
+		Entry("2 < 12", "2", "12", -1),
+		Entry("1.0 < 1.0.0", "1.0", "1.0.0", -1),
+		Entry("1.2 < 1.10", "1.2", "1.10", -1),
+		Entry("1.0.9 < 1.1", "1.0.9", "1.1", -1),
+		Entry("01 = 1", "01", "1", 0),
+		Entry("1.01 < 1.1", "1.01", "1.1", -1),
+		Entry("1.010 = 1.01", "1.010", "1.01", 0),
+		Entry("1.001 < 1.01", "1.001", "1.01", -1),
+		Entry("1.09 > 1.010", "1.09", "1.010", 1),
+		Entry("3.17.5.3.2.1 < 3.17.5.3.10", "3.17.5.3.2.1", "3.17.5.3.10", -1),
+		Entry("0.0.20190406 > 0.0.1", "0.0.20190406", "0.0.1", 1),
+		Entry("big numbers", "20210101000000000000", "20210101000000000001", -1),
+		// Letters
+		Entry("1.0 < 1.0a", "1.0", "1.0a", -1),
+		Entry("1.0a < 1.0b", "1.0a", "1.0b", -1),
+		Entry("1.0z < 1.0.1", "1.0z", "1.0.1", -1),
+		Entry("2018h < 2018i", "2018h", "2018i", -1),
+		// Suffixes
+		Entry("_alpha < _beta", "1.0_alpha", "1.0_beta", -1),
+		Entry("_beta < _pre", "1.0_beta", "1.0_pre", -1),
+		Entry("_pre < _rc", "1.0_pre", "1.0_rc", -1),
+		Entry("_rc < release", "1.0_rc", "1.0", -1),
+		Entry("release < _p", "1.0", "1.0_p", -1),
+		Entry("_alpha = _alpha0", "1.0_alpha", "1.0_alpha0", 0),
+		Entry("_rc1 < _rc2", "1.0_rc1", "1.0_rc2", -1),
+		Entry("_rc9 < _rc10", "1.0_rc9", "1.0_rc10", -1),
+		Entry("_p1 < _p2", "1.0_p1", "1.0_p2", -1),
+		Entry("_alpha1_pre3 < _alpha1", "1.2_alpha1_pre3", "1.2_alpha1", -1),
+		Entry("_alpha1_p1 > _alpha1", "1.2_alpha1_p1", "1.2_alpha1", 1),
+		Entry("_alpha1_pre3 < _alpha1_rc1", "1.2_alpha1_pre3", "1.2_alpha1_rc1", -1),
+		Entry("_alpha1_pre3 < _beta", "1.2_alpha1_pre3", "1.2_beta", -1),
+		Entry("_p1_alpha < _p1", "1.0_p1_alpha", "1.0_p1", -1),
+		Entry("_pre20200315 > _pre20200314", "1.0_pre20200315", "1.0_pre20200314", 1),
+		Entry("1.0a_alpha > 1.0", "1.0a_alpha", "1.0", 1),
+		// Revisions
+		Entry("1.0 < 1.0-r1", "1.0", "1.0-r1", -1),
+		Entry("1.0 = 1.0-r0", "1.0", "1.0-r0", 0),
+		Entry("1.0-r1 < 1.0-r10", "1.0-r1", "1.0-r10", -1),
+		Entry("1.0-r9 < 1.0_p1", "1.0-r9", "1.0_p1", -1),
+		Entry("1.0_rc1-r3 < 1.0", "1.0_rc1-r3", "1.0", -1),
+		// Builds
+		Entry("1.0 < 1.0+1", "1.0", "1.0+1", -1),
+		Entry("1.0+1 < 1.0+5", "1.0+1", "1.0+5", -1),
+		Entry("1.0+2 < 1.0+10", "1.0+2", "1.0+10", -1),
+		Entry("1.0-r1+1 > 1.0+5", "1.0-r1+1", "1.0+5", 1),
+	)
+
+	DescribeTable("Invalid versions",
+		func(v string) {
+			_, err := ParseVersion(v)
+			Expect(err).ShouldNot(BeNil())
+		},
+		Entry("empty", ""),
+		Entry("no numbers", "a"),
+		Entry("two letters", "1.0ab"),
+		Entry("invalid suffix", "1.0_foo"),
+		Entry("invalid revision", "1.0-r"),
+		Entry("trailing dot", "1."),
+	)
+
+	Context("ParseVersion", func() {
+		v, err := ParseVersion("1.2.03b_alpha1_p-r4+2")
+
+		It("Check error", func() {
+			Expect(err).Should(BeNil())
+		})
+
+		It("Check fields", func() {
+			Expect(v.Numbers).Should(Equal([]string{"1", "2", "03"}))
+			Expect(v.Letter).Should(Equal("b"))
+			Expect(v.Suffixes).Should(Equal([]GentooVersionSuffix{
+				{Type: "alpha", Number: "1"},
+				{Type: "p", Number: ""},
+			}))
+			Expect(v.Revision).Should(Equal("4"))
+			Expect(v.Build).Should(Equal("2"))
+			Expect(v.String()).Should(Equal("1.2.03b_alpha1_p-r4+2"))
+		})
+	})
+
+	Context("Parse package with suffix chain", func() {
+		gp, err := ParsePackageStr("app-misc/foo-1.2_alpha1_pre3-r1")
+
+		It("Check error", func() {
+			Expect(err).Should(BeNil())
+		})
+
+		It("Check version", func() {
+			Expect(gp.Version).Should(Equal("1.2"))
+			Expect(gp.VersionSuffix).Should(Equal("_alpha1_pre3-r1"))
+		})
+	})
+
+	DescribeTable("Admit",
+		func(atom, pkg string, expected bool) {
+			gpA, err := ParsePackageStr(atom)
+			Expect(err).Should(BeNil())
+			gpB, err := ParsePackageStr(pkg)
+			Expect(err).Should(BeNil())
+			ans, err := gpA.Admit(gpB)
+			Expect(err).Should(BeNil())
+			Expect(ans).Should(Equal(expected))
+		},
+		Entry(">= with leading zero", ">=app-misc/foo-1.1", "app-misc/foo-1.01", false),
+		Entry(">= with letter", ">=app-misc/foo-1.0a", "app-misc/foo-1.0b", true),
+		Entry("< with suffix chain", "<app-misc/foo-1.2_alpha1", "app-misc/foo-1.2_alpha1_pre3", true),
+		Entry("> with _p", ">app-misc/foo-1.2", "app-misc/foo-1.2_p1", true),
+		Entry("> with revision", ">app-misc/foo-1.2", "app-misc/foo-1.2-r1", true),
+		Entry("= with -r0", "=app-misc/foo-1.2-r0", "app-misc/foo-1.2", true),
+		Entry("~ with revision", "~app-misc/foo-1.2", "app-misc/foo-1.2-r3", true),
+		Entry("~ with _p", "~app-misc/foo-1.2", "app-misc/foo-1.2_p1", false),
+		Entry("=* on boundary", "=app-misc/foo-7.3*", "app-misc/foo-7.3.1", true),
+		Entry("=* inside component", "=app-misc/foo-7.3*", "app-misc/foo-7.30", false),
+		Entry("=* with letter", "=app-misc/foo-1*", "app-misc/foo-1a", true),
+		Entry("=* with suffix", "=app-misc/foo-1.2_rc*", "app-misc/foo-1.2_rc1", true),
+	)
+
+	Context("Sort packages", func() {
+		list := []string{
+			"app-misc/foo-1.0_p1",
+			"app-misc/foo-1.0-r1",
+			"app-misc/foo-1.0",
+			"app-misc/foo-1.0_rc1",
+			"app-misc/foo-1.0_alpha1_pre3",
+			"app-misc/foo-1.0_alpha1",
+			"app-misc/foo-1.0a",
+			"app-misc/foo-1.01",
+		}
+		pkgs := []GentooPackage{}
+		for _, p := range list {
+			gp, _ := ParsePackageStr(p)
+			pkgs = append(pkgs, *gp)
+		}
+
+		sort.Sort(GentooPackageSorter(pkgs))
+
+		It("Check order", func() {
+			ans := []string{}
+			for _, p := range pkgs {
+				ans = append(ans, p.GetPF())
+			}
+			Expect(ans).Should(Equal([]string{
+				"foo-1.0_alpha1_pre3",
+				"foo-1.0_alpha1",
+				"foo-1.0_rc1",
+				"foo-1.0",
+				"foo-1.0-r1",
+				"foo-1.0_p1",
+				"foo-1.0a",
+				"foo-1.01",
+			}))
+		})
+	})
+
+})
@@ -0,0 +1,319 @@
+/*
+
+Copyright (C) 2017-2021  Daniele Rondina <geaaru@sabayonlinux.org>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+*/
+
+package gentoo
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	WorldSetPrefix = "@"
+	SystemSetName  = "system"
+
+	ProfileParentFile   = "parent"
+	ProfilePackagesFile = "packages"
+)
+
+// OrphansReport contains the installed packages not reachable from
+// the root atoms.
+type OrphansReport struct {
+	Roots      []string `json:"roots"`
+	Unresolved []string `json:"unresolved,omitempty"`
+	Reachable  int      `json:"reachable"`
+	Orphans    []string `json:"orphans"`
+}
+
+// parseListFile returns the not empty lines of the file without
+// the comments.
+func parseListFile(data []byte) ([]string, error) {
+	ans := []string{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[0:i]
+		}
+		line = strings.TrimSpace(line)
+		if line != "" {
+			ans = append(ans, line)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return ans, nil
+}
+
+func loadListFile(file string) ([]string, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []string{}, nil
+		}
+		return nil, err
+	}
+	return parseListFile(data)
+}
+
+// LoadWorld reads the atoms of the world file (/var/lib/portage/world).
+// A missing file is an empty world.
+func LoadWorld(file string) ([]string, error) {
+	atoms, err := loadListFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, a := range atoms {
+		if _, err := ParseAtom(a); err != nil {
+			return nil, errors.New(
+				fmt.Sprintf("Invalid atom %s in %s: %s", a, file, err.Error()))
+		}
+	}
+
+	return atoms, nil
+}
+
+// LoadWorldSets reads the sets of the world_sets file
+// (/var/lib/portage/world_sets). A missing file is an empty list.
+func LoadWorldSets(file string) ([]string, error) {
+	sets, err := loadListFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, s := range sets {
+		if !strings.HasPrefix(s, WorldSetPrefix) {
+			return nil, errors.New(
+				fmt.Sprintf("Invalid set %s in %s", s, file))
+		}
+	}
+
+	return sets, nil
+}
+
+// LoadSystemSet returns the atoms of the @system set defined by the
+// packages files of the profiles: the atoms with the * prefix are added
+// and the atoms with the -* prefix are removed. The parents of a profile
+// are elaborated before the profile.
+func LoadSystemSet(profiles []string) ([]string, error) {
+	atoms := []string{}
+	visited := make(map[string]bool, 0)
+
+	var load func(profile string) error
+	load = func(profile string) error {
+		dir, err := filepath.EvalSymlinks(profile)
+		if err != nil {
+			return errors.New(
+				fmt.Sprintf("Invalid profile %s: %s", profile, err.Error()))
+		}
+		if visited[dir] {
+			return nil
+		}
+		visited[dir] = true
+
+		parents, err := loadListFile(filepath.Join(dir, ProfileParentFile))
+		if err != nil {
+			return err
+		}
+		for _, p := range parents {
+			if strings.Contains(p, ":") {
+				return errors.New(
+					fmt.Sprintf("Unsupported parent %s of the profile %s", p, dir))
+			}
+			if !filepath.IsAbs(p) {
+				p = filepath.Join(dir, p)
+			}
+			err = load(p)
+			if err != nil {
+				return err
+			}
+		}
+
+		entries, err := loadListFile(filepath.Join(dir, ProfilePackagesFile))
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			switch {
+			case strings.HasPrefix(e, "-*"):
+				removed := []string{}
+				for _, a := range atoms {
+					if a != e[2:] {
+						removed = append(removed, a)
+					}
+				}
+				atoms = removed
+			case strings.HasPrefix(e, "*"):
+				if _, err := ParseAtom(e[1:]); err != nil {
+					return errors.New(
+						fmt.Sprintf("Invalid atom %s in %s: %s", e, dir, err.Error()))
+				}
+				atoms = append(atoms, e[1:])
+			}
+		}
+
+		return nil
+	}
+
+	for _, p := range profiles {
+		err := load(p)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return atoms, nil
+}
+
+// ResolveSets returns the atoms of the sets defined as files under the
+// sets directory (/etc/portage/sets) or supplied by the builtin map
+// (ex. the @system set). The nested sets are resolved too.
+// The sets not available are returned as unknown.
+func ResolveSets(sets []string, setsDir string, builtin map[string][]string) ([]string, []string, error) {
+	atoms := []string{}
+	unknown := []string{}
+	visited := make(map[string]bool, 0)
+
+	var resolve func(set string) error
+	resolve = func(set string) error {
+		name := strings.TrimPrefix(set, WorldSetPrefix)
+		if visited[name] {
+			return nil
+		}
+		visited[name] = true
+
+		if a, ok := builtin[name]; ok {
+			atoms = append(atoms, a...)
+			return nil
+		}
+
+		file := filepath.Join(setsDir, name)
+		if _, err := os.Stat(file); err != nil {
+			if os.IsNotExist(err) {
+				unknown = append(unknown, WorldSetPrefix+name)
+				return nil
+			}
+			return err
+		}
+
+		entries, err := loadListFile(file)
+		if err != nil {
+			return err
+		}
+
+		for _, e := range entries {
+			if strings.HasPrefix(e, WorldSetPrefix) {
+				err = resolve(e)
+				if err != nil {
+					return err
+				}
+			} else {
+				atoms = append(atoms, e)
+			}
+		}
+		return nil
+	}
+
+	for _, s := range sets {
+		err := resolve(s)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return atoms, unknown, nil
+}
+
+// GetClosure returns the installed packages reachable from the root
+// atoms through the dependencies of the graph and the atoms that
+// don't match installed packages.
+func (g *DependencyGraph) GetClosure(roots []string) (map[string]bool, []string, error) {
+	ans := make(map[string]bool, 0)
+	unresolved := []string{}
+	queue := []string{}
+
+	for _, r := range roots {
+		gp, err := ParseAtom(r)
+		if err != nil {
+			return nil, nil, errors.New(
+				fmt.Sprintf("Invalid atom %s: %s", r, err.Error()))
+		}
+
+		pkgs := g.Match(gp, nil)
+		if len(pkgs) == 0 {
+			unresolved = append(unresolved, r)
+		}
+		for _, p := range pkgs {
+			if !ans[p] {
+				ans[p] = true
+				queue = append(queue, p)
+			}
+		}
+	}
+
+	for len(queue) > 0 {
+		pkg := queue[0]
+		queue = queue[1:]
+		for _, e := range g.deps[pkg] {
+			if !ans[e.To] {
+				ans[e.To] = true
+				queue = append(queue, e.To)
+			}
+		}
+	}
+
+	return ans, unresolved, nil
+}
+
+// GetOrphans returns the installed packages not reachable from the
+// root atoms, like a dry-run of emerge --depclean.
+func (g *DependencyGraph) GetOrphans(roots []string) (*OrphansReport, error) {
+	reachable, unresolved, err := g.GetClosure(roots)
+	if err != nil {
+		return nil, err
+	}
+
+	ans := &OrphansReport{
+		Roots:      roots,
+		Unresolved: unresolved,
+		Reachable:  len(reachable),
+		Orphans:    []string{},
+	}
+
+	for pkg := range g.Packages {
+		if !reachable[pkg] {
+			ans.Orphans = append(ans.Orphans, pkg)
+		}
+	}
+	sort.Strings(ans.Orphans)
+
+	return ans, nil
+}
@@ -0,0 +1,167 @@
+/*
+
+Copyright (C) 2017-2021  Daniele Rondina <geaaru@sabayonlinux.org>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+*/
+
+package gentoo_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/Sabayon/pkgs-checker/pkg/gentoo"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Gentoo world and orphans", func() {
+
+	var tmpdir string
+
+	BeforeEach(func() {
+		var err error
+		tmpdir, err = ioutil.TempDir("", "world")
+		Expect(err).Should(BeNil())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tmpdir)
+	})
+
+	writeFile := func(name, content string) string {
+		file := filepath.Join(tmpdir, name)
+		Expect(os.MkdirAll(filepath.Dir(file), 0755)).Should(BeNil())
+		Expect(ioutil.WriteFile(file, []byte(content), 0644)).Should(BeNil())
+		return file
+	}
+
+	Context("World files", func() {
+
+		It("Check world file", func() {
+			file := writeFile("world", `# comment
+app-editors/vim
+
+dev-lang/python:3.9 # inline comment
+`)
+			atoms, err := LoadWorld(file)
+			Expect(err).Should(BeNil())
+			Expect(atoms).Should(Equal([]string{"app-editors/vim", "dev-lang/python:3.9"}))
+		})
+
+		It("Check missing world file", func() {
+			atoms, err := LoadWorld(filepath.Join(tmpdir, "missing"))
+			Expect(err).Should(BeNil())
+			Expect(len(atoms)).Should(Equal(0))
+		})
+
+		It("Check invalid world sets", func() {
+			file := writeFile("world_sets", "desktop\n")
+			_, err := LoadWorldSets(file)
+			Expect(err).ShouldNot(BeNil())
+		})
+
+		It("Check sets resolution", func() {
+			file := writeFile("world_sets", "@desktop\n@system\n")
+			writeFile("sets/desktop", "x11-base/xorg-server\n@fonts\n")
+			writeFile("sets/fonts", "media-fonts/dejavu\n@desktop\n")
+
+			sets, err := LoadWorldSets(file)
+			Expect(err).Should(BeNil())
+			Expect(sets).Should(Equal([]string{"@desktop", "@system"}))
+
+			atoms, unknown, err := ResolveSets(sets, filepath.Join(tmpdir, "sets"), nil)
+			Expect(err).Should(BeNil())
+			Expect(atoms).Should(Equal([]string{"x11-base/xorg-server", "media-fonts/dejavu"}))
+			Expect(unknown).Should(Equal([]string{"@system"}))
+
+			atoms, unknown, err = ResolveSets(sets, filepath.Join(tmpdir, "sets"),
+				map[string][]string{SystemSetName: []string{"sys-apps/baselayout"}})
+			Expect(err).Should(BeNil())
+			Expect(atoms).Should(Equal([]string{
+				"x11-base/xorg-server", "media-fonts/dejavu", "sys-apps/baselayout"}))
+			Expect(unknown).Should(Equal([]string{}))
+		})
+
+		It("Check system set", func() {
+			writeFile("profiles/base/packages", "*sys-apps/baselayout\n*sys-apps/sed\n*sys-apps/which\nsys-apps/portage\n")
+			writeFile("profiles/default/linux/parent", "../../base\n")
+			writeFile("profiles/default/linux/packages", "-*sys-apps/which\n*>=sys-libs/glibc-2.33\n")
+			writeFile("profiles/default/linux/amd64/parent", "..\n../../../base\n")
+			writeFile("user/packages", "*app-shells/bash\n")
+			Expect(os.Symlink(filepath.Join(tmpdir, "profiles/default/linux/amd64"),
+				filepath.Join(tmpdir, "make.profile"))).Should(BeNil())
+
+			atoms, err := LoadSystemSet([]string{
+				filepath.Join(tmpdir, "make.profile"), filepath.Join(tmpdir, "user")})
+			Expect(err).Should(BeNil())
+			Expect(atoms).Should(Equal([]string{
+				"sys-apps/baselayout", "sys-apps/sed", ">=sys-libs/glibc-2.33", "app-shells/bash"}))
+
+			_, err = LoadSystemSet([]string{filepath.Join(tmpdir, "missing")})
+			Expect(err).ShouldNot(BeNil())
+		})
+	})
+
+	Context("Orphans", func() {
+
+		var g *DependencyGraph
+
+		BeforeEach(func() {
+			vdb := filepath.Join(tmpdir, "vdb")
+			writeVdbPackage(vdb, "app-editors/vim-8.2.2", map[string]string{
+				"IUSE_EFFECTIVE": "python",
+				"USE":            "",
+				"RDEPEND":        "sys-libs/ncurses:0= python? ( dev-lang/python:3.9 )",
+			})
+			writeVdbPackage(vdb, "sys-libs/ncurses-6.2", map[string]string{
+				"SLOT":    "0/6",
+				"PDEPEND": "sys-libs/gpm",
+			})
+			writeVdbPackage(vdb, "sys-libs/gpm-1.20.7", map[string]string{})
+			writeVdbPackage(vdb, "dev-lang/python-3.9.4", map[string]string{
+				"SLOT": "3.9/3.9",
+			})
+			writeVdbPackage(vdb, "dev-util/cmake-3.20.2", map[string]string{
+				"DEPEND": "sys-libs/ncurses",
+			})
+
+			var err error
+			g, err = BuildDependencyGraph(NewVdbScanner(vdb, nil),
+				[]string{DepKindRdepend, DepKindPdepend})
+			Expect(err).Should(BeNil())
+		})
+
+		It("Check orphans", func() {
+			report, err := g.GetOrphans([]string{"app-editors/vim", "app-misc/missing"})
+			Expect(err).Should(BeNil())
+			Expect(report.Reachable).Should(Equal(3))
+			Expect(report.Unresolved).Should(Equal([]string{"app-misc/missing"}))
+			Expect(report.Orphans).Should(Equal([]string{
+				"dev-lang/python-3.9.4",
+				"dev-util/cmake-3.20.2",
+			}))
+		})
+
+		It("Check invalid root atom", func() {
+			_, err := g.GetOrphans([]string{"vim"})
+			Expect(err).ShouldNot(BeNil())
+		})
+	})
+
+})
@@ -0,0 +1,510 @@
+/*
+
+Copyright (C) 2017-2021  Daniele Rondina <geaaru@sabayonlinux.org>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+*/
+
+package gentoo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+const (
+	DefaultVdbSubPath = "var/db/pkg"
+	// Prefix of the temporary directories used by the writer. Like the
+	// -MERGING- directories of Portage they are ignored by the scanner.
+	VdbMergingPrefix = "-MERGING-"
+)
+
+// PortageMetaWriteOpts defines the behaviour of WriteMetadata2DirWithOpts.
+type PortageMetaWriteOpts struct {
+	// Path of the vdb relative to the target directory.
+	VdbSubPath string `json:"vdb_subpath,omitempty" yaml:"vdb_subpath,omitempty"`
+	// Re-parse the written entry and compare it with the metadata
+	// before replacing the existing entry.
+	Verify bool `json:"verify,omitempty" yaml:"verify,omitempty"`
+}
+
+func NewPortageMetaWriteOpts() *PortageMetaWriteOpts {
+	return &PortageMetaWriteOpts{
+		VdbSubPath: DefaultVdbSubPath,
+		Verify:     true,
+	}
+}
+
+func isVdbTempDir(name string) bool {
+	return strings.HasPrefix(name, VdbMergingPrefix)
+}
+
+// WriteMetadata2Dir writes the metadata of the package under the
+// <dir>/var/db/pkg directory and verifies the written entry.
+func (m *PortageMetaData) WriteMetadata2Dir(dir string, opts *PortageUseParseOpts) error {
+	return m.WriteMetadata2DirWithOpts(dir, opts, NewPortageMetaWriteOpts())
+}
+
+// WriteMetadata2DirWithOpts writes the metadata of the package in a
+// temporary directory of the vdb and then it replaces the package
+// entry with a rename. The files of an existing entry not written by
+// the metadata (environment.bz2, etc.) are preserved.
+func (m *PortageMetaData) WriteMetadata2DirWithOpts(dir string, opts *PortageUseParseOpts, wopts *PortageMetaWriteOpts) error {
+	if wopts == nil {
+		wopts = NewPortageMetaWriteOpts()
+	}
+	if opts == nil {
+		opts = &PortageUseParseOpts{}
+	}
+
+	tmpdir, newdir, metadir, err := prepareVdbEntry(dir, wopts, m.Category, m.GetPF())
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpdir)
+
+	err = m.writeMetadataFiles(newdir)
+	if err != nil {
+		return err
+	}
+
+	exists, err := isVdbEntry(metadir)
+	if err != nil {
+		return err
+	}
+	if exists {
+		err = copyMissingFiles(metadir, newdir)
+		if err != nil {
+			return errors.New(
+				fmt.Sprintf("Error on preserve files of %s: %s", metadir, err.Error()))
+		}
+	}
+
+	if wopts.Verify {
+		pm, err := ParsePackageMetadataDir(newdir, opts)
+		if err != nil {
+			return errors.New(
+				fmt.Sprintf("Error on verify metadata of %s: %s",
+					m.GetPackageNameWithVersion(), err.Error()))
+		}
+		diffs, err := m.CompareMetadata(pm)
+		if err != nil {
+			return err
+		}
+		if len(diffs) > 0 {
+			return errors.New(
+				fmt.Sprintf("Written metadata of %s differ on fields: %s",
+					m.GetPackageNameWithVersion(), strings.Join(diffs, ", ")))
+		}
+	}
+
+	return commitVdbEntry(tmpdir, newdir, metadir)
+}
+
+// prepareVdbEntry creates the temporary directory used to write the
+// entry of the package. The temporary entry keeps the category and the
+// name of the package in the path for the verification.
+func prepareVdbEntry(dir string, wopts *PortageMetaWriteOpts, cat, pf string) (string, string, string, error) {
+	vdbDir := filepath.Join(dir, wopts.VdbSubPath)
+	catDir := filepath.Join(vdbDir, cat)
+	metadir := filepath.Join(catDir, pf)
+
+	err := os.MkdirAll(catDir, 0755)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	err = recoverVdbEntry(vdbDir, cat, pf)
+	if err != nil {
+		return "", "", "", errors.New(
+			fmt.Sprintf("Error on recover entry %s/%s: %s", cat, pf, err.Error()))
+	}
+
+	tmpdir, err := ioutil.TempDir(vdbDir, VdbMergingPrefix)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	newdir := filepath.Join(tmpdir, cat, pf)
+	err = os.MkdirAll(newdir, 0755)
+	if err != nil {
+		os.RemoveAll(tmpdir)
+		return "", "", "", err
+	}
+
+	return tmpdir, newdir, metadir, nil
+}
+
+func isVdbEntry(metadir string) (bool, error) {
+	fi, err := os.Stat(metadir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	if !fi.IsDir() {
+		return false, errors.New("Path " + metadir + " is not a directory!")
+	}
+	return true, nil
+}
+
+// getVdbOldEntry returns the path where the old entry of the package
+// is moved by commitVdbEntry.
+func getVdbOldEntry(tmpdir, cat, pf string) string {
+	return filepath.Join(tmpdir, "old", cat, pf)
+}
+
+// commitVdbEntry replaces the package entry with the temporary entry.
+// The old entry is moved inside the temporary directory and removed
+// with it. If the process is interrupted between the two renames the
+// old entry is restored by recoverVdbEntry on the next write.
+func commitVdbEntry(tmpdir, newdir, metadir string) error {
+	exists, err := isVdbEntry(metadir)
+	if err != nil {
+		return err
+	}
+	if exists {
+		old := getVdbOldEntry(tmpdir,
+			filepath.Base(filepath.Dir(metadir)), filepath.Base(metadir))
+		err = os.MkdirAll(filepath.Dir(old), 0755)
+		if err != nil {
+			return err
+		}
+		err = os.Rename(metadir, old)
+		if err != nil {
+			return err
+		}
+	}
+
+	return os.Rename(newdir, metadir)
+}
+
+// recoverVdbEntry restores the old entry of the package left in a
+// temporary directory of the vdb by an interrupted commitVdbEntry.
+// Nothing is done if the entry of the package exists.
+func recoverVdbEntry(vdbDir, cat, pf string) error {
+	metadir := filepath.Join(vdbDir, cat, pf)
+	exists, err := isVdbEntry(metadir)
+	if err != nil || exists {
+		return err
+	}
+
+	files, err := ioutil.ReadDir(vdbDir)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		if !f.IsDir() || !isVdbTempDir(f.Name()) {
+			continue
+		}
+		tmpdir := filepath.Join(vdbDir, f.Name())
+		old := getVdbOldEntry(tmpdir, cat, pf)
+		exists, err = isVdbEntry(old)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			continue
+		}
+
+		err = os.Rename(old, metadir)
+		if err != nil {
+			return err
+		}
+		return os.RemoveAll(tmpdir)
+	}
+
+	return nil
+}
+
+// isModelledMetaFile returns true if the file of a vdb entry is loaded
+// in a field of PortageMetaData.
+func isModelledMetaFile(name string) bool {
+	if strings.HasSuffix(name, ".ebuild") {
+		return true
+	}
+	f := PortageMetaField(name)
+	_, ok := portageMetaFieldLoaders[f]
+	return ok && f != MetaFieldEbuild
+}
+
+// copyMissingFiles copies the regular files of the directory src not
+// available in the directory dst. The files of the modelled fields are
+// not copied: a field empty in the new metadata must not be restored.
+func copyMissingFiles(src, dst string) error {
+	files, err := ioutil.ReadDir(src)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		if !f.Mode().IsRegular() || isModelledMetaFile(f.Name()) {
+			continue
+		}
+		target := filepath.Join(dst, f.Name())
+		if _, err := os.Lstat(target); err == nil {
+			continue
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+
+		err = copyFile(filepath.Join(src, f.Name()), target, f.Mode().Perm())
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func copyFile(src, dst string, mode os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(out, in)
+	if err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+}
+
+func metadataFieldsMap(m *PortageMetaData) (map[string]interface{}, error) {
+	ans := make(map[string]interface{}, 0)
+
+	// The use flags of the package are elaborated by the parser with
+	// the filters of the options.
+	gp := *m.GentooPackage
+	gp.UseFlags = nil
+	pm := *m
+	pm.GentooPackage = &gp
+
+	data, err := json.Marshal(pm)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(data, &ans)
+	if err != nil {
+		return nil, err
+	}
+
+	return ans, nil
+}
+
+// CompareMetadata returns the names of the fields with different
+// values between the two packages.
+func (m *PortageMetaData) CompareMetadata(o *PortageMetaData) ([]string, error) {
+	ans := []string{}
+
+	f1, err := metadataFieldsMap(m)
+	if err != nil {
+		return nil, err
+	}
+	f2, err := metadataFieldsMap(o)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range f1 {
+		if !reflect.DeepEqual(v, f2[k]) {
+			ans = append(ans, k)
+		}
+	}
+	for k := range f2 {
+		if _, ok := f1[k]; !ok {
+			ans = append(ans, k)
+		}
+	}
+	sort.Strings(ans)
+
+	return ans, nil
+}
+
+// CopyPackageMetadataDir copies verbatim the vdb entry of a package,
+// including the files not modelled by PortageMetaData, under the
+// target directory. The overrides replace the content of the selected
+// metadata files (ex. repository or COUNTER).
+func CopyPackageMetadataDir(src, dir string, overrides map[PortageMetaField]string, wopts *PortageMetaWriteOpts) error {
+	if wopts == nil {
+		wopts = NewPortageMetaWriteOpts()
+	}
+
+	src, err := filepath.Abs(src)
+	if err != nil {
+		return err
+	}
+	pf := filepath.Base(src)
+	cat := filepath.Base(filepath.Dir(src))
+
+	files := make(map[string]string, 0)
+	for f, value := range overrides {
+		if _, ok := portageMetaFieldLoaders[f]; !ok || f == MetaFieldEbuild {
+			return errors.New("Invalid metadata field " + string(f))
+		}
+		// Portage writes the COUNTER without the newline.
+		if f != MetaFieldCounter {
+			value += "\n"
+		}
+		files[string(f)] = value
+	}
+
+	tmpdir, newdir, metadir, err := prepareVdbEntry(dir, wopts, cat, pf)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpdir)
+
+	err = copyDir(src, newdir)
+	if err != nil {
+		return errors.New(
+			fmt.Sprintf("Error on copy %s: %s", src, err.Error()))
+	}
+
+	for name, content := range files {
+		err = ioutil.WriteFile(filepath.Join(newdir, name), []byte(content), 0644)
+		if err != nil {
+			return err
+		}
+	}
+
+	if wopts.Verify {
+		err = verifyCopiedDir(src, newdir, files)
+		if err != nil {
+			return errors.New(
+				fmt.Sprintf("Error on verify copy of %s/%s: %s", cat, pf, err.Error()))
+		}
+		_, err = ParsePackageMetadataDir(newdir, nil)
+		if err != nil {
+			return errors.New(
+				fmt.Sprintf("Error on verify metadata of %s/%s: %s", cat, pf, err.Error()))
+		}
+	}
+
+	return commitVdbEntry(tmpdir, newdir, metadir)
+}
+
+// copyDir copies the regular files, the symlinks and the
+// subdirectories of src under the existing directory dst.
+func copyDir(src, dst string) error {
+	files, err := ioutil.ReadDir(src)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		from := filepath.Join(src, f.Name())
+		to := filepath.Join(dst, f.Name())
+
+		switch {
+		case f.IsDir():
+			err = os.Mkdir(to, f.Mode().Perm())
+			if err == nil {
+				err = copyDir(from, to)
+			}
+		case f.Mode()&os.ModeSymlink != 0:
+			var link string
+			link, err = os.Readlink(from)
+			if err == nil {
+				err = os.Symlink(link, to)
+			}
+		case f.Mode().IsRegular():
+			err = copyFile(from, to, f.Mode().Perm())
+		default:
+			err = errors.New("Unsupported file type for " + from)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// verifyCopiedDir compares the regular files of the copied entry with
+// the source or with the overridden content.
+func verifyCopiedDir(src, dst string, overrides map[string]string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+
+		expected, ok := overrides[rel]
+		if !ok {
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			expected = string(data)
+		}
+
+		data, err := ioutil.ReadFile(filepath.Join(dst, rel))
+		if err != nil {
+			return err
+		}
+		if string(data) != expected {
+			return errors.New("File " + rel + " is different")
+		}
+
+		return nil
+	})
+}
+
+// ParseMetaFieldOverrides parses a list of FIELD=VALUE strings
+// (ex. repository=sabayon, COUNTER=100).
+func ParseMetaFieldOverrides(values []string) (map[PortageMetaField]string, error) {
+	ans := make(map[PortageMetaField]string, 0)
+
+	for _, v := range values {
+		i := strings.Index(v, "=")
+		if i <= 0 {
+			return nil, errors.New("Invalid override " + v)
+		}
+		field := PortageMetaField(v[0:i])
+		if _, ok := portageMetaFieldLoaders[field]; !ok || field == MetaFieldEbuild {
+			return nil, errors.New("Invalid metadata field " + string(field))
+		}
+		ans[field] = v[i+1:]
+	}
+
+	return ans, nil
+}
@@ -0,0 +1,253 @@
+/*
+
+Copyright (C) 2017-2021  Daniele Rondina <geaaru@sabayonlinux.org>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+*/
+
+package gentoo_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/Sabayon/pkgs-checker/pkg/gentoo"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Gentoo metadata writer", func() {
+
+	var tmpdir string
+	var pm *PortageMetaData
+
+	BeforeEach(func() {
+		var err error
+		tmpdir, err = ioutil.TempDir("", "write")
+		Expect(err).Should(BeNil())
+
+		src := filepath.Join(tmpdir, "src")
+		writeVdbPackage(src, "dev-libs/openssl-1.1.1k-r1", map[string]string{
+			"SLOT":           "0/1.1",
+			"COUNTER":        "42",
+			"IUSE":           "+asm sslv3",
+			"IUSE_EFFECTIVE": "asm sslv3",
+			"USE":            "asm amd64",
+			"RDEPEND":        ">=sys-libs/zlib-1.2.8-r1",
+			"KEYWORDS":       "amd64 ~arm",
+			"repository":     "gentoo",
+			"CONTENTS": "dir /usr/lib64\n" +
+				"obj /usr/lib64/libssl.so.1.1 d41d8cd98f00b204e9800998ecf8427e 1617000000\n" +
+				"sym /usr/lib64/libssl.so -> libssl.so.1.1 1617000000\n",
+		})
+
+		pm, err = ParsePackageMetadataDir(filepath.Join(src, "dev-libs", "openssl-1.1.1k-r1"), nil)
+		Expect(err).Should(BeNil())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tmpdir)
+	})
+
+	It("Check write and parse", func() {
+		root := filepath.Join(tmpdir, "root")
+		Expect(pm.WriteMetadata2Dir(root, nil)).Should(BeNil())
+
+		pkgs, err := NewVdbScanner(filepath.Join(root, "var/db/pkg"), nil).Scan()
+		Expect(err).Should(BeNil())
+		Expect(len(pkgs)).Should(Equal(1))
+		Expect(pkgs[0].GetPackageNameWithVersion()).Should(Equal("dev-libs/openssl-1.1.1k-r1"))
+
+		diffs, err := pm.CompareMetadata(pkgs[0])
+		Expect(err).Should(BeNil())
+		Expect(len(diffs)).Should(Equal(0))
+
+		// No temporary directories are left.
+		files, err := ioutil.ReadDir(filepath.Join(root, "var/db/pkg"))
+		Expect(err).Should(BeNil())
+		Expect(len(files)).Should(Equal(1))
+	})
+
+	It("Check custom vdb path", func() {
+		root := filepath.Join(tmpdir, "root")
+		wopts := NewPortageMetaWriteOpts()
+		wopts.VdbSubPath = "vdb"
+		Expect(pm.WriteMetadata2DirWithOpts(root, nil, wopts)).Should(BeNil())
+
+		_, err := os.Stat(filepath.Join(root, "vdb", "dev-libs", "openssl-1.1.1k-r1", "SLOT"))
+		Expect(err).Should(BeNil())
+	})
+
+	It("Check preserve of the unmodelled files", func() {
+		root := filepath.Join(tmpdir, "root")
+		entry := filepath.Join(root, "var/db/pkg", "dev-libs", "openssl-1.1.1k-r1")
+		Expect(os.MkdirAll(entry, 0755)).Should(BeNil())
+		Expect(ioutil.WriteFile(filepath.Join(entry, "environment.bz2"),
+			[]byte("env"), 0644)).Should(BeNil())
+		Expect(ioutil.WriteFile(filepath.Join(entry, "COUNTER"),
+			[]byte("1"), 0644)).Should(BeNil())
+
+		Expect(pm.WriteMetadata2Dir(root, nil)).Should(BeNil())
+
+		data, err := ioutil.ReadFile(filepath.Join(entry, "environment.bz2"))
+		Expect(err).Should(BeNil())
+		Expect(string(data)).Should(Equal("env"))
+		data, err = ioutil.ReadFile(filepath.Join(entry, "COUNTER"))
+		Expect(err).Should(BeNil())
+		Expect(string(data)).Should(Equal("42"))
+	})
+
+	It("Check rewrite with an empty field", func() {
+		root := filepath.Join(tmpdir, "root")
+		entry := filepath.Join(root, "var/db/pkg", "dev-libs", "openssl-1.1.1k-r1")
+		Expect(pm.WriteMetadata2Dir(root, nil)).Should(BeNil())
+		Expect(ioutil.WriteFile(filepath.Join(entry, "openssl-1.1.1k.ebuild"),
+			[]byte("EAPI=7"), 0644)).Should(BeNil())
+
+		pm.RDEPEND = ""
+		Expect(pm.WriteMetadata2Dir(root, nil)).Should(BeNil())
+		_, err := os.Stat(filepath.Join(entry, "RDEPEND"))
+		Expect(os.IsNotExist(err)).Should(BeTrue())
+		_, err = os.Stat(filepath.Join(entry, "openssl-1.1.1k.ebuild"))
+		Expect(os.IsNotExist(err)).Should(BeTrue())
+
+		// Without verification the stale file must not be restored too.
+		Expect(ioutil.WriteFile(filepath.Join(entry, "RDEPEND"),
+			[]byte("sys-libs/zlib"), 0644)).Should(BeNil())
+		wopts := NewPortageMetaWriteOpts()
+		wopts.Verify = false
+		Expect(pm.WriteMetadata2DirWithOpts(root, nil, wopts)).Should(BeNil())
+		_, err = os.Stat(filepath.Join(entry, "RDEPEND"))
+		Expect(os.IsNotExist(err)).Should(BeTrue())
+	})
+
+	It("Check recover of an interrupted commit", func() {
+		root := filepath.Join(tmpdir, "root")
+		vdb := filepath.Join(root, "var/db/pkg")
+		entry := filepath.Join(vdb, "dev-libs", "openssl-1.1.1k-r1")
+		Expect(pm.WriteMetadata2Dir(root, nil)).Should(BeNil())
+		Expect(ioutil.WriteFile(filepath.Join(entry, "environment.bz2"),
+			[]byte("env"), 0644)).Should(BeNil())
+
+		// Simulate a failure after the move of the old entry and
+		// before the rename of the new entry.
+		old := filepath.Join(vdb, VdbMergingPrefix+"123", "old", "dev-libs", "openssl-1.1.1k-r1")
+		Expect(os.MkdirAll(filepath.Dir(old), 0755)).Should(BeNil())
+		Expect(os.Rename(entry, old)).Should(BeNil())
+		Expect(os.MkdirAll(filepath.Join(vdb, VdbMergingPrefix+"123", "dev-libs", "openssl-1.1.1k-r1"),
+			0755)).Should(BeNil())
+
+		Expect(pm.WriteMetadata2Dir(root, nil)).Should(BeNil())
+
+		data, err := ioutil.ReadFile(filepath.Join(entry, "environment.bz2"))
+		Expect(err).Should(BeNil())
+		Expect(string(data)).Should(Equal("env"))
+
+		files, err := ioutil.ReadDir(vdb)
+		Expect(err).Should(BeNil())
+		Expect(len(files)).Should(Equal(1))
+	})
+
+	It("Check compare metadata", func() {
+		other, err := ParsePackageMetadataDir(
+			filepath.Join(tmpdir, "src", "dev-libs", "openssl-1.1.1k-r1"), nil)
+		Expect(err).Should(BeNil())
+		other.COUNTER = "43"
+		other.CONTENTS = other.CONTENTS[1:]
+
+		diffs, err := pm.CompareMetadata(other)
+		Expect(err).Should(BeNil())
+		Expect(diffs).Should(Equal([]string{"content", "counter"}))
+	})
+
+	It("Check scanner skips temporary entries", func() {
+		vdb := filepath.Join(tmpdir, "src")
+		Expect(os.MkdirAll(filepath.Join(vdb, VdbMergingPrefix+"123", "dev-libs", "foo-1.0"),
+			0755)).Should(BeNil())
+		Expect(os.MkdirAll(filepath.Join(vdb, "dev-libs", VdbMergingPrefix+"foo-1.0"),
+			0755)).Should(BeNil())
+
+		pkgs, err := NewVdbScanner(vdb, nil).Scan()
+		Expect(err).Should(BeNil())
+		Expect(len(pkgs)).Should(Equal(1))
+	})
+
+	Context("Verbatim copy", func() {
+
+		var src string
+
+		BeforeEach(func() {
+			src = filepath.Join(tmpdir, "src", "dev-libs", "openssl-1.1.1k-r1")
+			Expect(ioutil.WriteFile(filepath.Join(src, "environment.bz2"),
+				[]byte("env"), 0644)).Should(BeNil())
+			Expect(ioutil.WriteFile(filepath.Join(src, "BINPKGMD5"),
+				[]byte("d41d8cd98f00b204e9800998ecf8427e\n"), 0644)).Should(BeNil())
+		})
+
+		It("Check copy with overrides", func() {
+			root := filepath.Join(tmpdir, "root")
+			overrides, err := ParseMetaFieldOverrides([]string{
+				"repository=sabayon", "COUNTER=100",
+			})
+			Expect(err).Should(BeNil())
+			Expect(CopyPackageMetadataDir(src, root, overrides, nil)).Should(BeNil())
+
+			entry := filepath.Join(root, "var/db/pkg", "dev-libs", "openssl-1.1.1k-r1")
+			data, err := ioutil.ReadFile(filepath.Join(entry, "environment.bz2"))
+			Expect(err).Should(BeNil())
+			Expect(string(data)).Should(Equal("env"))
+			data, err = ioutil.ReadFile(filepath.Join(entry, "BINPKGMD5"))
+			Expect(err).Should(BeNil())
+			Expect(string(data)).Should(Equal("d41d8cd98f00b204e9800998ecf8427e\n"))
+
+			copied, err := ParsePackageMetadataDir(entry, nil)
+			Expect(err).Should(BeNil())
+			Expect(copied.Repository).Should(Equal("sabayon"))
+			Expect(copied.COUNTER).Should(Equal("100"))
+
+			diffs, err := pm.CompareMetadata(copied)
+			Expect(err).Should(BeNil())
+			Expect(diffs).Should(Equal([]string{"counter", "package"}))
+		})
+
+		It("Check copy replaces the existing entry", func() {
+			root := filepath.Join(tmpdir, "root")
+			entry := filepath.Join(root, "var/db/pkg", "dev-libs", "openssl-1.1.1k-r1")
+			Expect(os.MkdirAll(entry, 0755)).Should(BeNil())
+			Expect(ioutil.WriteFile(filepath.Join(entry, "stale"),
+				[]byte("x"), 0644)).Should(BeNil())
+
+			Expect(CopyPackageMetadataDir(src, root, nil, nil)).Should(BeNil())
+
+			_, err := os.Stat(filepath.Join(entry, "stale"))
+			Expect(os.IsNotExist(err)).Should(BeTrue())
+			_, err = os.Stat(filepath.Join(entry, "environment.bz2"))
+			Expect(err).Should(BeNil())
+		})
+
+		It("Check invalid overrides", func() {
+			_, err := ParseMetaFieldOverrides([]string{"FOO=bar"})
+			Expect(err).ShouldNot(BeNil())
+			_, err = ParseMetaFieldOverrides([]string{"repository"})
+			Expect(err).ShouldNot(BeNil())
+			err = CopyPackageMetadataDir(src, tmpdir,
+				map[PortageMetaField]string{MetaFieldEbuild: "x"}, nil)
+			Expect(err).ShouldNot(BeNil())
+		})
+	})
+
+})
@@ -0,0 +1,256 @@
+/*
+
+Copyright (C) 2017-2021  Daniele Rondina <geaaru@sabayonlinux.org>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+*/
+
+package gentoo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// XPAK format of the metadata appended to the Portage .tbz2 binary
+// packages as described by xpak(5):
+//
+//   <tarball> XPAKPACK <index len> <data len> <index> <data> XPAKSTOP
+//   <xpak len> STOP
+//
+// Every index entry is <name len> <name> <data offset> <data len>.
+// All the integers are 32bit big-endian.
+
+const (
+	XpakHeader  = "XPAKPACK"
+	XpakFooter  = "XPAKSTOP"
+	XpakTrailer = "STOP"
+)
+
+// XpakData contains the files of the XPAK segment.
+type XpakData map[string][]byte
+
+// ParseXpak parses a XPAK segment from XPAKPACK to XPAKSTOP.
+func ParseXpak(data []byte) (XpakData, error) {
+	hlen := len(XpakHeader) + 8
+	if len(data) < hlen+len(XpakFooter) ||
+		string(data[0:len(XpakHeader)]) != XpakHeader {
+		return nil, errors.New("Invalid XPAK header")
+	}
+
+	indexLen := int(binary.BigEndian.Uint32(data[8:12]))
+	dataLen := int(binary.BigEndian.Uint32(data[12:16]))
+	if hlen+indexLen+dataLen+len(XpakFooter) != len(data) ||
+		string(data[len(data)-len(XpakFooter):]) != XpakFooter {
+		return nil, errors.New("Invalid XPAK size")
+	}
+
+	index := data[hlen : hlen+indexLen]
+	values := data[hlen+indexLen : hlen+indexLen+dataLen]
+
+	ans := make(XpakData, 0)
+	for pos := 0; pos < len(index); {
+		if pos+4 > len(index) {
+			return nil, errors.New("Invalid XPAK index")
+		}
+		nameLen := int(binary.BigEndian.Uint32(index[pos : pos+4]))
+		pos += 4
+		if pos+nameLen+8 > len(index) {
+			return nil, errors.New("Invalid XPAK index")
+		}
+		name := string(index[pos : pos+nameLen])
+		pos += nameLen
+		offset := int(binary.BigEndian.Uint32(index[pos : pos+4]))
+		size := int(binary.BigEndian.Uint32(index[pos+4 : pos+8]))
+		pos += 8
+
+		if offset+size > len(values) {
+			return nil, errors.New(
+				fmt.Sprintf("Invalid XPAK data for entry %s", name))
+		}
+		ans[name] = values[offset : offset+size]
+	}
+
+	return ans, nil
+}
+
+// ReadXpak reads the XPAK segment at the end of a binary package.
+func ReadXpak(r io.ReadSeeker) (XpakData, error) {
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	if size < 8 {
+		return nil, errors.New("XPAK trailer not found")
+	}
+
+	trailer := make([]byte, 8)
+	_, err = r.Seek(size-8, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+	_, err = io.ReadFull(r, trailer)
+	if err != nil {
+		return nil, err
+	}
+	if string(trailer[4:]) != XpakTrailer {
+		return nil, errors.New("XPAK trailer not found")
+	}
+
+	xpakLen := int64(binary.BigEndian.Uint32(trailer[0:4]))
+	if xpakLen > size-8 {
+		return nil, errors.New("Invalid XPAK size")
+	}
+
+	data := make([]byte, xpakLen)
+	_, err = r.Seek(size-8-xpakLen, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+	_, err = io.ReadFull(r, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseXpak(data)
+}
+
+// ReadXpakFile reads the XPAK segment of a .tbz2 file.
+func ReadXpakFile(file string) (XpakData, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ans, err := ReadXpak(f)
+	if err != nil {
+		return nil, errors.New(
+			fmt.Sprintf("Error on read XPAK of %s: %s", file, err.Error()))
+	}
+	return ans, nil
+}
+
+// GetNames returns the sorted names of the XPAK entries.
+func (x XpakData) GetNames() []string {
+	ans := []string{}
+	for k := range x {
+		ans = append(ans, k)
+	}
+	sort.Strings(ans)
+	return ans
+}
+
+// GetString returns the value of an entry without the final newline.
+func (x XpakData) GetString(name string) string {
+	return strings.TrimRight(string(x[name]), "\n")
+}
+
+// Encode returns the XPAK segment of the entries sorted by name.
+func (x XpakData) Encode() []byte {
+	var index, values bytes.Buffer
+	buf := make([]byte, 4)
+
+	writeUint32 := func(b *bytes.Buffer, v int) {
+		binary.BigEndian.PutUint32(buf, uint32(v))
+		b.Write(buf)
+	}
+
+	for _, name := range x.GetNames() {
+		writeUint32(&index, len(name))
+		index.WriteString(name)
+		writeUint32(&index, values.Len())
+		writeUint32(&index, len(x[name]))
+		values.Write(x[name])
+	}
+
+	var ans bytes.Buffer
+	ans.WriteString(XpakHeader)
+	writeUint32(&ans, index.Len())
+	writeUint32(&ans, values.Len())
+	ans.Write(index.Bytes())
+	ans.Write(values.Bytes())
+	ans.WriteString(XpakFooter)
+
+	return ans.Bytes()
+}
+
+// EncodeTrailer returns the XPAK segment with the trailer to append
+// to the tarball of a binary package.
+func (x XpakData) EncodeTrailer() []byte {
+	data := x.Encode()
+	buf := make([]byte, 4)
+	binary.BigEndian.PutUint32(buf, uint32(len(data)))
+	return append(append(data, buf...), []byte(XpakTrailer)...)
+}
+
+// ToMetadata converts the XPAK entries to PortageMetaData. The entries
+// CATEGORY and PF are mandatory.
+func (x XpakData) ToMetadata(opts *PortageUseParseOpts) (*PortageMetaData, error) {
+	return parseMetadataFiles(func(name string) (string, error) {
+		return string(x[name]), nil
+	}, opts)
+}
+
+// parseMetadataFiles creates the PortageMetaData from the metadata
+// files of a binary package.
+func parseMetadataFiles(read metaFileReader, opts *PortageUseParseOpts) (*PortageMetaData, error) {
+	if opts == nil {
+		opts = &PortageUseParseOpts{}
+	}
+
+	cat, err := read("CATEGORY")
+	if err != nil {
+		return nil, err
+	}
+	pf, err := read("PF")
+	if err != nil {
+		return nil, err
+	}
+	cat = strings.TrimSpace(cat)
+	pf = strings.TrimSpace(pf)
+	if cat == "" || pf == "" {
+		return nil, errors.New("Metadata without CATEGORY or PF")
+	}
+
+	gp, err := ParsePackageStr(cat + "/" + pf)
+	if err != nil {
+		return nil, errors.New("Error on parse pkgname " + err.Error())
+	}
+
+	ans := NewPortageMetaData(gp)
+	err = ans.loadFields(read, opts, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return ans, nil
+}
+
+// ParseTbz2Metadata returns the metadata of a .tbz2 binary package.
+func ParseTbz2Metadata(file string, opts *PortageUseParseOpts) (*PortageMetaData, error) {
+	x, err := ReadXpakFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return x.ToMetadata(opts)
+}
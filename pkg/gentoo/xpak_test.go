@@ -0,0 +1,98 @@
+/*
+
+Copyright (C) 2017-2021  Daniele Rondina <geaaru@sabayonlinux.org>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+*/
+
+package gentoo_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/Sabayon/pkgs-checker/pkg/gentoo"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Gentoo XPAK", func() {
+
+	xpak := XpakData{
+		"CATEGORY":                 []byte("dev-libs\n"),
+		"PF":                       []byte("openssl-1.1.1k-r1\n"),
+		"SLOT":                     []byte("0/1.1\n"),
+		"IUSE":                     []byte("+asm sslv3\n"),
+		"IUSE_EFFECTIVE":           []byte("asm sslv3\n"),
+		"USE":                      []byte("amd64 asm\n"),
+		"RDEPEND":                  []byte(">=sys-libs/zlib-1.2.8-r1\n"),
+		"LICENSE":                  []byte("openssl\n"),
+		"repository":               []byte("gentoo\n"),
+		"openssl-1.1.1k-r1.ebuild": []byte("EAPI=7\n"),
+		"environment.bz2":          []byte("BZh"),
+	}
+
+	It("Check encode format", func() {
+		data := XpakData{"A": []byte("b")}.Encode()
+		Expect(data).Should(Equal(append(append([]byte("XPAKPACK"),
+			0, 0, 0, 13, 0, 0, 0, 1,
+			0, 0, 0, 1, 'A', 0, 0, 0, 0, 0, 0, 0, 1,
+			'b'), []byte("XPAKSTOP")...)))
+	})
+
+	It("Check parse of the segment", func() {
+		x, err := ParseXpak(xpak.Encode())
+		Expect(err).Should(BeNil())
+		Expect(x).Should(Equal(xpak))
+		Expect(x.GetString("SLOT")).Should(Equal("0/1.1"))
+	})
+
+	It("Check metadata of a binary package", func() {
+		tmpdir, err := ioutil.TempDir("", "xpak")
+		Expect(err).Should(BeNil())
+		defer os.RemoveAll(tmpdir)
+
+		file := filepath.Join(tmpdir, "openssl-1.1.1k-r1.tbz2")
+		data := append([]byte("BZh91AY&SY tarball"), xpak.EncodeTrailer()...)
+		Expect(ioutil.WriteFile(file, data, 0644)).Should(BeNil())
+
+		pm, err := ParseTbz2Metadata(file, nil)
+		Expect(err).Should(BeNil())
+		Expect(pm.GetPackageNameWithVersion()).Should(Equal("dev-libs/openssl-1.1.1k-r1"))
+		Expect(pm.Slot).Should(Equal("0"))
+		Expect(pm.SubSlot).Should(Equal("1.1"))
+		Expect(pm.UseFlags).Should(Equal([]string{"asm", "-sslv3"}))
+		Expect(pm.RDEPEND).Should(Equal(">=sys-libs/zlib-1.2.8-r1"))
+		Expect(pm.License).Should(Equal("openssl"))
+		Expect(pm.Repository).Should(Equal("gentoo"))
+		Expect(pm.Ebuild).Should(Equal("EAPI=7"))
+	})
+
+	It("Check invalid data", func() {
+		_, err := ReadXpak(bytes.NewReader([]byte("tarball without trailer")))
+		Expect(err).ShouldNot(BeNil())
+
+		data := xpak.Encode()
+		_, err = ParseXpak(data[0 : len(data)-1])
+		Expect(err).ShouldNot(BeNil())
+
+		_, err = XpakData{"PF": []byte("foo-1.0")}.ToMetadata(nil)
+		Expect(err).ShouldNot(BeNil())
+	})
+
+})
@@ -0,0 +1,91 @@
+/*
+
+Copyright (C) 2017-2019  Daniele Rondina <geaaru@sabayonlinux.org>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+*/
+
+package hash
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	h "hash"
+	"sort"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+const (
+	HashAlgorithmMd5     = "md5"
+	HashAlgorithmSha256  = "sha256"
+	HashAlgorithmSha512  = "sha512"
+	HashAlgorithmBlake2b = "blake2b"
+
+	// MD5 is the default for compatibility with the existing hashfiles.
+	DefaultHashAlgorithm = HashAlgorithmMd5
+)
+
+var hashAlgorithms = map[string]func() h.Hash{
+	HashAlgorithmMd5:     md5.New,
+	HashAlgorithmSha256:  sha256.New,
+	HashAlgorithmSha512:  sha512.New,
+	HashAlgorithmBlake2b: newBlake2b,
+}
+
+// newBlake2b returns a BLAKE2b-512 hash without key.
+func newBlake2b() h.Hash {
+	// New512 fails only with a key longer than 64 bytes.
+	ans, _ := blake2b.New512(nil)
+	return ans
+}
+
+// GetHashAlgorithms returns the names of the supported algorithms.
+func GetHashAlgorithms() []string {
+	ans := []string{}
+	for k := range hashAlgorithms {
+		ans = append(ans, k)
+	}
+	sort.Strings(ans)
+	return ans
+}
+
+// NewHash returns the hash of the algorithm. An empty algorithm is
+// the default algorithm.
+func NewHash(algorithm string) (h.Hash, error) {
+	if algorithm == "" {
+		algorithm = DefaultHashAlgorithm
+	}
+	f, ok := hashAlgorithms[algorithm]
+	if !ok {
+		return nil, errors.New("Invalid hash algorithm " + algorithm +
+			". Supported: " + strings.Join(GetHashAlgorithms(), ", "))
+	}
+	return f(), nil
+}
+
+// EmptyCheckSum returns the checksum used for the packages without
+// files and directories: a string of zeros with the length of the
+// digest of the algorithm.
+func EmptyCheckSum(algorithm string) (string, error) {
+	hh, err := NewHash(algorithm)
+	if err != nil {
+		return "", err
+	}
+	return strings.Repeat("0", hh.Size()*2), nil
+}
@@ -21,8 +21,6 @@ package hash
 
 import (
 	"archive/tar"
-	"compress/bzip2"
-	"crypto/md5"
 	"encoding/hex"
 	"errors"
 	"fmt"
@@ -39,6 +37,8 @@ import (
 	viper "github.com/spf13/viper"
 
 	commons "github.com/Sabayon/pkgs-checker/pkg/commons"
+	compress "github.com/Sabayon/pkgs-checker/pkg/compress"
+	gentoo "github.com/Sabayon/pkgs-checker/pkg/gentoo"
 )
 
 type CheckerExecutor interface {
@@ -74,6 +74,12 @@ func NewChecker(settings *viper.Viper, l *logger.Logger) (*Checker, error) {
 		log = l
 	}
 
+	// Validate the algorithm before processing packages.
+	_, err := NewHash(settings.GetString("algorithm"))
+	if err != nil {
+		return nil, err
+	}
+
 	logger.Debug("Created new Checker object")
 
 	var c = &Checker{
@@ -171,40 +177,66 @@ ret:
 	return ans, nil
 }
 
-func (c *Checker) processTarBz2(pkg string, abs string) error {
+// processTarball calculates the checksum of a tarball compressed with
+// one of the codecs of the registry (.tbz2, .tar.*).
+func (c *Checker) processTarball(pkg string, abs string) error {
 
-	var p *Package
-	var tarbz2 io.Reader
 	var f, err = os.Open(abs)
 	if err != nil {
 		return err
 	}
 	defer f.Close()
 
-	tarbz2 = bzip2.NewReader(f)
-
-	// Create Package object
-	p, err = NewPackage(pkg, c.logger)
+	r, codec, err := compress.NewReader(f)
 	if err != nil {
 		return err
 	}
-	p.abspath = abs
-	p.basename = filepath.Base(pkg)
+	defer r.Close()
 
-	var tarReader = tar.NewReader(tarbz2)
+	c.logger.Debugf("[%s] Detected codec: %s", pkg, codec)
 
-	for {
-		header, err := tarReader.Next()
+	return c.processTarEntries(pkg, abs, func(fn func(*tar.Header, io.Reader) error) error {
+		var tarReader = tar.NewReader(r)
 
-		if err == io.EOF {
-			err = nil
-			break
-		}
+		for {
+			header, err := tarReader.Next()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
 
-		if err != nil {
-			return err
+			err = fn(header, tarReader)
+			if err != nil {
+				return err
+			}
 		}
+	})
+}
+
+func (c *Checker) processGpkg(pkg string, abs string) error {
+	return c.processTarEntries(pkg, abs, func(fn func(*tar.Header, io.Reader) error) error {
+		return gentoo.WalkGpkgImage(abs, fn)
+	})
+}
+
+// processTarEntries calculates the checksum of the package with the
+// entries of the tarball returned by the walk function.
+func (c *Checker) processTarEntries(pkg string, abs string,
+	walk func(fn func(*tar.Header, io.Reader) error) error) error {
+
+	// Create Package object
+	p, err := NewPackageWithAlgorithm(pkg, c.settings.GetString("algorithm"),
+		c.logger)
+	if err != nil {
+		return err
+	}
+	p.abspath = abs
+	p.basename = filepath.Base(pkg)
 
+	err = walk(func(header *tar.Header, r io.Reader) error {
+		var err error
 		var isDir = false
 		var toSkip = false
 
@@ -215,7 +247,7 @@ func (c *Checker) processTarBz2(pkg string, abs string) error {
 			toSkip, err = c.is2SkipFile(pkg, header.Name)
 
 			if toSkip == false && err == nil {
-				err = p.ProcessTarFile(tarReader, header.Name)
+				err = p.ProcessTarFile(r, header.Name)
 			} else if toSkip {
 				p.skipped++
 			}
@@ -224,15 +256,37 @@ func (c *Checker) processTarBz2(pkg string, abs string) error {
 			return err
 		}
 
+		if c.settings.GetString("manifest") != "" && !toSkip {
+			e := NewManifestEntry(pkg, header)
+			if e.Type == ManifestTypeFile {
+				e.Algorithm = p.algorithm
+				e.Digest = hex.EncodeToString(p.files[header.Name])
+			}
+			p.AddManifestEntry(e)
+		}
+
 		c.logger.Debugf("[%s] File %s (dir = %t, skip = %t).", pkg, header.Name,
 			isDir, toSkip)
 
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
 	err = p.CalculateCRC()
-	if strings.Compare(p.checksum, commons.PKGS_CHECKER_EMPTY_PKGHASH) == 0 &&
+	if err != nil {
+		return err
+	}
+
+	emptyHash, _ := EmptyCheckSum(p.algorithm)
+	if strings.Compare(p.checksum, emptyHash) == 0 &&
 		c.settings.GetBool("hash-empty") {
-		var fake_hash h.Hash = md5.New()
+		var fake_hash h.Hash
+		fake_hash, err = NewHash(p.algorithm)
+		if err != nil {
+			return err
+		}
 		var h []byte = fake_hash.Sum(nil)
 		p.checksum = hex.EncodeToString(h)
 	}
@@ -276,8 +330,14 @@ func (c *Checker) processPackage(pkg string) error {
 
 	extension = filepath.Ext(absp)
 
-	if extension == ".tbz2" || strings.HasSuffix(filepath.Base(pkg), ".tar.bz2") {
-		err = c.processTarBz2(pkgname, absp)
+	if gentoo.IsGpkg(absp) {
+		err = c.processGpkg(pkgname, absp)
+		if err != nil {
+			c.logger.Errorf("[%s] Error: %s", pkgname, err)
+			return err
+		}
+	} else if gentoo.IsBinPkg(absp) || strings.Contains(filepath.Base(pkg), ".tar") {
+		err = c.processTarball(pkgname, absp)
 		if err != nil {
 			c.logger.Errorf("[%s] Error: %s", pkgname, err)
 			return err
@@ -349,8 +409,7 @@ func (c *Checker) findPackages(dir string) ([]string, error) {
 				ans = append(ans, childFile)
 			}
 		} else {
-			var ext string = filepath.Ext(f.Name())
-			if ext == ".tbz2" {
+			if gentoo.IsBinPkg(f.Name()) {
 				ans = append(ans, fmt.Sprintf("%s/%s", dir, f.Name()))
 			}
 		}
@@ -392,7 +451,7 @@ func (c *Checker) Run() error {
 		}
 	}
 
-	// Elaborate .tbz2 file under directory
+	// Elaborate .tbz2 and .gpkg.tar files under directory
 	if c.settings.GetString("directory") != "" {
 		err = c.processDirectory(c.settings.GetString("directory"))
 	}
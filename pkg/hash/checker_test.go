@@ -0,0 +1,127 @@
+/*
+
+Copyright (C) 2017-2021  Daniele Rondina <geaaru@sabayonlinux.org>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+*/
+
+package hash_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/Sabayon/pkgs-checker/pkg/hash"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	logger "github.com/sirupsen/logrus"
+	viper "github.com/spf13/viper"
+)
+
+func createTar(prefix string, dirs []string, files map[string]string) []byte {
+	var buf bytes.Buffer
+	w := tar.NewWriter(&buf)
+	for _, d := range dirs {
+		Expect(w.WriteHeader(&tar.Header{
+			Name: prefix + d, Mode: 0755, Typeflag: tar.TypeDir,
+		})).Should(BeNil())
+	}
+	for _, name := range []string{"usr/bin/foo", "usr/share/doc/foo/README"} {
+		Expect(w.WriteHeader(&tar.Header{
+			Name: prefix + name, Mode: 0644, Size: int64(len(files[name])),
+		})).Should(BeNil())
+		w.Write([]byte(files[name]))
+	}
+	Expect(w.Close()).Should(BeNil())
+	return buf.Bytes()
+}
+
+var _ = Describe("Checker", func() {
+
+	var tmpdir string
+
+	BeforeEach(func() {
+		var err error
+		tmpdir, err = ioutil.TempDir("", "checker")
+		Expect(err).Should(BeNil())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tmpdir)
+	})
+
+	Context("Binary package formats", func() {
+		It("Have the same checksum for the same image", func() {
+			dirs := []string{"", "usr/", "usr/bin/", "usr/share/doc/foo/"}
+			files := map[string]string{
+				"usr/bin/foo":              "#!/bin/sh\n",
+				"usr/share/doc/foo/README": "foo\n",
+			}
+
+			var gz bytes.Buffer
+			w := gzip.NewWriter(&gz)
+			w.Write(createTar("./", dirs, files))
+			Expect(w.Close()).Should(BeNil())
+
+			var gpkg bytes.Buffer
+			tw := tar.NewWriter(&gpkg)
+			for name, data := range map[string][]byte{
+				"foo-1.0/gpkg-1":    {},
+				"foo-1.0/image.tar": createTar("image/", dirs, files),
+			} {
+				Expect(tw.WriteHeader(&tar.Header{
+					Name: name, Mode: 0644, Size: int64(len(data)),
+				})).Should(BeNil())
+				tw.Write(data)
+			}
+			Expect(tw.Close()).Should(BeNil())
+
+			Expect(os.MkdirAll(filepath.Join(tmpdir, "tbz2"), 0755)).Should(BeNil())
+			Expect(os.MkdirAll(filepath.Join(tmpdir, "gpkg"), 0755)).Should(BeNil())
+			Expect(ioutil.WriteFile(filepath.Join(tmpdir, "tbz2", "foo-1.0.tbz2"),
+				gz.Bytes(), 0644)).Should(BeNil())
+			Expect(ioutil.WriteFile(filepath.Join(tmpdir, "gpkg", "foo-1.0.gpkg.tar"),
+				gpkg.Bytes(), 0644)).Should(BeNil())
+
+			s := viper.New()
+			s.Set("directory", tmpdir)
+			s.Set("ignoreFiles", []string{"./usr/share/doc/foo/README"})
+			l := logger.New()
+			l.Out = ioutil.Discard
+			c, err := NewChecker(s, l)
+			Expect(err).Should(BeNil())
+			Expect(c.Run()).Should(BeNil())
+
+			pkgs := c.GetPackages()
+			Expect(len(pkgs)).Should(Equal(2))
+			Expect(pkgs[0].Name()).Should(Equal("gpkg/foo-1.0.gpkg.tar"))
+			Expect(pkgs[1].Name()).Should(Equal("tbz2/foo-1.0.tbz2"))
+			Expect(pkgs[0].CheckSum()).Should(Equal(pkgs[1].CheckSum()))
+
+			// The ignored path is matched on both formats.
+			s.Set("ignoreFiles", []string{})
+			c, err = NewChecker(s, l)
+			Expect(err).Should(BeNil())
+			Expect(c.Run()).Should(BeNil())
+			Expect(c.GetPackages()[0].CheckSum()).ShouldNot(Equal(pkgs[0].CheckSum()))
+		})
+	})
+
+})
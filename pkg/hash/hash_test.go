@@ -0,0 +1,169 @@
+/*
+
+Copyright (C) 2017-2021  Daniele Rondina <geaaru@sabayonlinux.org>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package hash_test
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/Sabayon/pkgs-checker/pkg/hash"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	viper "github.com/spf13/viper"
+)
+
+var _ = Describe("Hash Algorithms", func() {
+
+	DescribeTable("Digests",
+		func(algorithm, data, expected string) {
+			hh, err := NewHash(algorithm)
+			Expect(err).Should(BeNil())
+			hh.Write([]byte(data))
+			Expect(hex.EncodeToString(hh.Sum(nil))).Should(Equal(expected))
+		},
+		Entry("md5 by default", "", "abc",
+			"900150983cd24fb0d6963f7d28e17f72"),
+		Entry("sha256", HashAlgorithmSha256, "abc",
+			"ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"),
+		Entry("blake2b empty", HashAlgorithmBlake2b, "",
+			"786a02f742015903c6c6fd852552d272912f4740e15847618a86e217f71f5419"+
+				"d25e1031afee585313896444934eb04b903a685b1448b755d56f701afe9be2ce"),
+		Entry("blake2b abc", HashAlgorithmBlake2b, "abc",
+			"ba80a53f981c4d0d6a2797b69f12f6e94c212f14685ac4b74b12bb6fdbffa2d1"+
+				"7d87c5392aab792dc252d5de4533cc9518d38aa8dbf1925ab92386edd4009923"),
+		Entry("blake2b two blocks", HashAlgorithmBlake2b, strings.Repeat("y", 129),
+			"b5a49bd30a88f4b0a5c36d2d57c3a550e88d6884c99802aba1a1d70b1c804057"+
+				"b3a4188074287fcb322f91d7d54bee3f8fa77b9594b377391a63936109f4d042"),
+	)
+
+	Context("Invalid algorithm", func() {
+		It("Returns an error", func() {
+			_, err := NewHash("crc32")
+			Expect(err).ShouldNot(BeNil())
+		})
+	})
+
+	Context("Empty checksum", func() {
+		It("Has the length of the digest", func() {
+			sum, err := EmptyCheckSum(HashAlgorithmSha512)
+			Expect(err).Should(BeNil())
+			Expect(sum).Should(Equal(strings.Repeat("0", 128)))
+		})
+	})
+
+	Context("Package checksum", func() {
+		It("Uses the algorithm of the package", func() {
+			p, err := NewPackageWithAlgorithm("test-1.tbz2", HashAlgorithmSha256, nil)
+			Expect(err).Should(BeNil())
+			Expect(p.ProcessTarFile(strings.NewReader("abc"), "usr/bin/test")).Should(BeNil())
+			Expect(p.CalculateCRC()).Should(BeNil())
+			Expect(len(p.CheckSum())).Should(Equal(64))
+		})
+	})
+})
+
+var _ = Describe("Hashfile Header", func() {
+
+	newSettings := func(algorithm string, files, exts []string) *viper.Viper {
+		s := viper.New()
+		s.Set("algorithm", algorithm)
+		s.Set("ignoreFiles", files)
+		s.Set("ignoreExt", exts)
+		return s
+	}
+
+	Context("Format", func() {
+		It("Parses the written header", func() {
+			header := NewHashfileHeader(newSettings(HashAlgorithmBlake2b,
+				[]string{"usr/share/a b", "etc/c"}, []string{".pyc", ".mo"}))
+			Expect(header.String()).Should(Equal(
+				"# pkgs-checker-hashfile v1 algorithm=blake2b " +
+					"ignore-files=etc%2Fc,usr%2Fshare%2Fa+b ignore-extensions=.mo,.pyc"))
+
+			parsed, err := ParseHashfileHeader(header.String())
+			Expect(err).Should(BeNil())
+			Expect(parsed).Should(Equal(header))
+		})
+
+		It("Rejects an unknown version", func() {
+			_, err := ParseHashfileHeader("# pkgs-checker-hashfile v9 algorithm=md5")
+			Expect(err).ShouldNot(BeNil())
+		})
+	})
+
+	Context("Check hashfile", func() {
+		var dir string
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "hashfile")
+			Expect(err).Should(BeNil())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		It("Requires the header on a new hashfile", func() {
+			header := NewHashfileHeader(newSettings("", nil, nil))
+			write, err := header.CheckHashfile(filepath.Join(dir, "hashes"))
+			Expect(err).Should(BeNil())
+			Expect(write).Should(BeTrue())
+		})
+
+		It("Rejects a different configuration", func() {
+			f := filepath.Join(dir, "hashes")
+			header := NewHashfileHeader(newSettings(HashAlgorithmMd5, nil, []string{".pyc"}))
+			Expect(ioutil.WriteFile(f, []byte(header.String()+"\n"), 0644)).Should(BeNil())
+
+			write, err := header.CheckHashfile(f)
+			Expect(err).Should(BeNil())
+			Expect(write).Should(BeFalse())
+
+			other := NewHashfileHeader(newSettings(HashAlgorithmMd5, nil, nil))
+			_, err = other.CheckHashfile(f)
+			Expect(err).ShouldNot(BeNil())
+		})
+
+		It("Accepts legacy hashfiles only with md5 and without ignore rules", func() {
+			f := filepath.Join(dir, "hashes")
+			Expect(ioutil.WriteFile(f,
+				[]byte("2047177094c0ed552b83110ff87bfc63 p-1.tbz2\n"), 0644)).Should(BeNil())
+
+			write, err := NewHashfileHeader(newSettings(HashAlgorithmMd5, nil, nil)).CheckHashfile(f)
+			Expect(err).Should(BeNil())
+			Expect(write).Should(BeFalse())
+
+			_, err = NewHashfileHeader(newSettings(HashAlgorithmSha256, nil, nil)).CheckHashfile(f)
+			Expect(err).ShouldNot(BeNil())
+
+			_, err = NewHashfileHeader(newSettings(HashAlgorithmMd5,
+				[]string{"etc/c"}, nil)).CheckHashfile(f)
+			Expect(err).ShouldNot(BeNil())
+			_, err = NewHashfileHeader(newSettings(HashAlgorithmMd5,
+				nil, []string{".pyc"})).CheckHashfile(f)
+			Expect(err).ShouldNot(BeNil())
+		})
+	})
+})
@@ -0,0 +1,202 @@
+/*
+
+Copyright (C) 2017-2019  Daniele Rondina <geaaru@sabayonlinux.org>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+*/
+
+package hash
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	viper "github.com/spf13/viper"
+)
+
+const (
+	HashfileHeaderPrefix  = "# pkgs-checker-hashfile"
+	HashfileFormatVersion = "v1"
+)
+
+// HashfileHeader describes the configuration used to generate the
+// checksums of an hashfile. Checksums generated with a different
+// header must not be compared.
+type HashfileHeader struct {
+	Version          string
+	Algorithm        string
+	IgnoreFiles      []string
+	IgnoreExtensions []string
+}
+
+func NewHashfileHeader(settings *viper.Viper) *HashfileHeader {
+	ans := &HashfileHeader{
+		Version:          HashfileFormatVersion,
+		Algorithm:        settings.GetString("algorithm"),
+		IgnoreFiles:      append([]string{}, settings.GetStringSlice("ignoreFiles")...),
+		IgnoreExtensions: append([]string{}, settings.GetStringSlice("ignoreExt")...),
+	}
+	if ans.Algorithm == "" {
+		ans.Algorithm = DefaultHashAlgorithm
+	}
+	sort.Strings(ans.IgnoreFiles)
+	sort.Strings(ans.IgnoreExtensions)
+	return ans
+}
+
+func IsHashfileHeader(line string) bool {
+	return strings.HasPrefix(line, HashfileHeaderPrefix+" ")
+}
+
+func ParseHashfileHeader(line string) (*HashfileHeader, error) {
+	if !IsHashfileHeader(line) {
+		return nil, errors.New("Invalid hashfile header: " + line)
+	}
+
+	fields := strings.Fields(strings.TrimPrefix(line, HashfileHeaderPrefix))
+	if len(fields) == 0 {
+		return nil, errors.New("Hashfile header without version")
+	}
+	ans := &HashfileHeader{
+		Version:          fields[0],
+		IgnoreFiles:      []string{},
+		IgnoreExtensions: []string{},
+	}
+	if ans.Version != HashfileFormatVersion {
+		return nil, errors.New(fmt.Sprintf(
+			"Unsupported hashfile format %s", ans.Version))
+	}
+
+	for _, f := range fields[1:] {
+		kv := strings.SplitN(f, "=", 2)
+		if len(kv) != 2 {
+			return nil, errors.New(fmt.Sprintf(
+				"Invalid hashfile header field %s", f))
+		}
+
+		switch kv[0] {
+		case "algorithm":
+			ans.Algorithm = kv[1]
+		case "ignore-files":
+			values, err := parseHeaderList(kv[1])
+			if err != nil {
+				return nil, err
+			}
+			ans.IgnoreFiles = values
+		case "ignore-extensions":
+			values, err := parseHeaderList(kv[1])
+			if err != nil {
+				return nil, err
+			}
+			ans.IgnoreExtensions = values
+		default:
+			return nil, errors.New(fmt.Sprintf(
+				"Unknown hashfile header field %s", kv[0]))
+		}
+	}
+
+	if ans.Algorithm == "" {
+		return nil, errors.New("Hashfile header without algorithm")
+	}
+
+	return ans, nil
+}
+
+func (h *HashfileHeader) String() string {
+	return fmt.Sprintf("%s %s algorithm=%s ignore-files=%s ignore-extensions=%s",
+		HashfileHeaderPrefix, h.Version, h.Algorithm,
+		formatHeaderList(h.IgnoreFiles), formatHeaderList(h.IgnoreExtensions))
+}
+
+func (h *HashfileHeader) Equal(o *HashfileHeader) bool {
+	return o != nil && h.String() == o.String()
+}
+
+// CheckHashfile validates the header of an existing hashfile against
+// the header of the current configuration. It returns true if the
+// hashfile is missing or empty and the header must be written.
+// A legacy hashfile without header is accepted only with the default
+// algorithm and without ignore rules, because its rules are unknown.
+func (h *HashfileHeader) CheckHashfile(file string) (bool, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return true, nil
+		}
+		return false, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	if !scanner.Scan() {
+		return true, scanner.Err()
+	}
+	line := scanner.Text()
+
+	if !IsHashfileHeader(line) {
+		if h.Algorithm != DefaultHashAlgorithm {
+			return false, errors.New(fmt.Sprintf(
+				"Hashfile %s without header is generated with algorithm %s and not %s",
+				file, DefaultHashAlgorithm, h.Algorithm))
+		}
+		if len(h.IgnoreFiles) > 0 || len(h.IgnoreExtensions) > 0 {
+			return false, errors.New(fmt.Sprintf(
+				"Hashfile %s without header can't be extended with ignore rules",
+				file))
+		}
+		return false, nil
+	}
+
+	header, err := ParseHashfileHeader(line)
+	if err != nil {
+		return false, err
+	}
+	if !h.Equal(header) {
+		return false, errors.New(fmt.Sprintf(
+			"Hashfile %s has a different configuration: %s",
+			file, line))
+	}
+
+	return false, nil
+}
+
+func formatHeaderList(values []string) string {
+	escaped := make([]string, len(values))
+	for idx, v := range values {
+		escaped[idx] = url.QueryEscape(v)
+	}
+	return strings.Join(escaped, ",")
+}
+
+func parseHeaderList(value string) ([]string, error) {
+	ans := []string{}
+	if value == "" {
+		return ans, nil
+	}
+	for _, v := range strings.Split(value, ",") {
+		s, err := url.QueryUnescape(v)
+		if err != nil {
+			return nil, err
+		}
+		ans = append(ans, s)
+	}
+	sort.Strings(ans)
+	return ans, nil
+}
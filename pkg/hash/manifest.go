@@ -0,0 +1,206 @@
+/*
+
+Copyright (C) 2017-2019  Daniele Rondina <geaaru@sabayonlinux.org>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+*/
+
+package hash
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	ManifestFormatJsonLines = "jsonl"
+	ManifestFormatManifest  = "manifest"
+
+	ManifestTypeFile     = "file"
+	ManifestTypeDir      = "dir"
+	ManifestTypeSymlink  = "symlink"
+	ManifestTypeHardlink = "hardlink"
+	ManifestTypeChar     = "char"
+	ManifestTypeBlock    = "block"
+	ManifestTypeFifo     = "fifo"
+	ManifestTypeOther    = "other"
+)
+
+// ManifestEntry describes a file of a package. The digest is available
+// only for the regular files.
+type ManifestEntry struct {
+	Package    string `json:"package"`
+	Path       string `json:"path"`
+	Type       string `json:"type"`
+	Size       int64  `json:"size"`
+	Mode       string `json:"mode"`
+	Uid        int    `json:"uid"`
+	Gid        int    `json:"gid"`
+	Owner      string `json:"owner"`
+	LinkTarget string `json:"link,omitempty"`
+	Algorithm  string `json:"algorithm,omitempty"`
+	Digest     string `json:"digest,omitempty"`
+}
+
+type ManifestEntrySorter []ManifestEntry
+
+func (m ManifestEntrySorter) Len() int           { return len(m) }
+func (m ManifestEntrySorter) Swap(i, j int)      { m[i], m[j] = m[j], m[i] }
+func (m ManifestEntrySorter) Less(i, j int) bool { return m[i].Path < m[j].Path }
+
+func GetManifestFormats() []string {
+	return []string{ManifestFormatJsonLines, ManifestFormatManifest}
+}
+
+func IsManifestFormat(format string) bool {
+	for _, f := range GetManifestFormats() {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+func NewManifestEntry(pkg string, header *tar.Header) *ManifestEntry {
+	ans := &ManifestEntry{
+		Package: pkg,
+		Path:    header.Name,
+		Type:    getManifestType(header.Typeflag),
+		Size:    header.Size,
+		Mode:    fmt.Sprintf("%04o", header.Mode&07777),
+		Uid:     header.Uid,
+		Gid:     header.Gid,
+	}
+
+	uname := header.Uname
+	if uname == "" {
+		uname = fmt.Sprintf("%d", header.Uid)
+	}
+	gname := header.Gname
+	if gname == "" {
+		gname = fmt.Sprintf("%d", header.Gid)
+	}
+	ans.Owner = uname + ":" + gname
+
+	if ans.Type == ManifestTypeSymlink || ans.Type == ManifestTypeHardlink {
+		ans.LinkTarget = header.Linkname
+	}
+
+	return ans
+}
+
+func getManifestType(typeflag byte) string {
+	switch typeflag {
+	case tar.TypeReg, tar.TypeRegA:
+		return ManifestTypeFile
+	case tar.TypeDir:
+		return ManifestTypeDir
+	case tar.TypeSymlink:
+		return ManifestTypeSymlink
+	case tar.TypeLink:
+		return ManifestTypeHardlink
+	case tar.TypeChar:
+		return ManifestTypeChar
+	case tar.TypeBlock:
+		return ManifestTypeBlock
+	case tar.TypeFifo:
+		return ManifestTypeFifo
+	default:
+		return ManifestTypeOther
+	}
+}
+
+// ManifestLine returns the entry with the syntax of the Portage Manifest
+// files: <TYPE> <path> <size> [<ALGO> <digest>] followed by the
+// MODE, OWNER and LINK fields.
+func (e *ManifestEntry) ManifestLine() string {
+	fields := []string{
+		strings.ToUpper(e.Type),
+		escapeManifestField(e.Path),
+		fmt.Sprintf("%d", e.Size),
+	}
+
+	if e.Digest != "" {
+		fields = append(fields, strings.ToUpper(e.Algorithm), e.Digest)
+	}
+
+	fields = append(fields, "MODE", e.Mode, "OWNER", escapeManifestField(e.Owner))
+	if e.LinkTarget != "" {
+		fields = append(fields, "LINK", escapeManifestField(e.LinkTarget))
+	}
+
+	return strings.Join(fields, " ")
+}
+
+// escapeManifestField escapes the whitespaces and the backslash with
+// the \xHH syntax used by the Manifest files.
+func escapeManifestField(s string) string {
+	var b strings.Builder
+	for _, c := range []byte(s) {
+		if c <= ' ' || c == '\\' || c == 0x7f {
+			b.WriteString(fmt.Sprintf("\\x%02X", c))
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// WriteManifest writes the files of the packages with the selected
+// format. With the manifest format every package is introduced by
+// a PACKAGE line with the checksum of the package.
+func WriteManifest(w io.Writer, pkgs []Package, format string) error {
+	var err error
+
+	if !IsManifestFormat(format) {
+		return errors.New(fmt.Sprintf("Invalid manifest format %s. Supported: %s",
+			format, strings.Join(GetManifestFormats(), ", ")))
+	}
+
+	enc := json.NewEncoder(w)
+
+	for _, p := range pkgs {
+		// Skip package in errors from file
+		if p.CheckSum() == "" {
+			continue
+		}
+
+		if format == ManifestFormatManifest {
+			_, err = fmt.Fprintf(w, "PACKAGE %s %s %s\n",
+				escapeManifestField(p.Name()), strings.ToUpper(p.Algorithm()),
+				p.CheckSum())
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, e := range p.GetManifest() {
+			if format == ManifestFormatJsonLines {
+				err = enc.Encode(e)
+			} else {
+				_, err = fmt.Fprintln(w, e.ManifestLine())
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
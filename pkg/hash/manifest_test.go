@@ -0,0 +1,106 @@
+/*
+
+Copyright (C) 2017-2021  Daniele Rondina <geaaru@sabayonlinux.org>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+*/
+package hash_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	. "github.com/Sabayon/pkgs-checker/pkg/hash"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Manifest", func() {
+
+	newPackage := func() *Package {
+		p, err := NewPackageWithAlgorithm("app-misc/test-1.tbz2", HashAlgorithmSha256, nil)
+		Expect(err).Should(BeNil())
+
+		file := &tar.Header{Name: "usr/bin/test", Typeflag: tar.TypeReg, Size: 3,
+			Mode: 0104755, Uname: "root", Gname: "wheel"}
+		Expect(p.ProcessTarFile(strings.NewReader("abc"), file.Name)).Should(BeNil())
+		e := NewManifestEntry(p.Name(), file)
+		e.Algorithm = p.Algorithm()
+		e.Digest = "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"
+		p.AddManifestEntry(e)
+
+		p.AddManifestEntry(NewManifestEntry(p.Name(), &tar.Header{
+			Name: "usr/bin/my test", Typeflag: tar.TypeSymlink, Linkname: "test",
+			Mode: 0777, Uid: 250, Gid: 250}))
+
+		Expect(p.CalculateCRC()).Should(BeNil())
+		return p
+	}
+
+	Context("Entries", func() {
+		It("Are sorted by path", func() {
+			entries := newPackage().GetManifest()
+			Expect(len(entries)).Should(Equal(2))
+			Expect(entries[0].Path).Should(Equal("usr/bin/my test"))
+			Expect(entries[0].Type).Should(Equal(ManifestTypeSymlink))
+			Expect(entries[0].LinkTarget).Should(Equal("test"))
+			Expect(entries[0].Owner).Should(Equal("250:250"))
+			Expect(entries[1].Mode).Should(Equal("4755"))
+		})
+	})
+
+	Context("Manifest format", func() {
+		It("Writes Manifest-style lines", func() {
+			var buf bytes.Buffer
+			p := newPackage()
+			Expect(WriteManifest(&buf, []Package{*p}, ManifestFormatManifest)).Should(BeNil())
+			Expect(strings.Split(buf.String(), "\n")).Should(Equal([]string{
+				"PACKAGE app-misc/test-1.tbz2 SHA256 " + p.CheckSum(),
+				"SYMLINK usr/bin/my\\x20test 0 MODE 0777 OWNER 250:250 LINK test",
+				"FILE usr/bin/test 3 SHA256 " +
+					"ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad" +
+					" MODE 4755 OWNER root:wheel",
+				"",
+			}))
+		})
+	})
+
+	Context("JSON Lines format", func() {
+		It("Writes an entry for line", func() {
+			var buf bytes.Buffer
+			Expect(WriteManifest(&buf, []Package{*newPackage()},
+				ManifestFormatJsonLines)).Should(BeNil())
+
+			lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+			Expect(len(lines)).Should(Equal(2))
+
+			var e ManifestEntry
+			Expect(json.Unmarshal([]byte(lines[1]), &e)).Should(BeNil())
+			Expect(e.Package).Should(Equal("app-misc/test-1.tbz2"))
+			Expect(e.Size).Should(Equal(int64(3)))
+			Expect(e.Algorithm).Should(Equal(HashAlgorithmSha256))
+		})
+	})
+
+	Context("Invalid format", func() {
+		It("Returns an error", func() {
+			var buf bytes.Buffer
+			Expect(WriteManifest(&buf, []Package{}, "xml")).ShouldNot(BeNil())
+		})
+	})
+})
@@ -19,8 +19,6 @@ along with this program. If not, see <http://www.gnu.org/licenses/>.
 package hash
 
 import (
-	"archive/tar"
-	"crypto/md5"
 	"encoding/hex"
 	"errors"
 	"fmt"
@@ -29,21 +27,21 @@ import (
 	"sort"
 
 	logger "github.com/sirupsen/logrus"
-
-	commons "github.com/Sabayon/pkgs-checker/pkg/commons"
 )
 
-const BYTE_BUFFER_LEN = 100
+const BYTE_BUFFER_LEN = 32 * 1024
 
 type Package struct {
-	pkg      string
-	basename string
-	abspath  string
-	checksum string
-	files    map[string][]byte
-	dirs     []string
-	skipped  int
-	logger   *logger.Logger
+	pkg       string
+	basename  string
+	abspath   string
+	checksum  string
+	algorithm string
+	files     map[string][]byte
+	dirs      []string
+	manifest  []ManifestEntry
+	skipped   int
+	logger    *logger.Logger
 }
 
 type PackageSorter []Package
@@ -62,10 +60,28 @@ func NewPackage(pkg string, l *logger.Logger) (*Package, error) {
 		log = l
 	}
 
-	return &Package{pkg: pkg, skipped: 0,
+	return &Package{pkg: pkg, skipped: 0, algorithm: DefaultHashAlgorithm,
 		logger: log, files: make(map[string][]byte)}, nil
 }
 
+func NewPackageWithAlgorithm(pkg, algorithm string, l *logger.Logger) (*Package, error) {
+	p, err := NewPackage(pkg, l)
+	if err != nil {
+		return nil, err
+	}
+
+	if algorithm != "" {
+		// Validate the algorithm before processing files.
+		_, err = NewHash(algorithm)
+		if err != nil {
+			return nil, err
+		}
+		p.algorithm = algorithm
+	}
+
+	return p, nil
+}
+
 func (p *Package) AddFile(f string, hash []byte) {
 	p.files[f] = hash
 }
@@ -74,6 +90,17 @@ func (p *Package) AddDir(d string) {
 	p.dirs = append(p.dirs, d)
 }
 
+func (p *Package) AddManifestEntry(e *ManifestEntry) {
+	p.manifest = append(p.manifest, *e)
+}
+
+// GetManifest returns the entries of the package sorted by path.
+func (p *Package) GetManifest() []ManifestEntry {
+	ans := append([]ManifestEntry{}, p.manifest...)
+	sort.Sort(ManifestEntrySorter(ans))
+	return ans
+}
+
 func (p *Package) Name() string {
 	return p.pkg
 }
@@ -82,6 +109,10 @@ func (p *Package) CheckSum() string {
 	return p.checksum
 }
 
+func (p *Package) Algorithm() string {
+	return p.algorithm
+}
+
 func (p *Package) String() string {
 	return fmt.Sprintf(
 		`Package: {
@@ -89,60 +120,53 @@ func (p *Package) String() string {
 	basename: %s,
 	abspath: %s,
 	checksum: %s,
+	algorithm: %s,
 	files: %d,
 	dirs: %d,
 	skipped: %d
 }`,
-		p.pkg, p.basename, p.abspath, p.checksum, len(p.files), len(p.dirs), p.skipped)
+		p.pkg, p.basename, p.abspath, p.checksum, p.algorithm, len(p.files), len(p.dirs), p.skipped)
 }
 
-func (p *Package) ProcessTarFile(tarReader *tar.Reader, name string) error {
-
-	// Read file
-	var err error
-	var buf []byte = make([]byte, BYTE_BUFFER_LEN)
-	var pb []byte
-	var n_bytes int
-	var fmd5 h.Hash = md5.New()
-
-	for {
-		n_bytes, err = tarReader.Read(buf)
-
-		if n_bytes > 0 {
-			//
-			pb = buf[0:n_bytes]
-			fmd5.Write(pb)
-		}
+func (p *Package) ProcessTarFile(tarReader io.Reader, name string) error {
 
-		if err == io.EOF {
-			err = nil
-			break
-		} else if err != nil {
-			return err
-		}
+	fhash, err := NewHash(p.algorithm)
+	if err != nil {
+		return err
+	}
 
+	// Read file
+	_, err = io.CopyBuffer(fhash, tarReader, make([]byte, BYTE_BUFFER_LEN))
+	if err != nil {
+		return err
 	}
 
-	var h []byte = fmd5.Sum(nil)
-	p.logger.Debugf("[%s] %s - MD5 (without dirs): %s",
-		p.basename, name, hex.EncodeToString(h))
+	var h []byte = fhash.Sum(nil)
+	p.logger.Debugf("[%s] %s - %s (without dirs): %s",
+		p.basename, name, p.algorithm, hex.EncodeToString(h))
 
 	p.AddFile(name, h)
 
-	return err
+	return nil
 }
 
 func (p *Package) CalculateCRC() error {
 
-	var pmd5 h.Hash = md5.New()
+	var phash h.Hash
+	var err error
 
 	if len(p.files) == 0 && len(p.dirs) == 0 {
 		p.logger.Warnf("[%s] No directories or files found for CRC.\n", p.pkg)
-		p.checksum = commons.PKGS_CHECKER_EMPTY_PKGHASH
-		return nil
+		p.checksum, err = EmptyCheckSum(p.algorithm)
+		return err
+	}
+
+	phash, err = NewHash(p.algorithm)
+	if err != nil {
+		return err
 	}
 
-	// Create MD5 with MD5 of all files
+	// Create the hash with the hashes of all files
 	if len(p.files) > 0 {
 		var files []string
 
@@ -152,20 +176,20 @@ func (p *Package) CalculateCRC() error {
 
 		sort.Strings(files)
 		for _, k := range files {
-			pmd5.Write(p.files[k])
+			phash.Write(p.files[k])
 		}
 	}
 
-	// Append to MD5 list of directories
+	// Append to the hash the list of directories
 	if len(p.dirs) > 0 {
 
 		sort.Strings(p.dirs)
 		for _, k := range p.dirs {
-			pmd5.Write([]byte(k))
+			phash.Write([]byte(k))
 		}
 	}
 
-	var h []byte = pmd5.Sum(nil)
+	var h []byte = phash.Sum(nil)
 	p.checksum = hex.EncodeToString(h)
 
 	return nil
@@ -75,11 +75,6 @@ func ConvertPortageMeta2PortageConverter(pkgs []*gentoo.PortageMetaData, treePat
 		}
 
 		for _, p := range pp {
-			// Ignoring sub slot
-			if strings.Contains(p.Slot, "/") {
-				p.Slot = p.Slot[0:strings.Index(p.Slot, "/")]
-			}
-
 			artefact.Packages = append(artefact.Packages, p.GetPackageNameWithSlot())
 		}
 
@@ -27,7 +27,6 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
-	"path/filepath"
 	"sort"
 	"strings"
 
@@ -36,6 +35,7 @@ import (
 	"github.com/Sabayon/pkgs-checker/pkg/binhostdir"
 	commons "github.com/Sabayon/pkgs-checker/pkg/commons"
 	entropy "github.com/Sabayon/pkgs-checker/pkg/entropy"
+	gentoo "github.com/Sabayon/pkgs-checker/pkg/gentoo"
 )
 
 type PkgListReport struct {
@@ -120,6 +120,9 @@ func PkgListConvertToMap(pkgs []string) (map[string][]entropy.EntropyPackage, er
 	return ans, nil
 }
 
+// PkgListIntersect returns the names of the packages present in both
+// the lists. The blockers (!, !< and !>) don't declare a package and
+// they are ignored.
 func PkgListIntersect(list1Map, list2Map map[string][]entropy.EntropyPackage) []string {
 	ans := make([]string, 0)
 	mpkgs := make(map[string]bool, 0)
@@ -130,7 +133,13 @@ func PkgListIntersect(list1Map, list2Map map[string][]entropy.EntropyPackage) []
 			continue
 		} else {
 			for _, pkg := range pkgs {
+				if pkg.IsBlocker() {
+					continue
+				}
 				for _, pkg2 := range pkgs2 {
+					if pkg2.IsBlocker() {
+						continue
+					}
 					if pkg.OfPackage(pkg2.GentooPackage) {
 						mpkgs[pkg.GetPackageName()] = true
 						logger.Debugf("pkg %s (%s) duplicated.",
@@ -163,6 +172,9 @@ func PkgListWithoutVersions(list []string) ([]string, error) {
 
 	for _, pkgs := range list1Map {
 		for _, pkg := range pkgs {
+			if pkg.IsBlocker() {
+				continue
+			}
 			m[pkg.GetPackageName()] = true
 		}
 	}
@@ -187,6 +199,9 @@ func PkgListWithSlot(list []string, withSlotZero bool) ([]string, error) {
 
 	for _, pkgs := range list1Map {
 		for _, pkg := range pkgs {
+			if pkg.IsBlocker() {
+				continue
+			}
 			slot = ""
 			if pkg.Slot != "" && (withSlotZero || pkg.Slot != "0") {
 				slot = pkg.Slot
@@ -262,10 +277,8 @@ func PkgListCreate(binhostDir string, log *logger.Logger) ([]string, error) {
 			sort.Strings(pkgs)
 
 			for _, p := range pkgs {
-				f := filepath.Base(p)
 				ans = append(ans,
-					fmt.Sprintf("%s/%s",
-						cat, f[0:strings.Index(f, filepath.Ext(f))]))
+					fmt.Sprintf("%s/%s", cat, gentoo.GetBinPkgName(p)))
 			}
 		}
 	}
@@ -290,12 +303,10 @@ func PkgListCreateToMap(binhostDir string, log *logger.Logger) (map[string][]ent
 		for cat, pkgs := range binHostTree {
 			sort.Strings(pkgs)
 
-			gpkgs := make([]entropy.EntropyPackage, 0, len(pkgs))
+			gpkgs := make([]entropy.EntropyPackage, len(pkgs))
 			for idx, p := range pkgs {
-				f := filepath.Base(p)
 				gp, err := entropy.NewEntropyPackage(
-					fmt.Sprintf("%s/%s",
-						cat, f[0:strings.Index(f, filepath.Ext(f))]))
+					fmt.Sprintf("%s/%s", cat, gentoo.GetBinPkgName(p)))
 				if err != nil {
 					return nil, err
 				}
@@ -21,6 +21,9 @@ package pkglist_test
 
 import (
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 
 	entropy "github.com/Sabayon/pkgs-checker/pkg/entropy"
 	gentoo "github.com/Sabayon/pkgs-checker/pkg/gentoo"
@@ -28,6 +31,7 @@ import (
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	logger "github.com/sirupsen/logrus"
 )
 
 var _ = Describe("PKGLIST", func() {
@@ -110,4 +114,61 @@ sys-libs/e2fsprogs-libs-1.45.0~0
 		})
 
 	})
+	Describe("PkgList with blockers", func() {
+
+		pkgs := []string{"sys-devel/gcc-8.2.0", "!<sys-devel/gcc-7.0", "!sys-libs/binutils-libs"}
+
+		It("Check without versions", func() {
+			ans, err := PkgListWithoutVersions(pkgs)
+			Expect(err).Should(BeNil())
+			Expect(ans).Should(Equal([]string{"sys-devel/gcc"}))
+		})
+
+		It("Check intersect", func() {
+			ans, err := PkgListIntersectFromLists(pkgs,
+				[]string{"sys-devel/gcc-9.3.0", "sys-libs/binutils-libs-2.32"})
+			Expect(err).Should(BeNil())
+			Expect(ans).Should(Equal([]string{"sys-devel/gcc"}))
+		})
+	})
+
+	Describe("PkgList from binhost directory", func() {
+
+		var tmpdir string
+
+		BeforeEach(func() {
+			var err error
+			tmpdir, err = ioutil.TempDir("", "pkglist")
+			Expect(err).Should(BeNil())
+
+			for _, f := range []string{
+				"sys-devel/gcc-8.2.0.tbz2",
+				"sys-devel/gcc-9.3.0.gpkg.tar",
+				"sys-libs/binutils-libs-2.32-r1.tbz2",
+			} {
+				Expect(os.MkdirAll(filepath.Join(tmpdir, filepath.Dir(f)), 0755)).Should(BeNil())
+				Expect(ioutil.WriteFile(filepath.Join(tmpdir, f), []byte{}, 0644)).Should(BeNil())
+			}
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(tmpdir)
+		})
+
+		It("Check create to map", func() {
+			l := logger.New()
+			l.Out = ioutil.Discard
+			pmap, err := PkgListCreateToMap(tmpdir, l)
+			Expect(err).Should(BeNil())
+			Expect(len(pmap)).Should(Equal(2))
+			Expect(len(pmap["sys-devel"])).Should(Equal(2))
+			Expect(pmap["sys-devel"][0].GetPackageNameWithVersion()).Should(
+				Equal("sys-devel/gcc-8.2.0"))
+			Expect(pmap["sys-devel"][1].GetPackageNameWithVersion()).Should(
+				Equal("sys-devel/gcc-9.3.0"))
+			Expect(len(pmap["sys-libs"])).Should(Equal(1))
+			Expect(pmap["sys-libs"][0].GetPackageNameWithVersion()).Should(
+				Equal("sys-libs/binutils-libs-2.32-r1"))
+		})
+	})
 })
@@ -83,7 +83,7 @@ type SarkBuildEquoPackage struct {
 	Install []string `mapstructure:"install" yaml:"install,omitempty"`
 	Remove  []string `mapstructure:"remove" yaml:"remove,omitempty"`
 	Mask    []string `mapstructure:"mask" yaml:"mask,omitempty"`
-	Unmask  []string `mapstructure:"unmask yaml:"unmask,omitempty"`
+	Unmask  []string `mapstructure:"unmask" yaml:"unmask,omitempty"`
 }
 
 type SarkBuildScript struct {
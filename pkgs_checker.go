@@ -39,6 +39,8 @@ func main() {
 	// For string nil is not possible. I use empty string.
 	settings.SetDefault("directory", "")
 	settings.SetDefault("hashfile", "")
+	settings.SetDefault("algorithm", "md5")
+	settings.SetDefault("manifest", "")
 	settings.SetDefault("concurrency", false)
 	settings.SetDefault("maxconcurrency", 10)
 	settings.SetDefault("apikey", "")
@@ -52,6 +54,7 @@ func main() {
 	settings.BindEnv("concurrency")
 	settings.BindEnv("maxconcurrency")
 	settings.BindEnv("hash-empty")
+	settings.BindEnv("algorithm")
 	settings.BindEnv("apikey")
 	settings.BindEnv("insecure_skipverify")
 